@@ -0,0 +1,9 @@
+// Package config has the default configuration files.
+package config
+
+import "embed"
+
+// Built-in color themes, used when there is no theme file with the same name.
+//
+//go:embed themes/*.glaketheme
+var Themes embed.FS
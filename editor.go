@@ -25,7 +25,7 @@ func main() {
 	flag.Float64Var(&opt.FontSize, "fontsize", 12, "")
 	flag.StringVar(&opt.FontHinting, "fonthinting", "full", "font hinting: none, vertical, full")
 	flag.Float64Var(&opt.DPI, "dpi", 72, "monitor dots per inch")
-	flag.IntVar(&opt.TabWidth, "tabwidth", 8, "")
+	flag.IntVar(&opt.TabWidth, "tabwidth", 8, "tab width in spaces, used when there is no editorconfig tab_width")
 	// flag.StringVar(&opt.CarriageReturnRune, "carriagereturnrune", "", "replacement rune for carriage return")
 	flag.StringVar(&opt.WrapLineRune, "wraplinerune", "←", "code for wrap line rune, can be set to zero")
 	flag.StringVar(&opt.ColorTheme, "colortheme", "light", "color theme")
@@ -34,10 +34,14 @@ func main() {
 	flag.BoolVar(&opt.Shadows, "shadow.s", true, "shadow effects on some elements")
 	flag.StringVar(&opt.SessionName, "sn", "", "open existing session")
 	flag.StringVar(&opt.SessionName, "sessionname", "", "open existing session")
+	flag.StringVar(&opt.LegacyEncoding, "legacyencoding", "windows-1252", "encoding used to read files that are not valid utf-8, empty to keep them as is")
+	flag.StringVar(&opt.Backup, "backup", "", "keep the previous version of saved files: \"orig\" for a \"<filename>.orig\" copy, or a backups directory")
+	flag.IntVar(&opt.MmapThreshold, "mmapthreshold", 256, "files from this size (in megabytes) are memory mapped and shown read-only until MakeEditable is used, 0 disables")
+	flag.BoolVar(&opt.ViMode, "vimode", false, "vi-style modal editing (normal, insert and visual modes) in the rows textarea")
 	flag.BoolVar(&opt.UseMultiKey, "usemultikey", false, "use multi-key to compose characters (Ex: [multi-key, ~, a] = ã)")
 	flag.StringVar(&opt.Plugins, "plugins", "", "comma separated string of plugin filenames")
 	flag.Var(&opt.LSProtos, "lsproto", "Language-server-protocol register options. Can be specified multiple times.\nFormat: language,fileExtensions,network{tcp|tcpclient|stdio},command,optional{stderr,nogotoimpl}\nFormat notes:\n\tif network is tcp, the command runs in a template with vars: {{.Addr}}.\n\tif network is tcpclient, the command should be an ipaddress.\nExamples:\n\t"+strings.Join(lsproto.RegistrationExamples(), "\n\t"))
-	flag.Var(&opt.PreSaveHooks, "presavehook", "Run program before saving a file. Uses stdin/stdout. Can be specified multiple times. By default, a \"goimports\" entry is auto added if no entry is defined for the \"go\" language.\nFormat: language,fileExtensions,cmd\nExamples:\n"+
+	flag.Var(&opt.PreSaveHooks, "presavehook", "Run program before saving a file. Uses stdin/stdout. Can be specified multiple times. Replaces the language pre-save hook (ex: \"goimports\" for the \"go\" language) if defined for the language.\nFormat: language,fileExtensions,cmd\nExamples:\n"+
 		"\tgo,.go,goimports\n"+
 		"\tcpp,\".cpp .hpp\",\"\\\"clang-format --style={'opt1':1,'opt2':2}\\\"\"\n"+
 		"\tpython,.py,python_formatter")
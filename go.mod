@@ -7,10 +7,10 @@ require (
 	github.com/fsnotify/fsnotify v1.8.0
 	github.com/veandco/go-sdl2 v0.4.40
 	golang.org/x/image v0.24.0
+	golang.org/x/text v0.22.0
 )
 
 require (
 	github.com/dlclark/regexp2 v1.11.5 // indirect
 	golang.org/x/sys v0.30.0 // indirect
-	golang.org/x/text v0.22.0 // indirect
 )
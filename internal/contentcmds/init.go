@@ -6,6 +6,8 @@ import (
 
 func init() {
 	// order matters
+	core.ContentCmds.Append("pasteclipboardentry", PasteClipboardEntry)
+	core.ContentCmds.Append("recoveryaction", RecoveryAction)
 	core.ContentCmds.Append("gotoimplementation_lsproto", GoToImplementationLSProto)
 	core.ContentCmds.Append("gotodefinition_lsproto", GoToDefinitionLSProto)
 
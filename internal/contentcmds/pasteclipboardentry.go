@@ -0,0 +1,51 @@
+package contentcmds
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/ioutil"
+)
+
+var clipboardEntryRe = regexp.MustCompile(`^#(\d+) `)
+
+// Pastes the clicked entry of the +ClipboardHistory row.
+func PasteClipboardEntry(ctx context.Context, erow *core.ERow, index int) (error, bool) {
+	if erow.Info.Name() != core.ClipboardHistoryRowName {
+		return nil, false
+	}
+	ta := erow.Row.TextArea
+
+	// limit reading
+	rd := ioutil.NewLimitedReaderAtPad(ta.RW(), index, index, 1000)
+	a, err := ioutil.LineStartIndex(rd, index)
+	if err != nil {
+		return err, true
+	}
+	b, _, err := ioutil.LineEndIndex(rd, index)
+	if err != nil {
+		return err, true
+	}
+	line, err := rd.ReadFastAt(a, b-a)
+	if err != nil {
+		return err, true
+	}
+	m := clipboardEntryRe.FindSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("not a clipboard entry"), true
+	}
+	id, err := strconv.Atoi(string(m[1]))
+	if err != nil {
+		return err, true
+	}
+
+	erow.Ed.UI.RunOnUIGoRoutine(func() {
+		if err := core.PasteClipboardEntry(erow.Ed, id); err != nil {
+			erow.Ed.Error(err)
+		}
+	})
+	return nil, true
+}
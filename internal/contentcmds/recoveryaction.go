@@ -0,0 +1,74 @@
+package contentcmds
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"unicode"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/ioutil"
+)
+
+const recoveryActions = "diff restore discard"
+
+var recoveryEntryRe = regexp.MustCompile(`^#(\S+) .*: ` + recoveryActions + `$`)
+
+// Runs the clicked action (diff, restore, discard) of an entry of the +Recovery row.
+func RecoveryAction(ctx context.Context, erow *core.ERow, index int) (error, bool) {
+	if erow.Info.Name() != core.RecoveryRowName {
+		return nil, false
+	}
+	ta := erow.Row.TextArea
+
+	// limit reading
+	rd := ioutil.NewLimitedReaderAtPad(ta.RW(), index, index, 1000)
+	a, err := ioutil.LineStartIndex(rd, index)
+	if err != nil {
+		return err, true
+	}
+	b, _, err := ioutil.LineEndIndex(rd, index)
+	if err != nil {
+		return err, true
+	}
+	line, err := rd.ReadFastAt(a, b-a)
+	if err != nil {
+		return err, true
+	}
+	line = []byte(string(line)) // copy
+	if n := len(line); n > 0 && line[n-1] == '\n' {
+		line = line[:n-1]
+	}
+	m := recoveryEntryRe.FindSubmatch(line)
+	if m == nil {
+		return fmt.Errorf("not a recovery entry"), true
+	}
+	id := string(m[1])
+
+	// clicked word, only in the actions after the quoted filename
+	k := min(index-a, len(line))
+	as := len(line) - len(recoveryActions)
+	if k < as {
+		return fmt.Errorf("click diff, restore or discard"), true
+	}
+	s, e := k, k
+	for s > as && unicode.IsLetter(rune(line[s-1])) {
+		s--
+	}
+	for e < len(line) && unicode.IsLetter(rune(line[e])) {
+		e++
+	}
+	action := string(line[s:e])
+	switch action {
+	case "diff", "restore", "discard":
+	default:
+		return fmt.Errorf("click diff, restore or discard"), true
+	}
+
+	erow.Ed.UI.RunOnUIGoRoutine(func() {
+		if err := core.RecoveryAction(erow.Ed, id, action); err != nil {
+			erow.Ed.Error(err)
+		}
+	})
+	return nil, true
+}
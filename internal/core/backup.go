@@ -0,0 +1,42 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Keeps a copy of the file contents before being overwritten. The backup option is "orig" to keep it next to the file as "<filename>.orig", or a directory where the copies are named after the full path with "%" as separator (ex: "%home%user%a.txt").
+func backupFile(backup, filename string) error {
+	if backup == "" {
+		return nil
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // nothing to keep
+		}
+		return err
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	dst := filename + ".orig"
+	if backup != "orig" {
+		abs, err := filepath.Abs(filename)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(backup, 0o700); err != nil {
+			return err
+		}
+		dst = filepath.Join(backup, strings.ReplaceAll(abs, string(filepath.Separator), "%"))
+	}
+	if err := os.WriteFile(dst, b, fi.Mode().Perm()); err != nil {
+		return fmt.Errorf("backup: %w", err)
+	}
+	return nil
+}
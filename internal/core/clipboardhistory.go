@@ -0,0 +1,53 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+
+	"github.com/friedelschoen/glake/internal/editbuf"
+)
+
+const ClipboardHistoryRowName = "+ClipboardHistory"
+
+// Shows the clipboard history entries, one per line, in the +ClipboardHistory row.
+func ListClipboardHistory(ed *Editor) {
+	entries := editbuf.Clipboard.Entries()
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "clipboard history: %d\n", len(entries))
+	fmt.Fprintf(buf, "(click an entry to paste it into the previous active row)\n")
+	for _, e := range entries {
+		fmt.Fprintf(buf, "#%v %v\n", e.Id, clipboardEntryPreview(e.Text))
+	}
+
+	erow, _ := ExistingERowOrNewBasic(ed, ClipboardHistoryRowName)
+	erow.Row.TextArea.SetBytesClearPos(buf.Bytes())
+	erow.Flash()
+}
+
+// Pastes a clipboard history entry into the row that was active before the +ClipboardHistory row.
+func PasteClipboardEntry(ed *Editor, id int) error {
+	erow, ok := ed.PreviousActiveERow()
+	if !ok || erow.Info.Name() == ClipboardHistoryRowName {
+		return fmt.Errorf("no row to paste into")
+	}
+	ta := erow.Row.TextArea
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	if err := editbuf.PasteEntry(ta.EditCtx(), id); err != nil {
+		return err
+	}
+	ta.MakeCursorVisible()
+	erow.Flash()
+	return nil
+}
+
+func clipboardEntryPreview(s string) string {
+	const max = 80
+	rs := []rune(s)
+	if len(rs) <= max {
+		return strconv.Quote(s)
+	}
+	return strconv.Quote(string(rs[:max])) + "..."
+}
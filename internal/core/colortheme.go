@@ -0,0 +1,74 @@
+package core
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/friedelschoen/glake/internal/fswatcher"
+	"github.com/friedelschoen/glake/internal/ui"
+)
+
+// Color theme files (with the inherited ones) watched to reload the theme on change.
+type colorThemeFiles struct {
+	sync.Mutex
+	name  string // theme set, can differ from ui.ColorTheme if it failed to load
+	files []string
+}
+
+// Sets the color theme. The theme files are watched also on errors, the theme is set when they are fixed. A theme that is not found (no files) keeps watching the current theme.
+func (ed *Editor) SetColorTheme(name string) error {
+	files, err := ui.SetColorscheme(name, ed.UI.Root)
+	if err == nil || len(files) > 0 {
+		ed.colorTheme.Lock()
+		ed.colorTheme.name = name
+		ed.colorTheme.Unlock()
+		ed.watchColorThemeFiles(files)
+	}
+	if err != nil {
+		return err
+	}
+	ed.UI.Root.MarkNeedsLayoutAndPaint()
+	return nil
+}
+
+func (ed *Editor) watchColorThemeFiles(files []string) {
+	ed.colorTheme.Lock()
+	old := ed.colorTheme.files
+	ed.colorTheme.files = files
+	ed.colorTheme.Unlock()
+
+	for _, name := range old {
+		if slices.Contains(files, name) {
+			continue
+		}
+		// still watched for an open row
+		if info, ok := ed.ERowInfo(name); ok && len(info.ERows) > 0 {
+			continue
+		}
+		_ = ed.Watcher.Remove(name)
+	}
+	for _, name := range files {
+		_ = ed.Watcher.Add(name)
+	}
+}
+
+func (ed *Editor) isColorThemeFile(name string) bool {
+	ed.colorTheme.Lock()
+	defer ed.colorTheme.Unlock()
+	return slices.Contains(ed.colorTheme.files, name)
+}
+
+// Reloads the theme when one of its files is written. On errors (ex: a file being edited) the current palette is kept.
+func (ed *Editor) handleColorThemeEvent(ev *fswatcher.Event) {
+	if !ev.Op.HasAny(fswatcher.Create|fswatcher.Modify) || !ed.isColorThemeFile(ev.Name) {
+		return
+	}
+	ed.UI.RunOnUIGoRoutine(func() {
+		ed.colorTheme.Lock()
+		name := ed.colorTheme.name
+		ed.colorTheme.Unlock()
+		if err := ed.SetColorTheme(name); err != nil {
+			ed.Error(err)
+		}
+	})
+}
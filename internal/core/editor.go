@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -21,7 +22,10 @@ import (
 	"github.com/friedelschoen/glake/internal/drawer"
 	"github.com/friedelschoen/glake/internal/fswatcher"
 	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/keymap"
+	"github.com/friedelschoen/glake/internal/language"
 	"github.com/friedelschoen/glake/internal/lsproto"
+	"github.com/friedelschoen/glake/internal/textencoding"
 	"github.com/friedelschoen/glake/internal/toolbarparser"
 	"github.com/friedelschoen/glake/internal/ui"
 	"github.com/friedelschoen/glake/internal/ui/driver"
@@ -37,6 +41,9 @@ type Editor struct {
 	RowReopener       *RowReopener
 	LSProtoMan        *lsproto.Manager
 	InlineComplete    *InlineComplete
+	Macros            *Macros
+	Snippets          *Snippets
+	Languages         *language.Registry
 	Plugins           *Plugins
 	EEvents           *EEvents // editor events (used by plugins)
 	FsCaseInsensitive bool     // filesystem
@@ -45,8 +52,16 @@ type Editor struct {
 	ifbw         *InfoFloatBoxWrap
 	erowInfos    map[string]*ERowInfo // use ed.ERowInfo*() to access
 	preSaveHooks []*PreSaveHook
+	prevActive   *ERow      // previous active row
+	globalKeys   keymap.Seq // pending keys of a global sequence (one keyboard)
+	colorTheme   colorThemeFiles
 
 	zipSessionsFile bool
+	viMode          bool              // new rows start with vi-style modal editing
+	autoPairs       map[string]string // language -> pairs, overrides editbuf.DefaultAutoPairs
+	legacyEncoding  string            // used to read files that are not valid utf-8
+	backup          string            // keep a copy of the saved files previous version, see backupFile
+	mmapThreshold   int64             // files from this size are memory mapped read-only (bytes, 0 disables)
 }
 
 func RunEditor(opt *Options) error {
@@ -61,6 +76,8 @@ func RunEditor(opt *Options) error {
 	ed.RowReopener = NewRowReopener(ed)
 	ed.dndh = NewDndHandler(ed)
 	ed.InlineComplete = NewInlineComplete(ed)
+	ed.Macros = NewMacros(ed)
+	ed.Snippets = NewSnippets(ed)
 	ed.EEvents = NewEEvents()
 
 	if err := ed.init(opt); err != nil {
@@ -68,6 +85,7 @@ func RunEditor(opt *Options) error {
 	}
 
 	go ed.fswatcherEventLoop()
+	go ed.autosaveLoop()
 	ed.uiEventLoop() // blocks
 
 	return nil
@@ -82,9 +100,27 @@ func (ed *Editor) init(opt *Options) error {
 	ed.Watcher = fswatcher.NewGWatcher(w)
 
 	ed.zipSessionsFile = opt.ZipSessionsFile
+	ed.viMode = opt.ViMode
+	ed.autoPairs = opt.AutoPairs
+	ed.backup = opt.Backup
+	ed.mmapThreshold = int64(opt.MmapThreshold) << 20
+	ed.legacyEncoding = opt.LegacyEncoding
+	if ed.legacyEncoding != "" {
+		enc, err := textencoding.EncodingName(ed.legacyEncoding)
+		if err != nil {
+			return err
+		}
+		ed.legacyEncoding = enc
+	}
 
 	ed.setupTheme(opt)
 
+	languages, langErr := language.NewRegistry(opt.Languages)
+	if langErr != nil {
+		languages, _ = language.NewRegistry(nil)
+	}
+	ed.Languages = languages
+
 	// user interface
 	ui0, err := ui.NewUI("Editor")
 	if err != nil {
@@ -97,6 +133,18 @@ func (ed *Editor) init(opt *Options) error {
 	// TODO: ensure it has the window measure
 	ed.EnsureOneColumn()
 
+	// user key bindings
+	if err := keymap.Load(opt.Keys); err != nil {
+		ed.Error(err)
+	}
+	if langErr != nil {
+		ed.Error(langErr)
+	}
+	// set again to report errors and watch the theme files
+	if err := ed.SetColorTheme(opt.ColorTheme); err != nil {
+		ed.Error(err)
+	}
+
 	// setup plugins
 	setupInitialRows := true
 	err = ed.setupPlugins(opt)
@@ -112,6 +160,11 @@ func (ed *Editor) init(opt *Options) error {
 		})
 	}
 
+	// files with unsaved changes from a previous crash
+	ed.UI.RunOnUIGoRoutine(func() {
+		ListRecovery(ed, true)
+	})
+
 	ed.initLSProto(opt)
 	ed.initPreSaveHooks(opt)
 
@@ -126,34 +179,29 @@ func (ed *Editor) initLSProto(opt *Options) {
 	}
 
 	// NOTE: argument for not having auto-registration: don't auto add since the lsproto server could have issues, and auto-adding doesn't allow the user to have a choice to using directly some other option (like a plugin)
-	// NOTE: unlikely to be using a plugin for golang since gopls is fairly stable now, allow auto registration at least for ".go" if not present
+	// NOTE: unlikely to be using a plugin for golang since gopls is fairly stable now, allow auto registration at least for languages that define one (ex: "go")
 
-	// auto setup gopls if there is no handler for ".go" files
-	_, err := ed.LSProtoMan.LangManager("a.go")
-	if err != nil { // no registration exists
-		s := lsproto.GoplsRegistration(false, false, false)
-		reg, err := lsproto.NewRegistration(s)
+	// auto setup the languages registrations if there is no handler for their file extensions
+	for _, l := range ed.Languages.Languages() {
+		if l.LSProto == "" {
+			continue
+		}
+		reg, err := lsproto.NewRegistration(l.LSProto)
 		if err != nil {
-			panic(err)
+			ed.Errorf("language %v: lsproto: %w", l.Id, err)
+			continue
+		}
+		if slices.ContainsFunc(reg.Exts, func(ext string) bool {
+			_, err := ed.LSProtoMan.LangManager("a" + ext)
+			return err == nil
+		}) {
+			continue
 		}
 		_ = ed.LSProtoMan.Register(reg)
 	}
 }
 
 func (ed *Editor) initPreSaveHooks(opt *Options) {
-	// auto register "goimports" if no entry exists for the "go" language
-	found := false
-	for _, r := range opt.PreSaveHooks.regs {
-		if r.Language == "go" {
-			found = true
-			break
-		}
-	}
-	if !found {
-		exec := "goimports"
-		opt.PreSaveHooks.MustSet("go,.go," + exec)
-	}
-
 	ed.preSaveHooks = opt.PreSaveHooks.regs
 }
 
@@ -164,6 +212,11 @@ func (ed *Editor) Close() {
 
 func (ed *Editor) uiEventLoop() {
 	defer ed.UI.Close()
+	defer func() {
+		if r := recover(); r != nil {
+			ed.panicWriteSwapFiles(r)
+		}
+	}()
 
 	for {
 		ed.UI.PollEvent()
@@ -176,6 +229,8 @@ func (ed *Editor) uiEventLoop() {
 		}
 		switch t := ev.(type) {
 		case *driver.WindowClose:
+			ed.storeHistories()
+			ed.removeSwapFiles()
 			return
 		case *driver.DndPosition:
 			ed.dndh.OnPosition(t)
@@ -209,6 +264,7 @@ func (ed *Editor) fswatcherEventLoop() {
 }
 
 func (ed *Editor) handleWatcherEvent(ev *fswatcher.Event) {
+	ed.handleColorThemeEvent(ev)
 	info, ok := ed.ERowInfo(ev.Name)
 	if ok {
 		ed.UI.RunOnUIGoRoutine(func() {
@@ -324,6 +380,16 @@ func (ed *Editor) ActiveERow() (*ERow, bool) {
 	return nil, false
 }
 
+// Row that was active before the current active row (ex: to paste into it from a special row).
+func (ed *Editor) PreviousActiveERow() (*ERow, bool) {
+	for _, e := range ed.ERows() {
+		if e == ed.prevActive {
+			return e, true
+		}
+	}
+	return nil, false
+}
+
 func (ed *Editor) setupUIRoot() {
 	ed.setupRootToolbar()
 	ed.setupRootMenuToolbar()
@@ -342,6 +408,11 @@ func (ed *Editor) setupRootToolbar() {
 	tb.EvReg.Add(ui.TextAreaCmdEventId, func(ev any) {
 		InternalCmdFromRootTb(ed, tb)
 	})
+	// key bindings not handled by the text editing
+	tb.EvReg.Add(ui.TextAreaKeyActionEventId, func(ev0 any) {
+		ev := ev0.(*ui.TextAreaKeyActionEvent)
+		ev.ReplyHandled = ed.runKeyAction(ev.Action)
+	})
 	// on write
 	tb.RWEvReg.Add(ioutil.RWEvIdWrite, func(ev0 any) {
 		ed.updateERowsToolbarsHomeVars()
@@ -357,6 +428,11 @@ func (ed *Editor) setupRootMenuToolbar() {
 	tb.EvReg.Add(ui.TextAreaCmdEventId, func(ev any) {
 		InternalCmdFromRootTb(ed, tb)
 	})
+	// key bindings not handled by the text editing
+	tb.EvReg.Add(ui.TextAreaKeyActionEventId, func(ev0 any) {
+		ev := ev0.(*ui.TextAreaKeyActionEvent)
+		ev.ReplyHandled = ed.runKeyAction(ev.Action)
+	})
 	// on write
 	tb.RWEvReg.Add(ioutil.RWEvIdWrite, func(ev0 any) {
 		ed.updateERowsToolbarsHomeVars()
@@ -379,6 +455,8 @@ func (ed *Editor) setupRootMenuToolbar() {
 		{"NewFile", "SaveAllFiles", "Save"},
 		{"OpenExternal", "OpenFilemanager", "OpenTerminal"},
 		{"Reload", "ReloadAll", "ReloadAllFiles"},
+		{"ReplaceAll -h"},
+		{"Search -h"},
 		{"SortTextLines", "SortTextLines -h"},
 	}
 	last := []string{"Exit", "Version", "Stop", "Clear"}
@@ -449,7 +527,9 @@ func (ed *Editor) setupInitialRows(opt *Options) {
 
 func (ed *Editor) setupTheme(opt *Options) {
 	drawer.WrapLineRune, _ = utf8.DecodeRuneInString(opt.WrapLineRune)
-	// fontcache.TabWidth = opt.TabWidth
+	if opt.TabWidth > 0 {
+		drawer.DefaultTabWidth = opt.TabWidth
+	}
 	// fontcache.CarriageReturnRune, _ = utf8.DecodeRuneInString(opt.CarriageReturnRune)
 	ui.ScrollBarLeft = opt.ScrollBarLeft
 	ui.ScrollBarWidth = opt.ScrollBarWidth
@@ -510,29 +590,40 @@ func (ed *Editor) NewColumn() *ui.Column {
 
 func (ed *Editor) handleGlobalShortcuts(ev any) (handled bool) {
 	switch t := ev.(type) {
-	case driver.KeyDown:
-		autoCloseInfo := true
-
-		switch {
-		case t.Key.Is("Escape"):
-			ed.InlineComplete.CancelAndClear()
-			ed.cancelERowInfosCmds()
-			ed.cancelERowsContentCmds()
-			ed.cancelERowsInternalCmds()
-			autoCloseInfo = false
+	case *driver.KeyDown:
+		x, y, _ := sdl.GetMouseState()
+		p := image.Point{int(x), int(y)}
+
+		actions, ok := keymap.Get(keymap.Global).Lookup(&ed.globalKeys, t.Key)
+		for _, action := range actions {
+			// leaving vi insert/visual mode is not a cancel
+			if action == "cancel" && ed.viModeEscape(p) {
+				continue
+			}
+			if !ed.runKeyAction(action) {
+				ed.Errorf("keymap: unknown global action: %q", action)
+			}
+		}
+		if ok {
+			// canceling doesn't consume the key, the text areas might use it (ex: vi mode)
+			return len(actions) == 0 || actions[len(actions)-1] != "cancel"
+		}
+
+		ed.UI.Root.ContextFloatBox.AutoClose(t, p)
+		if !ed.ifbw.ui().Visible() {
 			ed.cancelInfoFloatBox()
-			return true
-		case t.Key.Is("F1"):
-			autoCloseInfo = false
-			ed.toggleInfoFloatBox()
-			return true
 		}
+	}
+	return false
+}
 
-		if autoCloseInfo {
-			x, y, _ := sdl.GetMouseState()
-			ed.UI.Root.ContextFloatBox.AutoClose(t, image.Point{int(x), int(y)})
-			if !ed.ifbw.ui().Visible() {
-				ed.cancelInfoFloatBox()
+// Reports if the row text area under the point (receiving the keys) uses escape in vi mode (ex: to go back to normal mode, or to end a search).
+func (ed *Editor) viModeEscape(p image.Point) bool {
+	for _, erow := range ed.ERows() {
+		for _, ta := range []*ui.TextArea{erow.Row.TextArea, erow.Row.Toolbar.TextArea} {
+			if p.In(ta.Bounds) {
+				vi := ta.EditCtx().Vi
+				return vi != nil && vi.UsesEscape()
 			}
 		}
 	}
@@ -750,9 +841,15 @@ func (ed *Editor) CanModifyAnnotations(req EdAnnotationsRequester, ta *ui.TextAr
 	}
 }
 
+// Runs the -presavehook entries of the file extension, or the language pre-save hook if no entry exists for the language.
 func (ed *Editor) runPreSaveHooks(ctx context.Context, info *ERowInfo, b []byte) ([]byte, error) {
+	lang := info.Language()
+	langHook := lang.PreSaveHook != ""
 	ext := filepath.Ext(info.Name())
 	for _, h := range ed.preSaveHooks {
+		if h.Language == lang.Id {
+			langHook = false
+		}
 		for _, e := range h.Exts {
 			if e == ext {
 				b2, err := ed.runPreSaveHook(ctx, info, b, h.Cmd)
@@ -764,6 +861,13 @@ func (ed *Editor) runPreSaveHooks(ctx context.Context, info *ERowInfo, b []byte)
 			}
 		}
 	}
+	if langHook {
+		b2, err := ed.runPreSaveHook(ctx, info, b, lang.PreSaveHook)
+		if err != nil {
+			return nil, fmt.Errorf("presavehook(%v): %w", lang.Id, err)
+		}
+		b = b2
+	}
 	return b, nil
 }
 
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/friedelschoen/glake/internal/editorconfig"
+	"github.com/friedelschoen/glake/internal/textencoding"
+)
+
+// Applies the language indentation, and the .editorconfig indentation properties (take precedence) to the row editing.
+func (erow *ERow) setupEditorConfig() {
+	if !erow.Info.IsFileButNotDir() {
+		return
+	}
+	ta := erow.Row.TextArea
+	if u := erow.Info.Language().IndentUnit(); u != "" {
+		ta.EditCtx().IndentUnit = u
+	}
+	props, err := editorconfig.Lookup(erow.Info.Name())
+	if err != nil {
+		erow.Ed.Error(err)
+		return
+	}
+	switch props.IndentStyle() {
+	case "tab":
+		ta.EditCtx().IndentUnit = "\t"
+	case "space":
+		n := props.IndentSize()
+		if n <= 0 {
+			n = 4
+		}
+		ta.EditCtx().IndentUnit = strings.Repeat(" ", n)
+	}
+	ta.Drawer.SetTabWidth(props.TabWidth())
+}
+
+// Applies the .editorconfig saving properties (trailing whitespace, final newline) to the file content, and the line endings and charset to the file format.
+func editorConfigOnSave(filename string, b []byte, f textencoding.Format) ([]byte, textencoding.Format, error) {
+	props, err := editorconfig.Lookup(filename)
+	if err != nil {
+		return nil, f, err
+	}
+	if len(props) == 0 {
+		return b, f, nil
+	}
+
+	eol := props.EndOfLine()
+	switch eol {
+	case "lf", "crlf", "cr":
+		f.EOL = eol
+	default:
+		eol = ""
+	}
+	trim, _ := props.TrimTrailingWhitespace()
+	if trim || eol != "" {
+		lines := splitLines(b)
+		buf := &bytes.Buffer{}
+		for _, l := range lines {
+			text, nl := l[0], l[1]
+			if trim {
+				text = bytes.TrimRight(text, " \t")
+			}
+			buf.Write(text)
+			if len(nl) > 0 && eol != "" {
+				nl = []byte("\n") // converted by the format
+			}
+			buf.Write(nl)
+		}
+		b = buf.Bytes()
+	}
+
+	if v, ok := props.InsertFinalNewline(); ok && v && len(b) > 0 {
+		if last := b[len(b)-1]; last != '\n' && last != '\r' {
+			b = append(b, '\n')
+		}
+	}
+
+	switch cs := props.Charset(); cs {
+	case "":
+	case "utf-16le", "utf-16be":
+		// keep the current byte order mark choice
+		if !strings.HasPrefix(f.Encoding, cs) {
+			f.Encoding = cs + "-bom"
+		}
+	default:
+		if enc, err := textencoding.EncodingName(cs); err == nil {
+			f.Encoding = enc
+		}
+	}
+	return b, f, nil
+}
+
+// Lines split into text and line ending ("\n", "\r\n", "\r", or empty at the end).
+func splitLines(b []byte) [][2][]byte {
+	u := [][2][]byte{}
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "\r\n")
+		if i < 0 {
+			u = append(u, [2][]byte{b, nil})
+			break
+		}
+		n := 1
+		if b[i] == '\r' && i+1 < len(b) && b[i+1] == '\n' {
+			n = 2
+		}
+		u = append(u, [2][]byte{b[:i], b[i : i+n]})
+		b = b[i+n:]
+	}
+	return u
+}
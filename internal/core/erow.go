@@ -9,8 +9,11 @@ import (
 	"strings"
 	"sync"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/friedelschoen/glake/internal/drawer"
+	"github.com/friedelschoen/glake/internal/editbuf"
 	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/textencoding"
 	"github.com/friedelschoen/glake/internal/toolbarparser"
 	"github.com/friedelschoen/glake/internal/ui"
 	"github.com/friedelschoen/glake/internal/ui/driver"
@@ -27,6 +30,7 @@ type ERow struct {
 
 	terminalOpt    terminalOpt
 	scrollDownMode string
+	hexVar         bool // "$hex" was in the toolbar on the last parse
 
 	ctx       context.Context // erow general context
 	cancelCtx context.CancelFunc
@@ -92,9 +96,14 @@ func (erow *ERow) init(info *ERowInfo, rowPos *ui.RowPos) {
 	erow.ctx, erow.cancelCtx = context.WithCancel(ctx0)
 
 	erow.setupSyntaxHighlightAndCommentShortcuts()
+	erow.setupAutoPair()
+	erow.setupEditorConfig()
 	erow.initHandlers()
+	erow.Row.TextArea.SetViMode(erow.Ed.viMode)
 
 	erow.updateToolbarNameEncoding2("")
+	erow.updateToolbarEncoding()
+	erow.updateToolbarHex()
 
 	// editor events
 	ev := &PostNewERowEEvent{ERow: erow}
@@ -136,18 +145,34 @@ func newLoadedFileERow(info *ERowInfo, rowPos *ui.RowPos) (*ERow, error) {
 		return erow, nil
 	}
 
+	// big file, read-only
+	if erow, ok := newMmapFileERow(info, rowPos); ok {
+		return erow, nil
+	}
+
 	// load
-	b, err := info.readFsFile()
+	b, f, err := info.readFsFile()
 	if err != nil {
 		return nil, err
 	}
 
 	// update data
+	info.fileData.format = f
 	info.setSavedHash(info.fileData.fs.hash, len(b))
 
 	// new erow (no other rows exist)
 	erow := NewBasicERow(info, rowPos)
+	erow.Row.TextArea.SetRW(ioutil.NewPieceTable(nil)) // fast edits on big files
 	erow.Row.TextArea.SetBytesClearHistory(b)
+	if textencoding.IsBinary(b) {
+		if err := info.SetHexMode(true); err != nil {
+			info.Ed.Error(err)
+		}
+		return erow, nil
+	}
+	if err := info.restoreHistory(erow, info.fileData.fs.hash); err != nil {
+		info.Ed.Error(err)
+	}
 
 	return erow, nil
 }
@@ -216,11 +241,27 @@ func (erow *ERow) initHandlers() {
 	// textarea inlinecomplete
 	row.TextArea.EvReg.Add(ui.TextAreaInlineCompleteEventId, func(ev0 any) {
 		ev := ev0.(*ui.TextAreaInlineCompleteEvent)
+		if erow.Ed.Snippets.ExpandAtCursor(erow) {
+			ev.ReplyHandled = true
+			return
+		}
 		handled := erow.Ed.InlineComplete.Complete(erow, ev)
 		// Allow the input event (`tab` key press) to function normally if the inlinecomplete is not being handled (ex: no lsproto server is registered for this filename extension)
 		ev.ReplyHandled = bool(handled)
 	})
-	// key shortcuts
+	// key bindings not handled by the text editing (ex: save, commands)
+	keyAction := func(ev0 any) {
+		ev := ev0.(*ui.TextAreaKeyActionEvent)
+		ev.ReplyHandled = erow.runKeyAction(ev.Action)
+	}
+	row.Toolbar.EvReg.Add(ui.TextAreaKeyActionEventId, keyAction)
+	row.TextArea.EvReg.Add(ui.TextAreaKeyActionEventId, keyAction)
+	// keyboard macros
+	row.TextArea.EvReg.Add(ui.TextAreaInputEventId, func(ev0 any) {
+		ev := ev0.(*ui.TextAreaInputEvent)
+		erow.Ed.Macros.record(ev.Event)
+	})
+	// input events
 	row.EvReg.Add(ui.RowInputEventId, func(ev0 any) {
 		ev := ev0.(*ui.RowInputEvent)
 
@@ -229,29 +270,10 @@ func (erow *ERow) initHandlers() {
 			erow.Ed.InlineComplete.CancelAndClear()
 		}
 
-		switch evt := ev.Event.(type) {
-		case *driver.KeyDown:
+		switch ev.Event.(type) {
+		case *driver.KeyDown, *driver.MouseDown:
 			// activate row
 			erow.Info.UpdateActiveRowState(erow)
-			// shortcuts
-			switch {
-			case evt.Key.Is("ctrl-S"):
-				erow.SaveFileBusyCursor()
-			case evt.Key.Is("ctrl-F"):
-				AddFindShortcut(erow)
-			case evt.Key.Is("ctrl-H"):
-				AddReplaceShortcut(erow)
-			case evt.Key.Is("ctrl-N"):
-				AddNewFileShortcut(erow)
-			case evt.Key.Is("ctrl-R"):
-				AddReloadShortcut(erow)
-			case evt.Key.Is("ctrl-W"):
-				row.Close()
-			case evt.Key.Is("Escape"):
-				erow.Exec.Stop()
-			}
-		case *driver.MouseDown:
-			erow.Info.UpdateActiveRowState(erow)
 		case *driver.MouseEnter:
 			erow.highlightDuplicates = true
 			erow.Info.UpdateDuplicateHighlightRowState()
@@ -272,9 +294,20 @@ func (erow *ERow) initHandlers() {
 		// ensure execution (if any) is stopped
 		erow.Exec.Stop()
 
+		// keep undo history of the file
+		if len(erow.Info.ERows) == 1 {
+			if err := erow.Info.storeHistory(); err != nil {
+				erow.Ed.Error(err)
+			}
+			if err := erow.Info.removeSwapFile(); err != nil {
+				erow.Ed.Error(err)
+			}
+		}
+
 		// unregister from editor
 		erow.Info.RemoveERow(erow)
 		if len(erow.Info.ERows) == 0 {
+			erow.Info.closeMmapFile()
 			erow.Ed.DeleteERowInfo(erow.Info.Name())
 		}
 
@@ -283,7 +316,7 @@ func (erow *ERow) initHandlers() {
 		erow.Info.UpdateDuplicateHighlightRowState()
 
 		// unregister with watcher
-		if !erow.Info.IsSpecial() && len(erow.Info.ERows) == 0 {
+		if !erow.Info.IsSpecial() && len(erow.Info.ERows) == 0 && !erow.Ed.isColorThemeFile(erow.Info.Name()) {
 			erow.Ed.Watcher.Remove(erow.Info.Name())
 		}
 
@@ -369,6 +402,54 @@ func (erow *ERow) ToolbarSetStrAfterNameClearHistory(s string) {
 	erow.Row.Toolbar.SetStrClearHistory(str)
 }
 
+// Shows the file encoding and line endings in the toolbar (ex: "$encoding=utf-16le-bom,crlf") if not utf-8 with "\n" line endings.
+func (erow *ERow) updateToolbarEncoding() {
+	if !erow.Info.IsFileButNotDir() {
+		return
+	}
+	s := ""
+	if f := erow.Info.Format(); f != textencoding.Default {
+		s = "$encoding=" + f.String()
+	}
+	erow.updateToolbarPart("$encoding=", s)
+}
+
+// Replaces the toolbar part (after the name) with a single arg starting with prefix by s. Inserts it after the name if not found, or removes it if s is empty.
+func (erow *ERow) updateToolbarPart(prefix, s string) {
+	data := toolbarparser.Parse(erow.Row.Toolbar.Str())
+	str := data.Str
+	found := false
+	for _, p := range data.Parts[min(1, len(data.Parts)):] {
+		if len(p.Args) != 1 || !strings.HasPrefix(p.Args[0].String(), prefix) {
+			continue
+		}
+		found = true
+		a := p.Args[0]
+		if s != "" {
+			str = str[:a.Pos()] + s + str[a.End():]
+			break
+		}
+		// remove with the preceding separator
+		rest := str[p.End():]
+		str = str[:p.Pos()-1]
+		if rest == "" {
+			str = strings.TrimRight(str, " ")
+		}
+		str += rest
+		break
+	}
+	if !found && s != "" {
+		arg0, ok := data.Part0Arg0()
+		if !ok {
+			return
+		}
+		str = str[:arg0.End()] + " | " + s + str[arg0.End():]
+	}
+	if str != data.Str {
+		erow.Row.Toolbar.SetStrClearHistory(str)
+	}
+}
+
 func (erow *ERow) parseToolbarVars() {
 	vmap := toolbarparser.ParseVars(&erow.TbData)
 
@@ -405,6 +486,18 @@ func (erow *ERow) parseToolbarVars() {
 		}
 	}
 
+	// $hex: only changes in this toolbar switch the mode (a new duplicate row doesn't have it yet)
+	if _, ok := vmap[hexVar]; ok != erow.hexVar {
+		erow.hexVar = ok
+		if erow.Info.IsFileButNotDir() {
+			if err := erow.Info.SetHexMode(ok); err != nil {
+				erow.Ed.Error(err)
+			}
+		}
+	}
+
+	erow.setupSyntaxLexer(vmap)
+
 	// $scrollMode: "auto", otherwise is "manual"/"off"
 	erow.scrollDownMode = ""
 	if v, ok := vmap["$scrollMode"]; ok {
@@ -532,87 +625,50 @@ func (erow *ERow) MakeRangeVisibleAndFlash(index int, len int) {
 }
 
 func (erow *ERow) setupSyntaxHighlightAndCommentShortcuts() {
-	// // special handling for the toolbar (allow comment shortcut to work in the toolbar to easily disable cmds)
-	// tb := erow.Row.Toolbar
-	// tb.SetCommentStrings("#")
+	// special handling for the toolbar (allow comment shortcut to work in the toolbar to easily disable cmds)
+	erow.Row.Toolbar.SetCommentLineSym("#")
 
 	ta := erow.Row.TextArea
 
-	// // ensure syntax highlight is on (ex: strings)
+	// ensure syntax highlight is on (ex: strings)
 	ta.EnableSyntaxHighlight(true)
 
-	// //// consider only files from here (dirs and special rows are out)
-	// //if !erow.Info.IsFileButNotDir() {
-	// //	return
-	// //}
-
-	// // util funcs
-	// setComments := func(a ...any) {
-	// 	ta.SetCommentStrings(a...)
-	// }
-
-	// // ignore "." on files starting with "."
-	// name := filepath.Base(erow.Info.Name())
-	// if len(name) >= 1 && name[0] == '.' {
-	// 	name = name[1:]
-	// }
-
-	// // specific names
-	// switch name {
-	// case "bashrc":
-	// 	setComments("#")
-	// 	return
-	// case "Xresources":
-	// 	setComments("!")
-	// 	return
-	// }
-
-	// // specific suffixes (ex: allows "my_go.work")
-	// suffixes := []string{
-	// 	"go.mod", "go.sum", "go.work", "go.work.sum",
-	// }
-	// for _, suf := range suffixes {
-	// 	if strings.HasSuffix(name, suf) {
-	// 		setComments("//")
-	// 		return
-	// 	}
-	// }
-
-	// // setup comments based on name extension
-	// ext := strings.ToLower(filepath.Ext(name))
-	// switch ext {
-	// case ".sh",
-	// 	".conf", ".list",
-	// 	".py", // python
-	// 	".pl": // perl
-	// 	setComments("#")
-	// case ".go",
-	// 	".c", ".h",
-	// 	".cpp", ".hpp", ".cxx", ".hxx", // c++
-	// 	".java",
-	// 	".v",  // verilog
-	// 	".js": // javascript
-	// 	setComments("//", [2]string{"/*", "*/"})
-	// case ".ledger":
-	// 	setComments(";", "#") // ";" is main symbol for comments but is not if in the description; while "#" is not a comment in some other cases
-	// case ".pro": // prolog
-	// 	setComments("%", [2]string{"/*", "*/"})
-	// case ".html", ".xml", ".svg":
-	// 	setComments([2]string{"<!--", "-->"})
-	// case ".css":
-	// 	setComments([2]string{"/*", "*/"})
-	// case ".s", ".asm": // assembly
-	// 	setComments("//")
-	// case ".json":
-	// 	// no comments to setup
-	// case ".txt":
-	// 	setComments("#") // useful (but not correct)
-	// case "": // no file extension
-	// 	// handy for ex: /etc/network/interfaces
-	// 	setComments("#") // useful (but not correct)
-	// default: // all other file extensions
-	// 	setComments("#") // useful (but not correct)
-	// }
+	ta.SetCommentLineSym(erow.Info.Language().CommentSym())
+	ta.Drawer.SetSyntaxLexer(erow.Info.Language().ChromaLexer())
+}
+
+// $syntax: chroma lexer name overriding the file language lexer, "none" to disable (ex: "$syntax=python").
+func (erow *ERow) setupSyntaxLexer(vmap toolbarparser.VarMap) {
+	lexer := erow.Info.Language().ChromaLexer()
+	if v, ok := vmap["$syntax"]; ok {
+		if v == "none" {
+			lexer = nil
+		} else if l := lexers.Get(v); l != nil {
+			lexer = l
+		}
+	}
+	erow.Row.TextArea.Drawer.SetSyntaxLexer(lexer)
+}
+
+func (erow *ERow) setupAutoPair() {
+	if !erow.Info.IsFileButNotDir() {
+		return
+	}
+	lang := erow.Info.Language()
+	pairs, ok := erow.Ed.autoPairs[lang.Id]
+	if !ok {
+		pairs, ok = erow.Ed.autoPairs["*"]
+	}
+	if !ok {
+		pairs, ok = editbuf.DefaultAutoPairs[lang.Id]
+	}
+	if !ok {
+		pairs = editbuf.DefaultAutoPairs[""]
+	}
+	if pairs == "" {
+		return
+	}
+	erow.Row.TextArea.EditCtx().AutoPair = &editbuf.AutoPair{Pairs: pairs, Lexer: lang.ChromaLexer()}
 }
 
 func (erow *ERow) newContentCmdCtx() (context.Context, context.CancelFunc) {
@@ -5,12 +5,18 @@ import (
 	"context"
 	"crypto/sha1"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/language"
+	"github.com/friedelschoen/glake/internal/textencoding"
+	"github.com/friedelschoen/glake/internal/toolbarparser"
 	"github.com/friedelschoen/glake/internal/ui"
 )
 
@@ -26,18 +32,34 @@ type ERowInfo struct {
 	fi    os.FileInfo
 	fiErr error
 
+	lang *language.Language // cached, see Language()
+
 	// file type only
 	fileData struct {
+		// encoding and line endings used when saving (the content is kept as utf-8 with "\n" line endings)
+		format textencoding.Format
+
 		// saved/memory (keep even if file is deleted and reappears later)
 		saved struct {
-			size int
-			hash []byte
+			size   int
+			hash   []byte
+			format textencoding.Format
 		}
 		// filesystem (reflects changes by other programs)
 		fs struct {
 			hash    []byte
+			format  textencoding.Format
 			modTime time.Time
 		}
+		// memory mapped read-only content of big files, nil if the content is loaded
+		mmap *ioutil.MmapFile
+		// previous mapping (reload, MakeEditable), unmapped on the next reload or on close since reads might still be running
+		oldMmap *ioutil.MmapFile
+
+		// content written to the swap file (crash recovery), nil if there is no swap file
+		swap struct {
+			hash []byte
+		}
 		// not always up to date, used if the hash is being requested without the contents being changed
 		edited struct {
 			updated bool
@@ -64,6 +86,9 @@ func readERowInfoOrNew(ed *Editor, name string) *ERowInfo {
 
 	// new erow info
 	info = &ERowInfo{Ed: ed, name: name}
+	info.fileData.format = textencoding.Default
+	info.fileData.saved.format = textencoding.Default
+	info.fileData.fs.format = textencoding.Default
 	info.readFileInfo()
 	return info
 }
@@ -83,6 +108,7 @@ func (info *ERowInfo) readFileInfo() {
 		info.fiErr = err
 		return
 	}
+	wasFile := info.IsFileButNotDir()
 	info.fi = fi
 	info.fiErr = nil
 
@@ -97,6 +123,12 @@ func (info *ERowInfo) readFileInfo() {
 		info.fi = nil
 		info.fiErr = fmt.Errorf("file is a device")
 	}
+
+	// the file exists now (ex: a new file was saved), find its language
+	if !wasFile && info.IsFileButNotDir() {
+		info.lang = nil
+		info.updateRowsLanguage()
+	}
 }
 
 func (info *ERowInfo) IsSpecial() bool {
@@ -137,6 +169,58 @@ func (info *ERowInfo) Dir() string {
 	return filepath.Dir(info.Name())
 }
 
+// Language of the file, by filename, the "#!" first line, or the content. Special rows and directories get a default language (without lexer).
+func (info *ERowInfo) Language() *language.Language {
+	if info.lang != nil {
+		return info.lang
+	}
+	l := info.findLanguage()
+	// not cached until the file exists (the default is used, see readFileInfo)
+	if info.IsFileButNotDir() {
+		info.lang = l
+	}
+	return l
+}
+
+// Sets up the rows language dependent parts again.
+func (info *ERowInfo) updateRowsLanguage() {
+	for _, erow := range info.ERows {
+		erow.setupSyntaxHighlightAndCommentShortcuts()
+		erow.setupSyntaxLexer(toolbarparser.ParseVars(&erow.TbData)) // $syntax
+		erow.setupAutoPair()
+		erow.setupEditorConfig()
+	}
+}
+
+func (info *ERowInfo) findLanguage() *language.Language {
+	def := &language.Language{Id: "", LineComment: "#", Lexer: "none"} // useful (but not correct)
+	// files that don't exist yet are matched by name
+	if info.IsSpecial() || (info.HasFileinfo() && info.fi.IsDir()) {
+		return def
+	}
+	reg := info.Ed.Languages
+	if l, ok := reg.MatchFilename(info.Name()); ok {
+		return l
+	}
+	head, _ := readFileHead(info.Name(), 4096)
+	if l, ok := reg.MatchShebang(head); ok {
+		return l
+	}
+	// other lexers known by chroma, by filename or content
+	lexer := lexers.Match(filepath.Base(info.Name()))
+	if lexer == nil && len(head) > 0 {
+		lexer = lexers.Analyse(string(head))
+	}
+	if lexer != nil {
+		name := lexer.Config().Name
+		if l, ok := reg.Get(strings.ToLower(name)); ok {
+			return l
+		}
+		return &language.Language{Id: strings.ToLower(name), LineComment: "#", Lexer: name}
+	}
+	return def
+}
+
 func (info *ERowInfo) editedHashNeedsUpdate() {
 	info.fileData.edited.updated = false
 }
@@ -150,7 +234,7 @@ func (info *ERowInfo) updateEditedHash() {
 	if !ok {
 		return
 	}
-	b, err := erow0.Row.TextArea.Bytes()
+	b, err := info.rowsBytes(erow0)
 	if err != nil {
 		return
 	}
@@ -166,19 +250,35 @@ func (info *ERowInfo) setEditedHash(hash []byte, size int) {
 func (info *ERowInfo) setSavedHash(hash []byte, size int) {
 	info.fileData.saved.size = size
 	info.fileData.saved.hash = hash
+	info.fileData.saved.format = info.fileData.format
 	info.UpdateFsDifferRowState()
 }
 
-func (info *ERowInfo) setFsHash(hash []byte) {
+func (info *ERowInfo) setFsHash(hash []byte, format textencoding.Format) {
 	if info.fi == nil {
 		return
 	}
 	//info.fsHash.size = int(info.fi.Size()) // TODO: downgrading if 32bit system
 	info.fileData.fs.hash = hash
+	info.fileData.fs.format = format
 	info.fileData.fs.modTime = info.fi.ModTime()
 	info.UpdateFsDifferRowState()
 }
 
+// Encoding and line endings of the file.
+func (info *ERowInfo) Format() textencoding.Format {
+	return info.fileData.format
+}
+
+// Sets the encoding and line endings to use on the next save.
+func (info *ERowInfo) SetFormat(f textencoding.Format) {
+	info.fileData.format = f
+	info.UpdateEditedRowState()
+	for _, erow := range info.ERows {
+		erow.updateToolbarEncoding()
+	}
+}
+
 func (info *ERowInfo) updateFsHashIfNeeded() {
 	if !info.IsFileButNotDir() {
 		return
@@ -187,6 +287,11 @@ func (info *ERowInfo) updateFsHashIfNeeded() {
 		return
 	}
 	if !info.fi.ModTime().Equal(info.fileData.fs.modTime) {
+		if info.IsMmapped() {
+			// don't read the big file to compare
+			info.updateRowsStates(ui.RowStateFsDiffer, true)
+			return
+		}
 		info.readFsFile()
 	}
 }
@@ -242,12 +347,29 @@ func (info *ERowInfo) FirstERow() (*ERow, bool) {
 }
 
 func (info *ERowInfo) ReloadFile() error {
-	b, err := info.readFsFile()
+	if info.IsMmapped() {
+		return info.reloadMmapFile()
+	}
+	return info.reloadFile(info.readFsFile)
+}
+
+// Reloads the file decoding it with the encoding instead of detecting it.
+func (info *ERowInfo) ReloadFileWithEncoding(enc string) error {
+	return info.reloadFile(func() ([]byte, textencoding.Format, error) {
+		return info.readFsFile2(func(b []byte) ([]byte, textencoding.Format, error) {
+			return textencoding.DecodeAs(b, enc)
+		})
+	})
+}
+
+func (info *ERowInfo) reloadFile(read func() ([]byte, textencoding.Format, error)) error {
+	b, f, err := read()
 	if err != nil {
 		return err
 	}
 
 	// update data
+	info.SetFormat(f)
 	info.setSavedHash(info.fileData.fs.hash, len(b))
 
 	// update all erows
@@ -261,34 +383,53 @@ func (info *ERowInfo) SaveFile() error {
 	if !info.IsFileButNotDir() {
 		return fmt.Errorf("not a file: %s", info.Name())
 	}
+	if info.IsMmapped() {
+		return fmt.Errorf("read-only file, use %v to edit: %s", makeEditableCmd, info.Name())
+	}
 
 	// read from one of the erows
 	erow0, ok := info.FirstERow()
 	if !ok {
 		return nil
 	}
-	b, err := erow0.Row.TextArea.Bytes()
+	b, err := info.rowsBytes(erow0)
 	if err != nil {
 		return err
 	}
+	f := info.fileData.format
+
+	// hex mode: save the bytes as they are
+	if !info.IsHexMode() {
+		// run src formatters (ex: goimports)
+		ctx1, cancel1 := info.newCmdCtx()
+		defer cancel1()
+		if b2, err := info.Ed.runPreSaveHooks(ctx1, info, b); err != nil {
+			// ignore errors, can catch them when compiling
+			//info.Ed.Error(err)
+		} else {
+			b = b2
+		}
 
-	// run src formatters (ex: goimports)
-	ctx1, cancel1 := info.newCmdCtx()
-	defer cancel1()
-	if b2, err := info.Ed.runPreSaveHooks(ctx1, info, b); err != nil {
-		// ignore errors, can catch them when compiling
-		//info.Ed.Error(err)
-	} else {
-		b = b2
+		b, f, err = editorConfigOnSave(info.Name(), b, f)
+		if err != nil {
+			return err
+		}
 	}
 
-	if err := info.saveFsFile(b); err != nil {
+	if err := info.saveFsFile(b, f); err != nil {
 		return err
 	}
+	if err := info.removeSwapFile(); err != nil {
+		info.Ed.Error(err)
+	}
 
 	// update content
 	info.SetRowsBytes(b)
 
+	if err := info.storeHistory(); err != nil {
+		info.Ed.Error(err)
+	}
+
 	// editor events
 	ev := &PostFileSaveEEvent{Info: info}
 	info.Ed.EEvents.emit(PostFileSaveEEventId, ev)
@@ -314,37 +455,63 @@ func (info *ERowInfo) SaveFile() error {
 	return nil
 }
 
-func (info *ERowInfo) readFsFile() ([]byte, error) {
+// Returns the file content decoded to utf-8 with "\n" line endings, and the detected format. The hashes are of the decoded content.
+func (info *ERowInfo) readFsFile() ([]byte, textencoding.Format, error) {
+	if info.IsHexMode() {
+		return info.readFsFileRaw()
+	}
+	return info.readFsFile2(func(b []byte) ([]byte, textencoding.Format, error) {
+		// prefer the legacy encoding already in use
+		b2, format := textencoding.Decode(b, info.fileData.format.Encoding, info.Ed.legacyEncoding)
+		return b2, format, nil
+	})
+}
+
+// Returns the file bytes, without decoding.
+func (info *ERowInfo) readFsFileRaw() ([]byte, textencoding.Format, error) {
+	return info.readFsFile2(func(b []byte) ([]byte, textencoding.Format, error) {
+		return b, textencoding.Default, nil
+	})
+}
+
+func (info *ERowInfo) readFsFile2(decode func([]byte) ([]byte, textencoding.Format, error)) ([]byte, textencoding.Format, error) {
 	b, err := os.ReadFile(info.Name())
 	if err != nil {
-		return nil, err
+		return nil, textencoding.Format{}, err
+	}
+	b, format, err := decode(b)
+	if err != nil {
+		return nil, textencoding.Format{}, err
 	}
 
 	// update data
 	info.readFileInfo() // get new modtime
 	h := bytesHash(b)
-	info.setFsHash(h)
+	info.setFsHash(h, format)
 
-	return b, err
+	return b, format, nil
 }
 
-func (info *ERowInfo) saveFsFile(b []byte) error {
-	flags := os.O_WRONLY | os.O_TRUNC | os.O_CREATE
-	f, err := os.OpenFile(info.Name(), flags, 0644)
+func (info *ERowInfo) saveFsFile(b []byte, format textencoding.Format) error {
+	eb, err := textencoding.Encode(b, format)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	defer f.Sync() // necessary? modtime needs fsync on dir?
-	_, err = f.Write(b)
-	if err != nil {
+
+	if err := backupFile(info.Ed.backup, info.Name()); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFileAtomic(info.Name(), eb); err != nil {
 		return err
 	}
 
 	// update data
 	h := bytesHash(b)
 	info.readFileInfo() // get new modtime
-	info.setFsHash(h)
+	if format != info.fileData.format {
+		info.SetFormat(format)
+	}
+	info.setFsHash(h, format)
 	info.setSavedHash(h, len(b))
 
 	return nil
@@ -379,11 +546,12 @@ func (info *ERowInfo) HasRowState(st ui.RowState) bool {
 }
 
 func (info *ERowInfo) UpdateEditedRowState() {
-	if !info.IsFileButNotDir() {
+	if !info.IsFileButNotDir() || info.IsMmapped() {
 		return
 	}
 	info.editedHashNeedsUpdate()
-	edited := !info.EqualToBytesHash(info.fileData.saved.size, info.fileData.saved.hash)
+	edited := !info.EqualToBytesHash(info.fileData.saved.size, info.fileData.saved.hash) ||
+		info.fileData.format != info.fileData.saved.format
 	info.updateRowsStates(ui.RowStateEdited, edited)
 
 	// info.Ed.GoDebug.UpdateInfoAnnotations(info)
@@ -399,7 +567,7 @@ func (info *ERowInfo) UpdateFsDifferRowState() {
 	}
 	h1 := info.fileData.fs.hash
 	h2 := info.fileData.saved.hash
-	differ := !bytes.Equal(h1, h2)
+	differ := !bytes.Equal(h1, h2) || info.fileData.fs.format != info.fileData.saved.format
 	info.updateRowsStates(ui.RowStateFsDiffer, differ)
 }
 
@@ -431,6 +599,9 @@ func (info *ERowInfo) UpdateAnnotationsEditedRowState(v bool) {
 func (info *ERowInfo) UpdateActiveRowState(erow *ERow) {
 	// disable first the previous active row
 	for _, er := range info.Ed.ERows() {
+		if er != erow && er.Row.HasState(ui.RowStateActive) {
+			info.Ed.prevActive = er
+		}
 		if er != erow {
 			info.updateRowState(er, ui.RowStateActive, false)
 		}
@@ -462,6 +633,15 @@ func (info *ERowInfo) SetRowsBytes(b []byte) {
 		return
 	}
 	if erow0, ok := info.FirstERow(); ok {
+		if hv := erow0.Row.TextArea.HexView(); hv != nil {
+			// the hex view can only be overwritten, replace the content (clears the undo history)
+			if b2, err := ioutil.ReadFastFull(hv.Data()); err == nil && bytes.Equal(b, b2) {
+				return
+			}
+			info.setRowsRW(ioutil.NewPieceTable(b))
+			erow0.Row.TextArea.History().Clear()
+			return
+		}
 		// gets to duplicates via callback that in practice will only set pointers to share RW and History
 		erow0.Row.TextArea.SetBytes(b)
 	}
@@ -522,3 +702,20 @@ func bytesHash(b []byte) []byte {
 	h.Write(b)
 	return h.Sum(nil)
 }
+
+//----------
+
+// First n bytes of the file (less if the file is smaller).
+func readFileHead(filename string, n int) ([]byte, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	b := make([]byte, n)
+	k, err := io.ReadFull(f, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return b[:k], nil
+}
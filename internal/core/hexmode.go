@@ -0,0 +1,117 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/textencoding"
+	"github.com/friedelschoen/glake/internal/ui"
+)
+
+// Files can be shown as a hex dump (offset, hex and ascii columns), editable by overwriting the bytes. It is set with the "$hex" toolbar var, automatically for binary files. All rows of the file share the ioutil.HexView over the content. The content is kept as the file bytes (no encoding conversion).
+
+const hexVar = "$hex"
+
+func (info *ERowInfo) IsHexMode() bool {
+	erow0, ok := info.FirstERow()
+	return ok && erow0.Row.TextArea.HexView() != nil
+}
+
+// Shows the content as a hex dump, or back as text. The undo history is cleared.
+func (info *ERowInfo) SetHexMode(on bool) error {
+	if !info.IsFileButNotDir() {
+		return fmt.Errorf("not a file: %v", info.Name())
+	}
+	erow0, ok := info.FirstERow()
+	if !ok || on == info.IsHexMode() {
+		return nil
+	}
+
+	if on && info.Format() != textencoding.Default {
+		// show the bytes as saved
+		if !info.HasRowState(ui.RowStateEdited) {
+			if err := info.reloadFile(info.readFsFileRaw); err != nil {
+				return err
+			}
+		} else {
+			b, err := erow0.Row.TextArea.Bytes()
+			if err != nil {
+				return err
+			}
+			eb, err := textencoding.Encode(b, info.Format())
+			if err != nil {
+				return err
+			}
+			info.SetRowsBytes(eb)
+			info.SetFormat(textencoding.Default)
+		}
+	}
+
+	// keep the cursors at the same byte
+	cursors := map[*ERow]int{}
+	for _, erow := range info.ERows {
+		ci := erow.Row.TextArea.CursorIndex()
+		if hv := erow.Row.TextArea.HexView(); hv != nil {
+			ci, _, _ = hv.Locate(ci)
+		}
+		cursors[erow] = ci
+	}
+
+	ta := erow0.Row.TextArea
+	var rw ioutil.ReadWriterAt
+	if on {
+		rw = ioutil.NewHexView(ta.Text.RW())
+	} else {
+		rw = ta.HexView().Data()
+	}
+	ta.SetRW(rw)
+	ta.History().Clear() // edits of the other view
+	info.setRWFromMaster(erow0)
+
+	for _, erow := range info.ERows {
+		ta := erow.Row.TextArea
+		ci := cursors[erow]
+		if hv := ta.HexView(); hv != nil {
+			ci = hv.HexIndex(min(ci, max(0, hv.Size()-1)))
+		}
+		ta.Cursor().SetIndexSelectionOff(min(ci, ta.RW().Max()))
+		ta.MakeCursorVisible()
+		erow.updateToolbarHex()
+	}
+	return nil
+}
+
+// Sets the content RW of all rows, as a hex view if in hex mode.
+func (info *ERowInfo) setRowsRW(rw ioutil.ReadWriterAt) {
+	erow0, ok := info.FirstERow()
+	if !ok {
+		return
+	}
+	ta := erow0.Row.TextArea
+	if ta.HexView() != nil {
+		rw = ioutil.NewHexView(rw)
+	}
+	ta.SetRW(rw)
+	info.setRWFromMaster(erow0)
+}
+
+// Content of the rows: the bytes, not the dump, in hex mode.
+func (info *ERowInfo) rowsBytes(erow0 *ERow) ([]byte, error) {
+	ta := erow0.Row.TextArea
+	if hv := ta.HexView(); hv != nil {
+		return ioutil.ReadFastFull(hv.Data())
+	}
+	return ta.Bytes()
+}
+
+// Shows "$hex" in the toolbar if in hex mode.
+func (erow *ERow) updateToolbarHex() {
+	if !erow.Info.IsFileButNotDir() {
+		return
+	}
+	s := ""
+	if erow.Info.IsHexMode() {
+		s = hexVar
+	}
+	erow.updateToolbarPart(hexVar, s)
+}
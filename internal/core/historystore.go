@@ -0,0 +1,105 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/friedelschoen/glake/internal/historybuf"
+)
+
+// Undo history of files kept in the user cache directory, to be restored when the file is reopened with the same content.
+
+const maxStoredHistorySize = 1 << 20 // bytes of edits data per file
+
+type storedHistory struct {
+	Filename string
+	Hash     []byte // content hash when stored
+	History  *historybuf.Snapshot
+}
+
+func historyStoreFilename(filename string) (string, error) {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	h := sha1.Sum([]byte(filename))
+	return filepath.Join(cdir, "glake", "history", hex.EncodeToString(h[:])), nil
+}
+
+// Stores the undo history of the file rows, keyed by the current content.
+func (info *ERowInfo) storeHistory() error {
+	if !info.IsFileButNotDir() {
+		return nil
+	}
+	erow0, ok := info.FirstERow()
+	if !ok {
+		return nil
+	}
+	ta := erow0.Row.TextArea
+	fname, err := historyStoreFilename(info.Name())
+	if err != nil {
+		return err
+	}
+	hist := ta.History()
+	if hist.Empty() || ta.HexView() != nil {
+		// nothing to keep (hex view edits are not kept), don't restore an older history
+		if err := os.Remove(fname); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	b, err := ta.Bytes()
+	if err != nil {
+		return err
+	}
+
+	sh := &storedHistory{
+		Filename: info.Name(),
+		Hash:     bytesHash(b),
+		History:  hist.Snapshot(maxStoredHistorySize),
+	}
+	buf := &bytes.Buffer{}
+	if err := gob.NewEncoder(buf).Encode(sh); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fname), 0o700); err != nil {
+		return err
+	}
+	return os.WriteFile(fname, buf.Bytes(), 0o600)
+}
+
+// Restores the undo history of a just loaded file if it was stored with the same content.
+func (info *ERowInfo) restoreHistory(erow *ERow, hash []byte) error {
+	fname, err := historyStoreFilename(info.Name())
+	if err != nil {
+		return err
+	}
+	b, err := os.ReadFile(fname)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	sh := &storedHistory{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(sh); err != nil {
+		return fmt.Errorf("history: %v: %w", info.Name(), err)
+	}
+	if sh.Filename != info.Name() || !bytes.Equal(sh.Hash, hash) {
+		return nil
+	}
+	return erow.Row.TextArea.History().Restore(sh.History)
+}
+
+func (ed *Editor) storeHistories() {
+	for _, info := range ed.erowInfos {
+		if err := info.storeHistory(); err != nil {
+			ed.Error(err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package core
+
+import (
+	"github.com/friedelschoen/glake/internal/keymap"
+	"github.com/friedelschoen/glake/internal/toolbarparser"
+)
+
+// Runs a key action of a row (text area or toolbar). Returns false if the action is unknown.
+func (erow *ERow) runKeyAction(action string) bool {
+	if erowKeyAction(erow, action) {
+		return true
+	}
+	return runKeyCmd(erow.Ed, action, erow)
+}
+
+// Runs the action with the root toolbars or the global keymap, where the active row (if any) is used.
+func (ed *Editor) runKeyAction(action string) bool {
+	switch action {
+	case "cancel":
+		ed.InlineComplete.CancelAndClear()
+		ed.cancelERowInfosCmds()
+		ed.cancelERowsContentCmds()
+		ed.cancelERowsInternalCmds()
+		ed.cancelInfoFloatBox()
+		if erow, ok := ed.ActiveERow(); ok {
+			erow.Exec.Stop()
+		}
+		return true
+	case "toggleInfo":
+		ed.toggleInfoFloatBox()
+		return true
+	}
+	if erow, ok := ed.ActiveERow(); ok && erowKeyAction(erow, action) {
+		return true
+	}
+	return runKeyCmd(ed, action, nil)
+}
+
+// Row actions that can be bound to keys.
+func erowKeyAction(erow *ERow, action string) bool {
+	switch action {
+	case "save":
+		erow.SaveFileBusyCursor()
+	case "find":
+		AddFindShortcut(erow)
+	case "replace":
+		AddReplaceShortcut(erow)
+	case "newFile":
+		AddNewFileShortcut(erow)
+	case "reload":
+		AddReloadShortcut(erow)
+	case "close":
+		erow.Row.Close()
+	case "stop":
+		erow.Exec.Stop()
+	case "macroRecord":
+		if err := erow.Ed.Macros.ToggleRecording(); err != nil {
+			erow.Ed.Error(err)
+		}
+	case "macroReplay":
+		keys, err := erow.Ed.Macros.Macro("")
+		if err == nil {
+			_, err = erow.Ed.Macros.Replay(erow, keys, 1, false)
+		}
+		if err != nil {
+			erow.Ed.Error(err)
+		}
+	default:
+		return false
+	}
+	return true
+}
+
+// erow can be nil (ex: a root toolbar cmd)
+func runKeyCmd(ed *Editor, action string, optERow *ERow) bool {
+	if !keymap.IsCommand(action) {
+		return false
+	}
+	tbdata := toolbarparser.Parse(action)
+	if len(tbdata.Parts) == 0 || len(tbdata.Parts[0].Args) == 0 {
+		return false
+	}
+	internalExternalCmd(ed, tbdata.Parts[0], optERow)
+	return true
+}
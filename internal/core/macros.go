@@ -0,0 +1,233 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+// Keyboard macros: the key presses routed to the rows text areas are recorded, and can be replayed on a row.
+type Macros struct {
+	ed        *Editor
+	recording bool
+	keys      []driver.Key // keys being recorded
+	last      []driver.Key // last recorded macro
+	named     map[string][]driver.Key
+	replaying bool
+}
+
+func NewMacros(ed *Editor) *Macros {
+	return &Macros{ed: ed, named: map[string][]driver.Key{}}
+}
+
+func (m *Macros) Recording() bool {
+	return m.recording
+}
+
+func (m *Macros) StartRecording() {
+	m.recording = true
+	m.keys = nil
+	m.ed.Message("macro: recording")
+}
+
+func (m *Macros) StopRecording() error {
+	if !m.recording {
+		return fmt.Errorf("macro: not recording")
+	}
+	m.recording = false
+	if len(m.keys) == 0 {
+		return fmt.Errorf("macro: no keys recorded")
+	}
+	m.last = m.keys
+	m.keys = nil
+	m.ed.Messagef("macro: recorded %v keys", len(m.last))
+	return nil
+}
+
+func (m *Macros) ToggleRecording() error {
+	if m.recording {
+		return m.StopRecording()
+	}
+	m.StartRecording()
+	return nil
+}
+
+// Called with the input events of the rows text areas.
+func (m *Macros) record(ev any) {
+	if !m.recording || m.replaying {
+		return
+	}
+	if kd, ok := ev.(*driver.KeyDown); ok {
+		m.keys = append(m.keys, kd.Key)
+	}
+}
+
+//----------
+
+// Macro by name: the last recorded if empty, otherwise a saved macro (loaded from the config directory if needed).
+func (m *Macros) Macro(name string) ([]driver.Key, error) {
+	if name == "" {
+		if m.last == nil {
+			return nil, fmt.Errorf("macro: nothing recorded")
+		}
+		return m.last, nil
+	}
+	if keys, ok := m.named[name]; ok {
+		return keys, nil
+	}
+	keys, err := loadMacro(name)
+	if err != nil {
+		return nil, err
+	}
+	m.named[name] = keys
+	return keys, nil
+}
+
+// Saves the last recorded macro with the name to the config directory.
+func (m *Macros) Save(name string) error {
+	if m.last == nil {
+		return fmt.Errorf("macro: nothing recorded")
+	}
+	if err := saveMacro(name, m.last); err != nil {
+		return err
+	}
+	m.named[name] = m.last
+	return nil
+}
+
+// Names of the saved macros.
+func (m *Macros) Names() []string {
+	u := []string{}
+	for name := range m.named {
+		u = append(u, name)
+	}
+	if dir := macrosDir(); dir != "" {
+		des, _ := os.ReadDir(dir)
+		for _, de := range des {
+			name, ok := strings.CutSuffix(de.Name(), macroExtension)
+			if ok && m.named[name] == nil {
+				u = append(u, name)
+			}
+		}
+	}
+	sort.Strings(u)
+	return u
+}
+
+//----------
+
+// Replays the keys n times on the row text area as one undo step. With all set, repeats until the cursor stops moving or reaches the end of the text. Returns the number of replays.
+func (m *Macros) Replay(erow *ERow, keys []driver.Key, n int, all bool) (int, error) {
+	if m.replaying {
+		return 0, fmt.Errorf("macro: already replaying")
+	}
+	m.replaying = true
+	defer func() { m.replaying = false }()
+
+	ta := erow.Row.TextArea
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+
+	count := 0
+	for ; all || count < n; count++ {
+		if all && count >= maxMacroRepeat {
+			break
+		}
+		i0 := ta.CursorIndex()
+		for _, k := range keys {
+			ta.OnInputEvent(&driver.KeyDown{Key: k}, image.Point{})
+		}
+		if all {
+			if i := ta.CursorIndex(); i == i0 || i >= ta.RW().Max() {
+				count++
+				break
+			}
+		}
+	}
+	return count, nil
+}
+
+// Safety limit when repeating a macro until the cursor stops.
+const maxMacroRepeat = 100000
+
+//----------
+
+const macroExtension = ".json"
+
+func macrosDir() string {
+	cdir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(cdir, "glake", "macros")
+}
+
+func macroFilename(name string) (string, error) {
+	if name == "" || filepath.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("macro: invalid name: %q", name)
+	}
+	dir := macrosDir()
+	if dir == "" {
+		return "", errors.New("macro: missing config directory")
+	}
+	return path.Join(dir, name+macroExtension), nil
+}
+
+func loadMacro(name string) ([]driver.Key, error) {
+	filename, err := macroFilename(name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("macro: not found: %q", name)
+		}
+		return nil, err
+	}
+	names := []string{}
+	if err := json.Unmarshal(b, &names); err != nil {
+		return nil, fmt.Errorf("macro: %v: %w", name, err)
+	}
+	keys := []driver.Key{}
+	for _, kn := range names {
+		k, err := driver.ParseKey(kn)
+		if err != nil {
+			return nil, fmt.Errorf("macro: %v: %w", name, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func saveMacro(name string, keys []driver.Key) error {
+	filename, err := macroFilename(name)
+	if err != nil {
+		return err
+	}
+	// key names (ex: "ctrl-shift-V"), can be edited by hand
+	names := []string{}
+	for _, k := range keys {
+		kn := k.Name()
+		if kn == "" {
+			continue // not a keyboard key
+		}
+		names = append(names, kn)
+	}
+	b, err := json.MarshalIndent(names, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, b, 0o644)
+}
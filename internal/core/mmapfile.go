@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/ui"
+)
+
+// Files with a size above the editor mmap threshold are memory mapped and shown read-only, without reading them into memory. The toolbar gets a "MakeEditable" command to load the file into an editable buffer.
+
+const makeEditableCmd = "MakeEditable"
+
+// Returns false if the file is not mapped (small file, or mapping not supported) and should be loaded.
+func newMmapFileERow(info *ERowInfo, rowPos *ui.RowPos) (*ERow, bool) {
+	threshold := info.Ed.mmapThreshold
+	if threshold <= 0 || info.fi == nil || info.fi.Size() < threshold {
+		return nil, false
+	}
+	m, err := ioutil.OpenMmapFile(info.Name())
+	if err != nil {
+		info.Ed.Error(err)
+		return nil, false
+	}
+	info.fileData.mmap = m
+	info.fileData.fs.modTime = info.fi.ModTime()
+
+	erow := NewBasicERow(info, rowPos)
+	erow.Row.TextArea.SetRW(m)
+	erow.updateToolbarPart(makeEditableCmd, makeEditableCmd)
+	return erow, true
+}
+
+func (info *ERowInfo) IsMmapped() bool {
+	return info.fileData.mmap != nil
+}
+
+// Loads the memory mapped file into an editable buffer.
+func (info *ERowInfo) MakeEditable() error {
+	m := info.fileData.mmap
+	if m == nil {
+		return fmt.Errorf("already editable: %v", info.Name())
+	}
+	b, f, err := info.readFsFile()
+	if err != nil {
+		return err
+	}
+	info.fileData.mmap = nil
+	info.fileData.format = f
+	info.setSavedHash(info.fileData.fs.hash, len(b))
+
+	info.setRowsRW(ioutil.NewPieceTable(b))
+	for _, erow := range info.ERows {
+		erow.updateToolbarPart(makeEditableCmd, "")
+	}
+	info.SetFormat(f)
+	return info.retireMmapFile(m)
+}
+
+// Maps the file again (ex: a log file that got appended).
+func (info *ERowInfo) reloadMmapFile() error {
+	m, err := ioutil.OpenMmapFile(info.Name())
+	if err != nil {
+		return err
+	}
+	info.readFileInfo()
+	info.fileData.fs.modTime = info.fi.ModTime()
+	old := info.fileData.mmap
+	info.fileData.mmap = m
+	info.setRowsRW(m)
+	info.updateRowsStates(ui.RowStateFsDiffer, false)
+	return info.retireMmapFile(old)
+}
+
+// Keeps the mapping no longer used by the rows until the next retire (or close), unmaps the one kept before.
+func (info *ERowInfo) retireMmapFile(m *ioutil.MmapFile) error {
+	old := info.fileData.oldMmap
+	info.fileData.oldMmap = m
+	if old != nil {
+		return old.Close()
+	}
+	return nil
+}
+
+func (info *ERowInfo) closeMmapFile() {
+	for _, m := range []*ioutil.MmapFile{info.fileData.mmap, info.fileData.oldMmap} {
+		if m != nil {
+			if err := m.Close(); err != nil {
+				info.Ed.Error(err)
+			}
+		}
+	}
+	info.fileData.mmap = nil
+	info.fileData.oldMmap = nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/friedelschoen/glake/internal/language"
 	"github.com/friedelschoen/glake/internal/lsproto"
 	"github.com/friedelschoen/glake/internal/parser"
 )
@@ -23,6 +24,15 @@ type Options struct {
 	ScrollBarLeft  bool   `json:"scrollbar-left"`
 	Shadows        bool   `json:"shadows"`
 
+	Keys      map[string]map[string]string `json:"keys"` // keymap context -> key sequence -> action
+	ViMode    bool                         `json:"vimode"`
+	AutoPairs map[string]string            `json:"autopairs"` // language -> open/close runes ("*" for all languages, "" to disable)
+	Languages []*language.Language         `json:"languages"` // added to (or merged with) the built-in languages
+
+	LegacyEncoding string `json:"legacy-encoding"` // used to read files that are not valid utf-8 (ex: "windows-1252"), "" to keep them as is
+	Backup         string `json:"backup"`          // "orig" to keep "<filename>.orig" when saving, or a backups directory
+	MmapThreshold  int    `json:"mmap-threshold"`  // megabytes, files from this size are memory mapped read-only (0 disables)
+
 	SessionName string
 	Filenames   []string
 
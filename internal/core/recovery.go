@@ -0,0 +1,320 @@
+package core
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/ui"
+)
+
+// Unsaved file contents are written periodically to swap files in the user cache directory, and removed when the file is saved or closed. Swap files left by an editor that didn't exit (crash, killed, power loss) are listed in the +Recovery row at startup.
+
+const RecoveryRowName = "+Recovery"
+
+const autosaveInterval = 30 * time.Second
+
+type swapMeta struct {
+	Filename    string
+	Pid         int
+	Time        time.Time
+	CursorIndex int
+	OffsetIndex int
+}
+
+func swapDir() (string, error) {
+	cdir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(cdir, "glake", "swap"), nil
+}
+
+// Swap filename without extension (".swap" for the content, ".json" for the meta data). The pid keeps the swap files of other instances (or of a crash) from being overwritten.
+func swapFilename(filename string, pid int) (string, error) {
+	dir, err := swapDir()
+	if err != nil {
+		return "", err
+	}
+	h := sha1.Sum([]byte(filename))
+	return filepath.Join(dir, fmt.Sprintf("%v-%d", hex.EncodeToString(h[:]), pid)), nil
+}
+
+// Writes the rows content if it has unsaved changes, removes the swap file otherwise.
+func (info *ERowInfo) writeSwapFile() error {
+	if !info.IsFileButNotDir() {
+		return nil
+	}
+	erow0, ok := info.FirstERow()
+	if !ok {
+		return nil
+	}
+	if !info.HasRowState(ui.RowStateEdited) {
+		return info.removeSwapFile()
+	}
+	ta := erow0.Row.TextArea
+	b, err := info.rowsBytes(erow0)
+	if err != nil {
+		return err
+	}
+	h := bytesHash(b)
+	if bytes.Equal(h, info.fileData.swap.hash) {
+		return nil // unchanged since last write
+	}
+
+	fname, err := swapFilename(info.Name(), os.Getpid())
+	if err != nil {
+		return err
+	}
+	meta := &swapMeta{
+		Filename:    info.Name(),
+		Pid:         os.Getpid(),
+		Time:        time.Now(),
+		CursorIndex: ta.CursorIndex(),
+		OffsetIndex: ta.RuneOffset(),
+	}
+	mb, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fname), 0o700); err != nil {
+		return err
+	}
+	// content first, the meta file marks a complete swap
+	if err := ioutil.WriteFileAtomic(fname+".swap", b); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFileAtomic(fname+".json", mb); err != nil {
+		return err
+	}
+	info.fileData.swap.hash = h
+	return nil
+}
+
+func (info *ERowInfo) removeSwapFile() error {
+	if info.fileData.swap.hash == nil {
+		return nil
+	}
+	fname, err := swapFilename(info.Name(), os.Getpid())
+	if err != nil {
+		return err
+	}
+	if err := removeSwapFiles(fname); err != nil {
+		return err
+	}
+	info.fileData.swap.hash = nil
+	return nil
+}
+
+func removeSwapFiles(fname string) error {
+	for _, ext := range []string{".json", ".swap"} {
+		if err := os.Remove(fname + ext); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+//----------
+
+// Should be called under UI goroutine.
+func (ed *Editor) writeSwapFiles() {
+	for _, info := range ed.erowInfos {
+		if err := info.writeSwapFile(); err != nil {
+			ed.Error(err)
+		}
+	}
+}
+
+func (ed *Editor) removeSwapFiles() {
+	for _, info := range ed.erowInfos {
+		if err := info.removeSwapFile(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (ed *Editor) autosaveLoop() {
+	for range time.Tick(autosaveInterval) {
+		ed.UI.RunOnUIGoRoutine(ed.writeSwapFiles)
+	}
+}
+
+// Called with the value of a recovered panic. Writes the swap files before panicking again.
+func (ed *Editor) panicWriteSwapFiles(r any) {
+	log.Printf("panic: %v\n%s", r, debug.Stack())
+	func() {
+		// the editor state might be too broken to write
+		defer func() {
+			if r2 := recover(); r2 != nil {
+				log.Printf("panic while writing swap files: %v", r2)
+			}
+		}()
+		for _, info := range ed.erowInfos {
+			if err := info.writeSwapFile(); err != nil {
+				log.Println(err)
+			}
+		}
+	}()
+	panic(r)
+}
+
+//----------
+
+type recoverySwap struct {
+	fname string // without extension
+	meta  *swapMeta
+}
+
+// Swap files left by instances that are not running anymore.
+func loadRecoverySwaps() ([]*recoverySwap, error) {
+	dir, err := swapDir()
+	if err != nil {
+		return nil, err
+	}
+	des, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	u := []*recoverySwap{}
+	for _, de := range des {
+		name := de.Name()
+		if !strings.HasSuffix(name, ".json") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		fname := filepath.Join(dir, strings.TrimSuffix(name, ".json"))
+		b, err := os.ReadFile(fname + ".json")
+		if err != nil {
+			return nil, err
+		}
+		meta := &swapMeta{}
+		if err := json.Unmarshal(b, meta); err != nil {
+			return nil, fmt.Errorf("recovery: %v: %w", name, err)
+		}
+		if meta.Pid == os.Getpid() || processAlive(meta.Pid) {
+			continue
+		}
+		u = append(u, &recoverySwap{fname: fname, meta: meta})
+	}
+	sort.Slice(u, func(i, j int) bool {
+		return u[i].meta.Time.After(u[j].meta.Time)
+	})
+	return u, nil
+}
+
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// Shows the recoverable files in the +Recovery row. If onlyIfAny is set, the row is not created if there is nothing to recover.
+func ListRecovery(ed *Editor, onlyIfAny bool) {
+	swaps, err := loadRecoverySwaps()
+	if err != nil {
+		ed.Error(err)
+		return
+	}
+	if len(swaps) == 0 && onlyIfAny {
+		return
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "recoverable files: %d\n", len(swaps))
+	fmt.Fprintf(buf, "(click diff to compare with the file, restore to open the file with the unsaved content, or discard)\n")
+	for _, s := range swaps {
+		fmt.Fprintf(buf, "#%v %v %v: diff restore discard\n", filepath.Base(s.fname), strconv.Quote(s.meta.Filename), s.meta.Time.Format("2006-01-02 15:04:05"))
+	}
+
+	erow, _ := ExistingERowOrNewBasic(ed, RecoveryRowName)
+	erow.Row.TextArea.SetBytesClearPos(buf.Bytes())
+	erow.Flash()
+}
+
+// Runs a +Recovery row action (diff, restore, discard) on the entry with the id (the swap file basename, as listed).
+func RecoveryAction(ed *Editor, id string, action string) error {
+	swaps, err := loadRecoverySwaps()
+	if err != nil {
+		return err
+	}
+	k := slices.IndexFunc(swaps, func(s *recoverySwap) bool {
+		return filepath.Base(s.fname) == id
+	})
+	if k < 0 {
+		return fmt.Errorf("recovery entry not found: %v", id)
+	}
+	s := swaps[k]
+
+	switch action {
+	case "diff":
+		return s.diff(ed)
+	case "restore":
+		if err := s.restore(ed); err != nil {
+			return err
+		}
+	case "discard":
+		if err := removeSwapFiles(s.fname); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown recovery action: %v", action)
+	}
+	ListRecovery(ed, false)
+	return nil
+}
+
+func (s *recoverySwap) diff(ed *Editor) error {
+	filename := s.meta.Filename
+	info := ed.ReadERowInfo(filepath.Dir(filename))
+	if !info.IsDir() {
+		return fmt.Errorf("not a directory: %v", info.Name())
+	}
+	erow := NewBasicERow(info, ed.GoodRowPos())
+	cargs := []string{"diff", "-u", filename, s.fname + ".swap"}
+	externalCmdFromDir(erow, cargs, nil, nil)
+	return nil
+}
+
+// Opens the file and replaces its content (undoable) with the swap content. The swap files are removed since the content is now in a row.
+func (s *recoverySwap) restore(ed *Editor) error {
+	b, err := os.ReadFile(s.fname + ".swap")
+	if err != nil {
+		return err
+	}
+	info := ed.ReadERowInfo(s.meta.Filename)
+	erow, ok := info.FirstERow()
+	if !ok {
+		erow, err = NewLoadedERow(info, ed.GoodRowPos())
+		if err != nil {
+			return err
+		}
+	}
+	ta := erow.Row.TextArea
+	if info.IsHexMode() {
+		info.SetRowsBytes(b)
+	} else if err := ta.SetBytes(b); err != nil {
+		return err
+	}
+	ta.SetCursorIndex(min(s.meta.CursorIndex, len(b)))
+	ta.SetRuneOffset(min(s.meta.OffsetIndex, len(b)))
+	erow.Flash()
+	return removeSwapFiles(s.fname)
+}
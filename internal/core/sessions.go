@@ -283,6 +283,7 @@ func (state *RowState) OpenERow(ed *Editor, rowPos *ui.RowPos) (*ERow, bool, err
 	w := data.Str[arg0.End():]
 	if strings.TrimSpace(w) != "" {
 		erow.ToolbarSetStrAfterNameClearHistory(w)
+		erow.updateToolbarEncoding() // the saved one can be outdated
 	}
 
 	return erow, true, nil
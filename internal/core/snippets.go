@@ -0,0 +1,286 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/friedelschoen/glake/internal/editbuf"
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/parser"
+	"github.com/friedelschoen/glake/internal/snippet"
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+// Snippets loaded from "~/.config/glake/snippets/<language>.json", and "all.json" for every language. Files are reloaded when changed.
+type Snippets struct {
+	ed *Editor
+	mu struct {
+		sync.Mutex
+		files map[string]*snippetsFile
+	}
+}
+
+type snippetsFile struct {
+	modTime time.Time
+	defs    []*snippet.Def
+}
+
+func NewSnippets(ed *Editor) *Snippets {
+	s := &Snippets{ed: ed}
+	s.mu.files = map[string]*snippetsFile{}
+	return s
+}
+
+// Snippets of the language, followed by the ones for all languages.
+func (s *Snippets) Defs(lang string) []*snippet.Def {
+	dir := snippetsDir()
+	if dir == "" {
+		return nil
+	}
+	names := []string{"all"}
+	if lang != "" {
+		names = []string{lang, "all"}
+	}
+	u := []*snippet.Def{}
+	for _, name := range names {
+		defs, err := s.load(path.Join(dir, name+".json"))
+		if err != nil {
+			s.ed.Error(err)
+			continue
+		}
+		u = append(u, defs...)
+	}
+	return u
+}
+
+func (s *Snippets) load(filename string) ([]*snippet.Def, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			delete(s.mu.files, filename)
+			return nil, nil
+		}
+		return nil, err
+	}
+	if f, ok := s.mu.files[filename]; ok && f.modTime.Equal(fi.ModTime()) {
+		return f.defs, nil
+	}
+	defs, err := snippet.LoadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.files[filename] = &snippetsFile{modTime: fi.ModTime(), defs: defs}
+	return defs, nil
+}
+
+//----------
+
+// Expands the snippet whose prefix is just before the cursor. Returns false if there is none.
+func (s *Snippets) ExpandAtCursor(erow *ERow) bool {
+	if !erow.Info.IsFileButNotDir() {
+		return false
+	}
+	ta := erow.Row.TextArea
+	if ta.Cursor().HaveSelection() {
+		return false
+	}
+	ci := ta.CursorIndex()
+	defs := s.Defs(erow.Info.Language().Id)
+	if len(defs) == 0 {
+		return false
+	}
+
+	// limit reading
+	const pad = 100
+	a := max(0, ci-pad)
+	b, err := ta.RW().ReadFastAt(a, ci-a)
+	if err != nil {
+		return false
+	}
+
+	// longest prefix that is a whole word
+	var def *snippet.Def
+	plen := 0
+	for _, d := range defs {
+		for _, p := range d.Prefixes {
+			if len(p) <= plen || !bytes.HasSuffix(b, []byte(p)) {
+				continue
+			}
+			ru, size := utf8.DecodeLastRune(b[:len(b)-len(p)])
+			ru0, _ := utf8.DecodeRuneInString(p)
+			if size > 0 && isSnippetWordRune(ru) && isSnippetWordRune(ru0) {
+				continue
+			}
+			def, plen = d, len(p)
+		}
+	}
+	if def == nil {
+		return false
+	}
+	if err := s.Insert(erow, def, ci-plen, ci); err != nil {
+		s.ed.Error(err)
+	}
+	return true
+}
+
+// Replaces [a,b) of the row text with the snippet.
+func (s *Snippets) Insert(erow *ERow, def *snippet.Def, a, b int) error {
+	ta := erow.Row.TextArea
+	rw := ta.RW()
+
+	// indent the body lines like the current line
+	ls, err := ioutil.LineStartIndex(rw, a)
+	if err != nil {
+		return err
+	}
+	lb, err := rw.ReadFastAt(ls, a-ls)
+	if err != nil {
+		return err
+	}
+	indent := lb[:len(lb)-len(bytes.TrimLeft(lb, " \t"))]
+	body := strings.ReplaceAll(def.Body, "\n", "\n"+string(indent))
+
+	sn, err := snippet.Expand(body, s.varFn(erow, a, b))
+	if err != nil {
+		return fmt.Errorf("snippet: %v: %w", def.Name, err)
+	}
+
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	if err := editbuf.InsertSnippet(ta.EditCtx(), a, b, sn); err != nil {
+		return err
+	}
+	ta.MakeCursorVisible()
+	return nil
+}
+
+// Snippet variables, [a,b) being the text replaced by the snippet.
+func (s *Snippets) varFn(erow *ERow, a, b int) snippet.VarFn {
+	ta := erow.Row.TextArea
+	rw := ta.RW()
+	name := erow.Info.Name()
+	return func(v string) (string, bool) {
+		now := time.Now()
+		switch v {
+		case "TM_SELECTED_TEXT":
+			if sa, sb, ok := ta.Cursor().SelectionIndexes(); ok {
+				if w, err := rw.ReadFastAt(sa, sb-sa); err == nil {
+					return string(w), true
+				}
+			}
+			return "", true
+		case "TM_CURRENT_LINE":
+			ls, err := ioutil.LineStartIndex(rw, a)
+			if err != nil {
+				return "", false
+			}
+			le, _, err := ioutil.LineEndIndex(rw, b)
+			if err != nil {
+				return "", false
+			}
+			w, err := rw.ReadFastAt(ls, le-ls)
+			if err != nil {
+				return "", false
+			}
+			return strings.TrimRight(string(w), "\n"), true
+		case "TM_LINE_INDEX", "TM_LINE_NUMBER":
+			line, _, err := parser.IndexLineColumn(rw, a)
+			if err != nil {
+				return "", false
+			}
+			if v == "TM_LINE_INDEX" {
+				line--
+			}
+			return strconv.Itoa(line), true
+		case "TM_FILENAME":
+			return filepath.Base(name), true
+		case "TM_FILENAME_BASE":
+			base := filepath.Base(name)
+			return strings.TrimSuffix(base, filepath.Ext(base)), true
+		case "TM_DIRECTORY":
+			return erow.Info.Dir(), true
+		case "TM_FILEPATH":
+			return name, true
+		case "CLIPBOARD":
+			s, err := driver.GetClipboardData()
+			return s, err == nil
+		case "CURRENT_YEAR":
+			return now.Format("2006"), true
+		case "CURRENT_YEAR_SHORT":
+			return now.Format("06"), true
+		case "CURRENT_MONTH":
+			return now.Format("01"), true
+		case "CURRENT_MONTH_NAME":
+			return now.Format("January"), true
+		case "CURRENT_DATE":
+			return now.Format("02"), true
+		case "CURRENT_DAY_NAME":
+			return now.Format("Monday"), true
+		case "CURRENT_HOUR":
+			return now.Format("15"), true
+		case "CURRENT_MINUTE":
+			return now.Format("04"), true
+		case "CURRENT_SECOND":
+			return now.Format("05"), true
+		}
+		return "", false
+	}
+}
+
+// Lists the snippets available for the row.
+func (s *Snippets) List(erow *ERow) string {
+	defs := s.Defs(erow.Info.Language().Id)
+	u := []string{}
+	for _, d := range defs {
+		line := fmt.Sprintf("\t%v: %v", strings.Join(d.Prefixes, ", "), d.Name)
+		if d.Description != "" {
+			line += " - " + d.Description
+		}
+		u = append(u, line)
+	}
+	sort.Strings(u)
+	return fmt.Sprintf("snippets (%v): %d\n%v", snippetsDir(), len(u), strings.Join(u, "\n"))
+}
+
+// Snippet by name or prefix.
+func (s *Snippets) Find(erow *ERow, name string) (*snippet.Def, bool) {
+	for _, d := range s.Defs(erow.Info.Language().Id) {
+		if d.Name == name {
+			return d, true
+		}
+		for _, p := range d.Prefixes {
+			if p == name {
+				return d, true
+			}
+		}
+	}
+	return nil, false
+}
+
+//----------
+
+func snippetsDir() string {
+	cdir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(cdir, "glake", "snippets")
+}
+
+func isSnippetWordRune(ru rune) bool {
+	return unicode.IsLetter(ru) || unicode.IsDigit(ru) || ru == '_'
+}
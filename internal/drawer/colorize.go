@@ -51,6 +51,9 @@ func (c *Colorize) applyOp(op *ColorizeOp) {
 	if op.Bg != nil || op.SetNil {
 		c.d.st.curColors.bg = op.Bg
 	}
+	if op.Underline || op.SetNil {
+		c.d.st.curColors.underline = op.Underline
+	}
 	if op.ProcColor != nil {
 		st := &c.d.st.curColors
 		st.fg, st.bg = op.ProcColor(st.fg, st.bg)
@@ -74,4 +77,6 @@ type ColorizeOp struct {
 	ProcColor func(fg, bg color.Color) (fg2, bg2 color.Color)
 	Line      bool
 	SetNil    bool
+	Underline bool
+	Font      FontStyle
 }
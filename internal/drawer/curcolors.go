@@ -12,6 +12,7 @@ func (cc *CurColors) Iter() {
 	st.fg = cc.d.fg
 	st.bg = nil
 	st.lineBg = nil
+	st.underline = false
 	if !cc.d.iterNext() {
 		return
 	}
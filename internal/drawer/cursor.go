@@ -58,6 +58,11 @@ func (c *Cursor) draw2(dr image.Rectangle, col color.Color) {
 	img := c.d.st.drawR.img
 	bounds := c.d.Bounds()
 
+	if c.d.Opt.Cursor.Block {
+		c.drawBlock(dr, col)
+		return
+	}
+
 	vbw := 1 // default vertical bar width
 
 	// vertical bar
@@ -90,6 +95,30 @@ func (c *Cursor) draw2(dr image.Rectangle, col color.Color) {
 	draw.Draw(img, r2, image.NewUniform(col), image.Point{}, draw.Src)
 }
 
+func (c *Cursor) drawBlock(dr image.Rectangle, col color.Color) {
+	img := c.d.st.drawR.img
+	bounds := c.d.Bounds()
+
+	// runes without width (ex: newline, end of text)
+	if dr.Dx() < 2 {
+		dr.Max.X = dr.Min.X + c.d.LineHeight()/2
+	}
+
+	w := 1 // border width
+	if c.d.Opt.Cursor.AddedWidth > 0 {
+		w = c.d.Opt.Cursor.AddedWidth
+	}
+	sides := []image.Rectangle{
+		{dr.Min, image.Pt(dr.Max.X, dr.Min.Y+w)}, // top
+		{image.Pt(dr.Min.X, dr.Max.Y-w), dr.Max}, // bottom
+		{dr.Min, image.Pt(dr.Min.X+w, dr.Max.Y)}, // left
+		{image.Pt(dr.Max.X-w, dr.Min.Y), dr.Max}, // right
+	}
+	for _, r := range sides {
+		draw.Draw(img, r.Intersect(bounds), image.NewUniform(col), image.Point{}, draw.Src)
+	}
+}
+
 type CursorDelay struct {
 	penb image.Rectangle
 	col  color.Color
@@ -7,6 +7,7 @@ import (
 	"image/draw"
 	"log"
 
+	"github.com/alecthomas/chroma/v2"
 	"github.com/friedelschoen/glake/internal/ioutil"
 	"github.com/friedelschoen/glake/internal/mathutil"
 	"golang.org/x/image/font"
@@ -33,6 +34,8 @@ type TextDrawer struct {
 	reader ioutil.ReaderAt
 
 	fface            font.Face
+	ffaces           [4]font.Face // by FontStyle
+	tabWidth         int          // in spaces
 	lineHeight       fixed.Int52_12
 	bounds           image.Rectangle
 	firstLineOffsetX int
@@ -88,6 +91,12 @@ type TextDrawer struct {
 		}
 		syntaxH struct {
 			updated bool
+			version int // of the cache tokens used by the ops
+			cache   shCache
+		}
+		hexLayout struct {
+			fface   font.Face
+			advance fixed.Int52_12
 		}
 	}
 
@@ -106,6 +115,7 @@ type TextDrawer struct {
 			On         bool
 			Fg         color.Color
 			AddedWidth int
+			Block      bool // box around the rune (ex: vi normal mode)
 		}
 		Colorize struct {
 			Groups []*ColorizeGroup
@@ -130,8 +140,11 @@ type TextDrawer struct {
 			Group  ColorizeGroup
 		}
 		SyntaxHighlight struct {
-			On    bool
-			Group ColorizeGroup
+			On       bool
+			Group    ColorizeGroup
+			Lexer    chroma.Lexer  // nil for none, see SetSyntaxLexer
+			Style    *chroma.Style // nil for the chroma fallback, see SetSyntaxStyle
+			OnUpdate func()        // called from another goroutine when new tokens are ready (needs paint)
 		}
 	}
 }
@@ -147,6 +160,7 @@ type State struct {
 		extra         int
 		startRi       int
 		fface         font.Face
+		fontIdx       []int // colorize groups ops indexes, see fontStyle()
 	}
 	measure struct {
 		penMax fixed.Point52_12
@@ -180,8 +194,9 @@ type State struct {
 		extraLine bool
 	}
 	curColors struct {
-		fg, bg color.Color
-		lineBg color.Color
+		fg, bg    color.Color
+		lineBg    color.Color
+		underline bool
 	}
 	cursor struct {
 		delay *CursorDelay
@@ -266,8 +281,14 @@ func (d *TextDrawer) limitedReaderPadSpace(offset int) ioutil.ReaderAt {
 }
 
 func (d *TextDrawer) ContentChanged() {
+	d.ContentChangedAt(0)
+}
+
+// Same as ContentChanged, but the content before the offset didn't change (keeps the syntax highlight tokens before it).
+func (d *TextDrawer) ContentChangedAt(offset int) {
 	d.opt.measure.updated = false
 	d.opt.syntaxH.updated = false
+	d.opt.syntaxH.cache.invalidate(offset)
 	d.opt.wordH.updatedWord = false
 	d.opt.wordH.updatedOps = false
 	d.opt.parenthesisH.updated = false
@@ -279,11 +300,25 @@ func (d *TextDrawer) SetFontFace(ff font.Face) {
 		return
 	}
 	d.fface = ff
+	d.ffaces = styledFaces(ff)
+	// bold/italic runs are drawn on the same baseline and line height
 	d.lineHeight = fixed.Int52_12(d.fface.Metrics().Height << 6)
 
 	d.opt.measure.updated = false
 }
 
+// Tab stops every n spaces, the default if zero.
+func (d *TextDrawer) SetTabWidth(n int) {
+	if n <= 0 {
+		n = DefaultTabWidth
+	}
+	if n == d.tabWidth {
+		return
+	}
+	d.tabWidth = n
+	d.opt.measure.updated = false
+}
+
 func (d *TextDrawer) LineHeight() int {
 	if d.fface == nil {
 		return 0
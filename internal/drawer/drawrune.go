@@ -27,7 +27,8 @@ func (dr *DrawRune) End() {}
 func (dr *DrawRune) draw() {
 	st := &dr.d.st.drawR
 
-	pen := dr.d.iters.runeR.penBoundsRect().Min
+	penb := dr.d.iters.runeR.penBoundsRect()
+	pen := penb.Min
 
 	// draw now
 	//dr.draw2(dr.d.st.runeR.fface, pen, dr.d.st.runeR.ru, dr.d.st.curColors.fg)
@@ -36,14 +37,19 @@ func (dr *DrawRune) draw() {
 	// delayed draw
 	if st.delay != nil {
 		dr.draw2(st.delay.fface, st.delay.pen, st.delay.ru, st.delay.fg)
+		if st.delay.underline && st.delay.ru > 0 && st.delay.ru != '\n' {
+			dr.drawUnderline(st.delay.penb, st.delay.fg)
+		}
 	}
 
 	// delay drawing by one rune to allow drawing the kern bg correctly. The last position is also drawn because the runereader emits a final ru=0 at the end
 	st.delay = &DrawRuneDelay{
-		pen:   pen,
-		ru:    dr.d.st.runeR.ru,
-		fg:    dr.d.st.curColors.fg,
-		fface: dr.d.st.runeR.fface,
+		pen:       pen,
+		penb:      penb,
+		ru:        dr.d.st.runeR.ru,
+		fg:        dr.d.st.curColors.fg,
+		fface:     dr.d.st.runeR.fface,
+		underline: dr.d.st.curColors.underline,
 	}
 }
 
@@ -55,7 +61,8 @@ func (dr *DrawRune) draw2(fface font.Face, pen image.Point, ru rune, fg color.Co
 
 	//fmt.Printf("draw at %v \"%c\"\n", pen, ru)
 
-	gr, mask, maskp, _, ok := fface.Glyph(fixed.Point26_6{Y: fface.Metrics().Ascent}, ru)
+	// baseline of the regular face (consistent line height with bold/italic runs)
+	gr, mask, maskp, _, ok := fface.Glyph(fixed.Point26_6{Y: dr.d.fface.Metrics().Ascent}, ru)
 	if !ok {
 		return
 	}
@@ -74,9 +81,19 @@ func (dr *DrawRune) draw2(fface font.Face, pen image.Point, ru rune, fg color.Co
 	draw.DrawMask(dr.d.st.drawR.img, gr, image.NewUniform(fg), image.Point{}, mask, maskp, draw.Over)
 }
 
+// One pixel line below the baseline, along the rune advance.
+func (dr *DrawRune) drawUnderline(penb image.Rectangle, fg color.Color) {
+	y := penb.Min.Y + dr.d.fface.Metrics().Ascent.Ceil() + 1
+	r := image.Rect(penb.Min.X, y, penb.Max.X, y+1)
+	r = r.Intersect(dr.d.Bounds())
+	draw.Draw(dr.d.st.drawR.img, r, image.NewUniform(fg), image.Point{}, draw.Over)
+}
+
 type DrawRuneDelay struct {
-	pen   image.Point
-	ru    rune
-	fg    color.Color
-	fface font.Face
+	pen       image.Point
+	penb      image.Rectangle
+	ru        rune
+	fg        color.Color
+	fface     font.Face
+	underline bool
 }
@@ -0,0 +1,77 @@
+package drawer
+
+import "golang.org/x/image/font"
+
+// Font style of colorized text (see ColorizeOp.Font).
+type FontStyle uint8
+
+const (
+	FontRegular FontStyle = 0
+	FontBold    FontStyle = 1 << 0
+	FontItalic  FontStyle = 1 << 1
+)
+
+// Font face with bold and italic variants (nil variants use the regular face). Can be used as a regular font.Face.
+type StyledFontFace struct {
+	font.Face  // regular
+	Bold       font.Face
+	Italic     font.Face
+	BoldItalic font.Face
+}
+
+// Faces indexed by FontStyle.
+func styledFaces(ff font.Face) [4]font.Face {
+	u := [4]font.Face{ff, ff, ff, ff}
+	sf, ok := ff.(*StyledFontFace)
+	if !ok {
+		return u
+	}
+	u[FontRegular] = sf.Face
+	set := func(s FontStyle, f font.Face) {
+		if f != nil {
+			u[s] = f
+		} else {
+			u[s] = sf.Face
+		}
+	}
+	set(FontBold, sf.Bold)
+	set(FontItalic, sf.Italic)
+	set(FontBold|FontItalic, sf.BoldItalic)
+	return u
+}
+
+//----------
+
+// Font style at the current rune from the colorize ops, same precedence as the colors (later groups override). Runs in the rune reader, since the style changes the advance (all iterators measure with the same faces).
+func (rr *RuneReader) fontStyle() FontStyle {
+	st := &rr.d.st.runeR
+	groups := rr.d.Opt.Colorize.Groups
+	if len(st.fontIdx) != len(groups) {
+		st.fontIdx = make([]int, len(groups))
+	}
+	style := FontRegular
+	for k, g := range groups {
+		if g == nil || g.Off {
+			continue
+		}
+		var w *ColorizeOp
+		i := &st.fontIdx[k]
+		for j := *i; j < len(g.Ops); j++ {
+			op := g.Ops[j]
+			if op.Offset > st.ri {
+				break
+			}
+			w = op
+			*i = j
+		}
+		if w == nil {
+			continue
+		}
+		if w.Font != FontRegular {
+			style = w.Font
+		} else if w.SetNil {
+			style = FontRegular
+		}
+	}
+	return style
+}
@@ -0,0 +1,46 @@
+package drawer
+
+import (
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// Hex dump layout, used when the reader is an ioutil.HexView: runes are placed in cells of the same width to keep the columns aligned with proportional fonts, and the cells of the byte at the cursor are highlighted in both columns (instead of the word at the cursor).
+
+func (d *TextDrawer) hexView() (*ioutil.HexView, bool) {
+	hv, ok := d.reader.(*ioutil.HexView)
+	return hv, ok
+}
+
+// Advance of the widest printable ascii glyph.
+func (d *TextDrawer) hexCellAdvance(ff font.Face) fixed.Int52_12 {
+	c := &d.opt.hexLayout
+	if c.fface == ff {
+		return c.advance
+	}
+	adv := fixed.Int26_6(0)
+	for ru := rune(0x20); ru <= 0x7e; ru++ {
+		if a, ok := ff.GlyphAdvance(ru); ok && a > adv {
+			adv = a
+		}
+	}
+	c.fface = ff
+	c.advance = fixed.Int52_12(adv << 6)
+	return c.advance
+}
+
+func hexCursorOps(d *TextDrawer, hv *ioutil.HexView) []*ColorizeOp {
+	if hv.Size() == 0 {
+		return nil
+	}
+	bi, _, _ := hv.Locate(d.opt.cursor.offset)
+	hi, ai := hv.HexIndex(bi), hv.ASCIIIndex(bi)
+	fg, bg := d.Opt.WordHighlight.Fg, d.Opt.WordHighlight.Bg
+	return []*ColorizeOp{
+		{Offset: hi, Fg: fg, Bg: bg},
+		{Offset: hi + 2},
+		{Offset: ai, Fg: fg, Bg: bg},
+		{Offset: ai + 1},
+	}
+}
@@ -42,6 +42,11 @@ func (rr *RuneReader) iter2(ru rune, size int) bool {
 	st := &rr.d.st.runeR
 	st.ru = ru
 
+	// extra runes (ex: annotations) set their own face
+	if rr.isNormal() {
+		st.fface = rr.d.ffaces[rr.fontStyle()]
+	}
+
 	// add/subtract kern with previous rune
 	k := rr.d.st.runeR.fface.Kern(st.prevRu, st.ru)
 	st.kern = fixed.Int52_12(k << 6)
@@ -86,13 +91,22 @@ func (rr *RuneReader) isNormal() bool {
 	return !rr.isExtra()
 }
 
+var DefaultTabWidth = 4 // in spaces, used without an editorconfig tab width (set from the options)
+
 func (rr *RuneReader) glyphAdvance(ru rune) fixed.Int52_12 {
+	if _, ok := rr.d.hexView(); ok {
+		return rr.d.hexCellAdvance(rr.d.st.runeR.fface)
+	}
 	if ru == '\t' {
 		adv, ok := rr.d.st.runeR.fface.GlyphAdvance(' ')
 		if !ok {
 			return 0
 		}
-		return fixed.Int52_12(adv<<6) * 4
+		tw := rr.d.tabWidth
+		if tw <= 0 {
+			tw = DefaultTabWidth
+		}
+		return fixed.Int52_12(adv<<6) * fixed.Int52_12(tw)
 	}
 	adv, ok := rr.d.st.runeR.fface.GlyphAdvance(ru)
 	if !ok {
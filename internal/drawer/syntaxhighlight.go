@@ -2,10 +2,20 @@ package drawer
 
 import (
 	"image/color"
+	"sort"
+	"strings"
+	"sync"
 
 	"github.com/alecthomas/chroma/v2"
-	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/friedelschoen/glake/internal/ioutil"
+)
+
+// Syntax highlighting tokens are cached. The content is tokenised in a background goroutine, from the last checkpoint (a line start where the lexer is back in its root state) up to a bit after the visible end (shown first), and then up to the content end. Checkpoints are only taken from text lexed up to the content end: a construct cut at the end of the text (ex: an unterminated block comment) is lexed differently. Edits only invalidate the tokens after the last checkpoint before the edit, and these are still shown until the new ones are ready.
+
+const (
+	shCheckpointLines = 100  // lines between checkpoint candidates
+	shReadAhead       = 8192 // bytes tokenised after the visible end
 )
 
 func updateSyntaxHighlightOps(d *TextDrawer) {
@@ -13,13 +23,24 @@ func updateSyntaxHighlightOps(d *TextDrawer) {
 		return
 	}
 	d.Opt.SyntaxHighlight.Group.Ops = SyntaxHighlight(d)
+	d.opt.measure.updated = false // bold/italic runs can change the advance
 }
 
 func shDone(d *TextDrawer) bool {
-	if !d.Opt.SyntaxHighlight.On {
+	if _, ok := d.hexView(); ok || !d.Opt.SyntaxHighlight.On || d.Opt.SyntaxHighlight.Lexer == nil {
 		d.Opt.SyntaxHighlight.Group.Ops = nil
 		return true
 	}
+	// highlighting reads from the start, too slow for big mapped files
+	if _, ok := d.reader.(*ioutil.MmapFile); ok {
+		d.Opt.SyntaxHighlight.Group.Ops = nil
+		return true
+	}
+	// new tokens from the background goroutine
+	if v := d.opt.syntaxH.cache.getVersion(); v != d.opt.syntaxH.version {
+		d.opt.syntaxH.version = v
+		d.opt.syntaxH.updated = false
+	}
 	if d.opt.syntaxH.updated {
 		return true
 	}
@@ -27,6 +48,23 @@ func shDone(d *TextDrawer) bool {
 	return false
 }
 
+func (d *TextDrawer) SetSyntaxLexer(lexer chroma.Lexer) {
+	if lexer == d.Opt.SyntaxHighlight.Lexer {
+		return
+	}
+	d.Opt.SyntaxHighlight.Lexer = lexer
+	d.opt.syntaxH.cache.invalidate(0)
+	d.opt.syntaxH.updated = false
+}
+
+func (d *TextDrawer) SetSyntaxStyle(style *chroma.Style) {
+	if style == d.Opt.SyntaxHighlight.Style {
+		return
+	}
+	d.Opt.SyntaxHighlight.Style = style
+	d.opt.syntaxH.updated = false
+}
+
 func HexColor(in chroma.Colour) color.Color {
 	if !in.IsSet() {
 		return nil
@@ -39,45 +77,218 @@ func HexColor(in chroma.Colour) color.Color {
 	}
 }
 
+// Ops of the cached tokens in the visible range. Starts tokenising in the background if the cache doesn't reach the visible end, Opt.SyntaxHighlight.OnUpdate is called when new tokens are ready.
 func SyntaxHighlight(d *TextDrawer) []*ColorizeOp {
-	// limit reading to be able to handle big content
 	o, n, _, _ := d.visibleLen()
+	c := &d.opt.syntaxH.cache
+	lexer := d.Opt.SyntaxHighlight.Lexer
 
-	/* read up, until visible end */
-	bytes, err := d.reader.ReadFastAt(0, o+n)
-	if err != nil {
-		return nil
+	toks, end := c.visible(o, o+n)
+	if end < o+n {
+		to := min(o+n+shReadAhead, d.reader.Max())
+		c.start(d.reader, lexer, to, d.Opt.SyntaxHighlight.OnUpdate)
 	}
-	content := string(bytes)
 
-	lexer := lexers.Get("go")
-	if lexer == nil {
-		lexer = lexers.Fallback
-	}
-	// fmt.Printf("lexer: %s\n", lexer.Config().Name)
-
-	style := styles.Get("xcode")
+	style := d.Opt.SyntaxHighlight.Style
 	if style == nil {
 		style = styles.Fallback
 	}
+	bg := style.Get(chroma.Background).Background // drawn by the text area
+	ops := make([]*ColorizeOp, 0, len(toks)+1)
+	for _, t := range toks {
+		s := style.Get(t.typ)
+		op := &ColorizeOp{Offset: t.offset, Fg: HexColor(s.Colour)}
+		if s.Background != bg {
+			op.Bg = HexColor(s.Background)
+		}
+		op.Underline = s.Underline == chroma.Yes
+		if s.Bold == chroma.Yes {
+			op.Font |= FontBold
+		}
+		if s.Italic == chroma.Yes {
+			op.Font |= FontItalic
+		}
+		ops = append(ops, op)
+	}
+	// not tokenised yet (and no old tokens)
+	if end < o+n && (len(toks) == 0 || toks[len(toks)-1].offset < end) {
+		ops = append(ops, &ColorizeOp{Offset: end})
+	}
+	return ops
+}
 
-	tokens, _ := lexer.Tokenise(nil, content)
+//----------
 
-	ops := make([]*ColorizeOp, 0)
+type shToken struct {
+	offset int
+	typ    chroma.TokenType
+}
 
-	off := 0
-	for {
-		token := tokens()
-		if token == chroma.EOF {
-			break
+type shCache struct {
+	sync.Mutex
+	toks    []shToken // ordered by offset
+	end     int       // tokens are valid up to here, the others are from before an edit
+	cps     []int     // checkpoints, ordered, the first is always 0
+	gen     int       // incremented on invalidation, discards the running job result
+	version int       // incremented when tokens are added
+	running bool
+}
+
+func (c *shCache) getVersion() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.version
+}
+
+// Invalidates the tokens after the last checkpoint before the offset.
+func (c *shCache) invalidate(offset int) {
+	c.Lock()
+	defer c.Unlock()
+	c.gen++
+	k := sort.Search(len(c.cps), func(i int) bool { return c.cps[i] > offset })
+	c.cps = c.cps[:k]
+	cp := 0
+	if k > 0 {
+		cp = c.cps[k-1]
+	}
+	c.end = min(c.end, cp)
+}
+
+// Tokens in [a,b) (starting with the one containing a), and the offset the tokens are valid up to.
+func (c *shCache) visible(a, b int) ([]shToken, int) {
+	c.Lock()
+	defer c.Unlock()
+	i := sort.Search(len(c.toks), func(i int) bool { return c.toks[i].offset > a })
+	if i > 0 {
+		i--
+	}
+	j := sort.Search(len(c.toks), func(i int) bool { return c.toks[i].offset >= b })
+	u := make([]shToken, j-i)
+	copy(u, c.toks[i:j])
+	return u, c.end
+}
+
+// Tokenises from the last checkpoint up to the offset, and then up to the content end, in the background. The content is read here (ui goroutine), the reader is not safe to use concurrently with writes.
+func (c *shCache) start(r ioutil.ReaderAt, lexer chroma.Lexer, to int, onUpdate func()) {
+	c.Lock()
+	defer c.Unlock()
+	if c.running {
+		return // a new job is started on the next update
+	}
+	cp := 0
+	if len(c.cps) > 0 {
+		cp = c.cps[len(c.cps)-1]
+	}
+	b, err := ioutil.ReadFastFull(ioutil.NewLimitedReaderAt(r, r.Min()+cp, r.Max()))
+	if err != nil {
+		return
+	}
+	text := string(b) // copy
+	c.running = true
+	gen := c.gen
+	stop := func() bool {
+		c.Lock()
+		defer c.Unlock()
+		return gen != c.gen
+	}
+	update := func(toks []shToken, cps []int, end int, done bool) {
+		c.Lock()
+		if done {
+			c.running = false
+		}
+		if gen == c.gen && toks != nil {
+			j := sort.Search(len(c.toks), func(i int) bool { return c.toks[i].offset >= cp })
+			c.toks = append(c.toks[:j], toks...)
+			c.cps = append(c.cps, cps...)
+			if len(c.cps) == 0 || c.cps[0] != 0 {
+				c.cps = append([]int{0}, c.cps...)
+			}
+			c.end = end
 		}
-		s := style.Get(token.Type)
-		ops = append(ops, &ColorizeOp{
-			Offset: off,
-			Fg:     HexColor(s.Colour),
-		})
-		off += len(token.Value)
+		c.version++
+		c.Unlock()
+
+		if onUpdate != nil {
+			onUpdate()
+		}
+	}
+	go func() {
+		// visible part first, no checkpoints
+		if n := to - cp; n < len(text) {
+			toks := shTokens(lexer, text[:max(n, 0)], cp, stop)
+			if toks == nil {
+				update(nil, nil, 0, true)
+				return
+			}
+			update(toks, nil, to, false)
+		}
+		toks, cps := shTokenise(lexer, text, cp, stop)
+		update(toks, cps, cp+len(text), true)
+	}()
+}
+
+// Tokens of the text (at the content offset base) up to the content end, and the checkpoints found. Nil if stopped.
+func shTokenise(lexer chroma.Lexer, text string, base int, stop func() bool) ([]shToken, []int) {
+	toks := shTokens(lexer, text, base, stop)
+	if toks == nil {
+		return nil, nil
 	}
 
-	return ops
+	cps := []int{}
+	lines := 0
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\n' {
+			continue
+		}
+		lines++
+		if lines%shCheckpointLines != 0 || i+1 >= len(text) {
+			continue
+		}
+		if shIsCheckpoint(lexer, text, base, toks, i+1) {
+			cps = append(cps, base+i+1)
+		}
+	}
+	// the tokens after the last checkpoint are kept, but are re-tokenised when continuing (the lexer state is unknown at the end)
+	return toks, cps
+}
+
+// Nil if stopped (checked every few tokens, stop can be nil).
+func shTokens(lexer chroma.Lexer, text string, base int, stop func() bool) []shToken {
+	it, err := lexer.Tokenise(&chroma.TokeniseOptions{State: "root"}, text)
+	if err != nil {
+		return nil
+	}
+	toks := []shToken{}
+	off := base
+	for t := it(); t != chroma.EOF; t = it() {
+		if len(t.Value) == 0 {
+			continue
+		}
+		toks = append(toks, shToken{off, t.Type})
+		off += len(t.Value)
+		if stop != nil && len(toks)%4096 == 0 && stop() {
+			return nil
+		}
+	}
+	return toks
+}
+
+// The line start is a checkpoint if a token starts there, and tokenising the line from the root state gives the same tokens.
+func shIsCheckpoint(lexer chroma.Lexer, text string, base int, toks []shToken, i int) bool {
+	k := sort.Search(len(toks), func(j int) bool { return toks[j].offset >= base+i })
+	if k >= len(toks) || toks[k].offset != base+i {
+		return false
+	}
+	e := len(text)
+	if j := strings.IndexByte(text[i:], '\n'); j >= 0 {
+		e = i + j + 1
+	}
+	u := shTokens(lexer, text[i:e], base+i, nil)
+	for _, t := range u {
+		if k >= len(toks) || toks[k] != t {
+			return false
+		}
+		k++
+	}
+	return true
 }
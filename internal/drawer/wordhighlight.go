@@ -12,6 +12,10 @@ func updateWordHighlightWord(d *TextDrawer) {
 		return
 	}
 
+	if _, ok := d.hexView(); ok {
+		return
+	}
+
 	if d.opt.wordH.updatedWord {
 		return
 	}
@@ -39,6 +43,10 @@ func updateWordHighlightOps(d *TextDrawer) {
 	}
 	d.opt.wordH.updatedOps = true
 
+	if hv, ok := d.hexView(); ok {
+		d.Opt.WordHighlight.Group.Ops = hexCursorOps(d, hv)
+		return
+	}
 	d.Opt.WordHighlight.Group.Ops = wordHOps(d)
 }
 
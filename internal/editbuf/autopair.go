@@ -0,0 +1,216 @@
+package editbuf
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/friedelschoen/glake/internal/ioutil"
+)
+
+// Auto-pairing of brackets and quotes.
+type AutoPair struct {
+	Pairs string       // open/close runes in sequence (ex: "()[]{}\"\"")
+	Lexer chroma.Lexer // to not pair inside strings and comments, can be nil
+}
+
+// Pairs by language (lowercase chroma lexer name), "" for the other languages.
+var DefaultAutoPairs = map[string]string{
+	"":            "()[]{}\"\"''",
+	"go":          "()[]{}\"\"''``",
+	"javascript":  "()[]{}\"\"''``",
+	"typescript":  "()[]{}\"\"''``",
+	"markdown":    "()[]{}\"\"``",
+	"rust":        "()[]{}\"\"", // lifetimes
+	"common lisp": "()[]{}\"\"",
+	"scheme":      "()[]{}\"\"",
+	"clojure":     "()[]{}\"\"",
+	"emacslisp":   "()[]{}\"\"",
+	"plaintext":   "()[]{}\"\"",
+}
+
+func (ap *AutoPair) closeOf(open rune) (rune, bool) {
+	rs := []rune(ap.Pairs)
+	for i := 0; i+1 < len(rs); i += 2 {
+		if rs[i] == open {
+			return rs[i+1], true
+		}
+	}
+	return 0, false
+}
+
+func (ap *AutoPair) isClose(ru rune) bool {
+	rs := []rune(ap.Pairs)
+	for i := 1; i < len(rs); i += 2 {
+		if rs[i] == ru {
+			return true
+		}
+	}
+	return false
+}
+
+//----------
+
+// Inserts a typed rune handling the pairs. Returns false if the rune should be inserted as usual.
+func AutoPairInsert(ctx *EditorBuffer, ru rune) (bool, error) {
+	ap := ctx.AutoPair
+	if ap == nil {
+		return false, nil
+	}
+
+	// wrap selection
+	if a, b, ok := ctx.C.SelectionIndexes(); ok {
+		cl, ok := ap.closeOf(ru)
+		if !ok {
+			return false, nil
+		}
+		if err := ctx.RW.OverwriteAt(b, 0, []byte(string(cl))); err != nil {
+			return true, err
+		}
+		s := string(ru)
+		if err := ctx.RW.OverwriteAt(a, 0, []byte(s)); err != nil {
+			return true, err
+		}
+		ctx.C.SetSelection(a+len(s), b+len(s))
+		return true, nil
+	}
+
+	ci := ctx.C.Index()
+	next, _, nextErr := ioutil.ReadRuneAt(ctx.RW, ci)
+	prev, _, prevErr := ioutil.ReadLastRuneAt(ctx.RW, ci)
+
+	// skip over an existing closer
+	if ap.isClose(ru) && nextErr == nil && next == ru {
+		ctx.C.SetIndex(ci + len(string(ru)))
+		return true, nil
+	}
+
+	cl, ok := ap.closeOf(ru)
+	if !ok {
+		return false, nil
+	}
+	// only pair before spaces, closers and punctuation
+	if nextErr == nil && !unicode.IsSpace(next) && !ap.isClose(next) && !strings.ContainsRune(",;:.", next) {
+		return false, nil
+	}
+	// quotes: not after a word (ex: "don't")
+	if cl == ru && prevErr == nil && (unicode.IsLetter(prev) || unicode.IsDigit(prev) || prev == ru) {
+		return false, nil
+	}
+	if inStringOrComment(ctx, ci) {
+		return false, nil
+	}
+
+	s := string(ru) + string(cl)
+	if err := ctx.RW.OverwriteAt(ci, 0, []byte(s)); err != nil {
+		return true, err
+	}
+	ctx.C.SetIndex(ci + len(string(ru)))
+	return true, nil
+}
+
+// Backspace that deletes both runes of an empty pair.
+func AutoPairBackspace(ctx *EditorBuffer) error {
+	ap := ctx.AutoPair
+	if ap == nil || ctx.C.HaveSelection() {
+		return Backspace(ctx)
+	}
+	ci := ctx.C.Index()
+	prev, psize, err := ioutil.ReadLastRuneAt(ctx.RW, ci)
+	if err != nil {
+		return Backspace(ctx)
+	}
+	next, nsize, err := ioutil.ReadRuneAt(ctx.RW, ci)
+	if err != nil {
+		return Backspace(ctx)
+	}
+	if cl, ok := ap.closeOf(prev); !ok || cl != next {
+		return Backspace(ctx)
+	}
+	if err := ctx.RW.OverwriteAt(ci-psize, psize+nsize, nil); err != nil {
+		return err
+	}
+	ctx.C.SetIndex(ci - psize)
+	return nil
+}
+
+// Uses the lexer tokens up to the end of the line of index i.
+func inStringOrComment(ctx *EditorBuffer, i int) bool {
+	lexer := ctx.AutoPair.Lexer
+	if lexer == nil {
+		return false
+	}
+	// limit reading to be able to handle big content
+	const pad = 32 * 1024
+	start := max(0, i-pad)
+	if start > 0 {
+		// start at a line start: not inside a rune, and less likely inside a string or comment
+		k, newline, err := ioutil.LineEndIndex(ioutil.NewLimitedReaderAt(ctx.RW, start, i), start)
+		if err != nil {
+			return false
+		}
+		if newline {
+			start = k
+		} else {
+			// long line, at least start at a rune
+			for ; start < i; start++ {
+				if b, err := ctx.RW.ReadFastAt(start, 1); err != nil || utf8.RuneStart(b[0]) {
+					break
+				}
+			}
+		}
+	}
+	end, _, err := ioutil.LineEndIndex(ctx.RW, i)
+	if err != nil {
+		return false
+	}
+	b, err := ctx.RW.ReadFastAt(start, end-start)
+	if err != nil {
+		return false
+	}
+	it, err := lexer.Tokenise(nil, string(b))
+	if err != nil {
+		return false
+	}
+	k := i - start
+	for off := 0; ; {
+		tok := it()
+		if tok == chroma.EOF {
+			return false
+		}
+		e := off + len(tok.Value)
+		isComment := tok.Type.InCategory(chroma.Comment)
+		isString := tok.Type.InSubCategory(chroma.LiteralString)
+		switch {
+		case k <= off || k > e:
+		case k < e:
+			return isComment || isString
+		case isComment:
+			return true
+		case isString:
+			// at the end of an unterminated string
+			v := []rune(tok.Value)
+			return len(v) < 2 || v[0] != v[len(v)-1]
+		}
+		off = e
+	}
+}
+
+//----------
+
+// Moves the cursor to the bracket matching the one at (or before) the cursor.
+func JumpToMatchingBracket(ctx *EditorBuffer) error {
+	ci := ctx.C.Index()
+	points, ok := ioutil.MatchParenthesis(ctx.RW, ci, 1024*1024)
+	if !ok || len(points) != 2 {
+		return fmt.Errorf("no matching bracket")
+	}
+	j := points[1]
+	if ci == points[1] || ci == points[1]+1 {
+		j = points[0]
+	}
+	ctx.C.SetIndexSelectionOff(j)
+	return nil
+}
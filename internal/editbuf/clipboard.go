@@ -0,0 +1,151 @@
+package editbuf
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+// Recent copies and cuts (kill ring), shared by all the text areas.
+var Clipboard = NewClipboardHistory(50)
+
+type ClipboardHistory struct {
+	sync.Mutex
+	max     int
+	seq     int
+	entries []*ClipboardEntry // most recent first
+
+	// last paste, allows replacing it with an older entry
+	paste struct {
+		ctx  *EditorBuffer
+		a, b int
+		id   int
+	}
+}
+
+type ClipboardEntry struct {
+	Id   int // stable while in the history
+	Text string
+}
+
+func NewClipboardHistory(max int) *ClipboardHistory {
+	return &ClipboardHistory{max: max}
+}
+
+// Adds s as the most recent entry. An existing entry with the same text is moved to the front.
+func (ch *ClipboardHistory) Add(s string) {
+	ch.Lock()
+	defer ch.Unlock()
+	ch.add(s)
+}
+
+func (ch *ClipboardHistory) add(s string) *ClipboardEntry {
+	if s == "" {
+		return nil
+	}
+	for i, e := range ch.entries {
+		if e.Text == s {
+			copy(ch.entries[1:i+1], ch.entries[:i])
+			ch.entries[0] = e
+			return e
+		}
+	}
+	ch.seq++
+	e := &ClipboardEntry{Id: ch.seq, Text: s}
+	ch.entries = append([]*ClipboardEntry{e}, ch.entries...)
+	if len(ch.entries) > ch.max {
+		ch.entries = ch.entries[:ch.max]
+	}
+	return e
+}
+
+// Copy of the entries, most recent first.
+func (ch *ClipboardHistory) Entries() []ClipboardEntry {
+	ch.Lock()
+	defer ch.Unlock()
+	u := make([]ClipboardEntry, 0, len(ch.entries))
+	for _, e := range ch.entries {
+		u = append(u, *e)
+	}
+	return u
+}
+
+func (ch *ClipboardHistory) Entry(id int) (string, bool) {
+	ch.Lock()
+	defer ch.Unlock()
+	for _, e := range ch.entries {
+		if e.Id == id {
+			return e.Text, true
+		}
+	}
+	return "", false
+}
+
+// Keeps the text just inserted before the cursor to be able to cycle through older entries.
+func (ch *ClipboardHistory) pasted(ctx *EditorBuffer, s string) {
+	ch.Lock()
+	defer ch.Unlock()
+	if e := ch.add(s); e != nil {
+		ci := ctx.C.Index()
+		ch.setPaste(ctx, ci-len(s), ci, e.Id)
+	}
+}
+
+func (ch *ClipboardHistory) setPaste(ctx *EditorBuffer, a, b, id int) {
+	ch.paste.ctx = ctx
+	ch.paste.a, ch.paste.b = a, b
+	ch.paste.id = id
+}
+
+//----------
+
+// Sets the system clipboard and keeps the text in the history.
+func setClipboard(s string) {
+	Clipboard.Add(s)
+	driver.SetClipboardData(s)
+}
+
+// Inserts a clipboard history entry at the cursor (replaces the selection).
+func PasteEntry(ctx *EditorBuffer, id int) error {
+	s, ok := Clipboard.Entry(id)
+	if !ok {
+		return fmt.Errorf("clipboard entry not found: %v", id)
+	}
+	if err := InsertString(ctx, s); err != nil {
+		return err
+	}
+	Clipboard.pasted(ctx, s)
+	return nil
+}
+
+// Replaces the text just pasted with the previous (older) entry of the clipboard history, wrapping around.
+func PastePrevious(ctx *EditorBuffer) error {
+	ch := Clipboard
+	ch.Lock()
+	defer ch.Unlock()
+
+	p := &ch.paste
+	k := -1
+	for i, e := range ch.entries {
+		if e.Id == p.id {
+			k = i
+		}
+	}
+	if p.ctx != ctx || k < 0 || ctx.C.HaveSelection() || ctx.C.Index() != p.b {
+		return fmt.Errorf("pastePrevious: not after a paste")
+	}
+	// the pasted text must be unchanged
+	b, err := ctx.RW.ReadFastAt(p.a, p.b-p.a)
+	if err != nil || string(b) != ch.entries[k].Text {
+		return fmt.Errorf("pastePrevious: not after a paste")
+	}
+
+	e := ch.entries[(k+1)%len(ch.entries)]
+	if err := ctx.RW.OverwriteAt(p.a, p.b-p.a, []byte(e.Text)); err != nil {
+		return err
+	}
+	ctx.C.SetIndex(p.a + len(e.Text))
+	ch.setPaste(ctx, p.a, p.a+len(e.Text), e.Id)
+	return nil
+}
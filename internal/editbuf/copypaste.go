@@ -8,7 +8,7 @@ import (
 
 func Copy(ctx *EditorBuffer) error {
 	if b, ok := ctx.Selection(); ok {
-		driver.SetClipboardData(string(b))
+		setClipboard(string(b))
 	}
 	return nil
 }
@@ -21,5 +21,8 @@ func Paste(ctx *EditorBuffer) {
 	}
 	if err := InsertString(ctx, s); err != nil {
 		ctx.Fns.Error(fmt.Errorf("rwedit.paste: insertstring: %w", err))
+		return
 	}
+	// also keeps copies from other applications in the history
+	Clipboard.pasted(ctx, s)
 }
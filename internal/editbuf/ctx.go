@@ -5,21 +5,37 @@ import (
 	"image"
 
 	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/keymap"
 )
 
 //godebug:annotatefile
 
 type EditorBuffer struct {
-	RW  ioutil.ReadWriterAt
-	C   Cursor
-	Fns CtxFns
+	RW   ioutil.ReadWriterAt
+	C    Cursor
+	Fns  CtxFns
+	Keys keymap.Context  // keymap used on key input
+	Seq  keymap.Seq      // pending keys of a sequence
+	Vi   *Vi             // modal editing state, nil if not enabled
+	Hex  *ioutil.HexView // set if RW is a hex view (overwrite editing), nil otherwise
+
+	AutoPair   *AutoPair       // nil if not enabled
+	Snippet    *SnippetSession // snippet placeholders being edited, can be nil
+	IndentUnit string          // inserted by TabRight, a tab if empty (ex: "    ")
 }
 
 func NewEditorBuffer() *EditorBuffer {
-	ctx := &EditorBuffer{C: &SimpleCursor{}, Fns: nil}
+	ctx := &EditorBuffer{C: &SimpleCursor{}, Fns: nil, Keys: keymap.TextArea}
 	return ctx
 }
 
+func (ctx *EditorBuffer) indentUnit() string {
+	if ctx.IndentUnit == "" {
+		return "\t"
+	}
+	return ctx.IndentUnit
+}
+
 func (ctx *EditorBuffer) CursorSelectionLinesIndexes() (int, int, bool, error) {
 	a, b, ok := ctx.C.SelectionIndexes()
 	if !ok {
@@ -63,4 +79,6 @@ type CtxFns interface {
 
 	Undo() error
 	Redo() error
+
+	RunKeyAction(action string) bool // actions unknown to editbuf, returns handled
 }
@@ -1,7 +1,5 @@
 package editbuf
 
-import "github.com/friedelschoen/glake/internal/ui/driver"
-
 func Cut(ctx *EditorBuffer) error {
 	a, b, ok := ctx.C.SelectionIndexes()
 	if !ok {
@@ -12,7 +10,7 @@ func Cut(ctx *EditorBuffer) error {
 	if err != nil {
 		return err
 	}
-	driver.SetClipboardData(string(s))
+	setClipboard(string(s))
 
 	if err := ctx.RW.OverwriteAt(a, b-a, nil); err != nil {
 		return err
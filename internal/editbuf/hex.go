@@ -0,0 +1,210 @@
+package editbuf
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/keymap"
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+//godebug:annotatefile
+
+// Overwrite input handler for hex views: typed hex digits replace the nibble at the cursor, typed chars in the ascii column replace the byte. Backspace moves to the previous nibble/char, tab switches between the hex and ascii columns. Non key events (ex: mouse) are handled by HandleInput.
+func HandleHexInput(ctx *EditorBuffer, ev any) (bool, error) {
+	kd, ok := ev.(*driver.KeyDown)
+	if !ok || ctx.Hex == nil {
+		return HandleInput(ctx, ev)
+	}
+	in := &Input{ctx, ev}
+	actions, ok := keymap.Get(ctx.Keys).Lookup(&ctx.Seq, kd.Key)
+	if !ok {
+		// prefix of a broken sequence
+		for _, a := range actions {
+			if _, err := hexKeyAction(in, a); err != nil {
+				return true, err
+			}
+		}
+		if kd.Key.Rune == 0 {
+			return len(actions) > 0, nil
+		}
+		err := HexOverwrite(ctx, kd.Key.Rune)
+		if err == nil {
+			ctx.Fns.MakeIndexVisible(ctx.C.Index())
+		}
+		return true, err
+	}
+	handled := true // pending key sequence if there are no actions
+	for _, a := range actions {
+		h, err := hexKeyAction(in, a)
+		if err != nil {
+			return true, err
+		}
+		handled = h
+	}
+	return handled, nil
+}
+
+func hexKeyAction(in *Input, action string) (bool, error) {
+	switch action {
+	case "backspace":
+		hexBackspace(in.ctx)
+		return true, nil
+	case "tabRight", "tabLeft":
+		hexSwitchColumn(in.ctx)
+		return true, nil
+	}
+	return in.keyAction(action)
+}
+
+// Overwrites the nibble (hex column) or byte (ascii column) at the cursor, and moves the cursor to the next one.
+func HexOverwrite(ctx *EditorBuffer, ru rune) error {
+	hv := ctx.Hex
+	if hv.Size() == 0 {
+		return fmt.Errorf("hex: no bytes to overwrite")
+	}
+	ci := ctx.C.Index()
+	if a, _, ok := ctx.C.SelectionIndexes(); ok {
+		ci = a
+		ctx.C.SetSelectionOff()
+	}
+	bi, ascii, nibble := hv.Locate(ci)
+	last := bi == hv.Size()-1
+
+	if !ascii {
+		if _, ok := ioutil.HexDigit(ru); !ok {
+			return fmt.Errorf("hex: not a hex digit: %q", ru)
+		}
+		i := hv.HexIndex(bi) + nibble
+		if err := ctx.RW.OverwriteAt(i, 1, []byte(strings.ToLower(string(ru)))); err != nil {
+			return err
+		}
+		switch {
+		case nibble == 0:
+			ctx.C.SetIndex(i + 1)
+		case !last:
+			ctx.C.SetIndex(hv.HexIndex(bi + 1))
+		}
+		return nil
+	}
+
+	if ru < 0x20 || ru > 0x7e {
+		return fmt.Errorf("hex: not a printable ascii char: %q", ru)
+	}
+	// also write the hex cell, so undoing restores the byte (the ascii column doesn't show all bytes)
+	hi, ai := hv.HexIndex(bi), hv.ASCIIIndex(bi)
+	b, err := ioutil.ReadFastFull(ioutil.NewLimitedReaderAt(ctx.RW, hi, ai+1))
+	if err != nil {
+		return err
+	}
+	p := append([]byte{}, b...)
+	copy(p, hex.EncodeToString([]byte{byte(ru)}))
+	p[len(p)-1] = byte(ru)
+	if err := ctx.RW.OverwriteAt(hi, len(p), p); err != nil {
+		return err
+	}
+	if !last {
+		ai = hv.ASCIIIndex(bi + 1)
+	}
+	ctx.C.SetIndex(ai)
+	return nil
+}
+
+func hexBackspace(ctx *EditorBuffer) {
+	hv := ctx.Hex
+	bi, ascii, nibble := hv.Locate(ctx.C.Index())
+	switch {
+	case ascii:
+		ctx.C.SetIndexSelectionOff(hv.ASCIIIndex(max(0, bi-1)))
+	case nibble == 1:
+		ctx.C.SetIndexSelectionOff(hv.HexIndex(bi))
+	case bi > 0:
+		ctx.C.SetIndexSelectionOff(hv.HexIndex(bi-1) + 1)
+	}
+}
+
+func hexSwitchColumn(ctx *EditorBuffer) {
+	hv := ctx.Hex
+	bi, ascii, _ := hv.Locate(ctx.C.Index())
+	i := hv.ASCIIIndex(bi)
+	if ascii {
+		i = hv.HexIndex(bi)
+	}
+	ctx.C.SetIndexSelectionOff(i)
+	ctx.Fns.MakeIndexVisible(i)
+}
+
+//----------
+
+// Finds the bytes in the hex view data from the cursor, wrapping around. The match is selected in the hex column.
+func HexFind(cctx context.Context, ctx *EditorBuffer, b []byte, reverse bool) (bool, error) {
+	hv := ctx.Hex
+	if len(b) == 0 || hv.Size() == 0 {
+		return false, nil
+	}
+	ci, sel := ctx.C.Index(), false
+	if a, _, ok := ctx.C.SelectionIndexes(); ok {
+		ci, sel = a, true
+	}
+	start, _, _ := hv.Locate(ci)
+
+	data := hv.Data()
+	m := data.Min()
+	opt := &ioutil.IndexOpt{} // exact bytes
+	var i int
+	var err error
+	if !reverse {
+		if sel {
+			start++ // next match
+		}
+		i, _, err = ioutil.IndexCtx(cctx, data, m+min(start, hv.Size()), b, opt)
+		if err == nil && i < 0 {
+			e := min(m+start+len(b)-1, data.Max())
+			rd := ioutil.NewLimitedReaderAt(data, m, e)
+			i, _, err = ioutil.IndexCtx(cctx, rd, m, b, opt)
+		}
+	} else {
+		i, _, err = ioutil.LastIndexCtx(cctx, data, m+start, b, opt)
+		if err == nil && i < 0 {
+			s, e := max(m, m+start-len(b)+1), data.Max()
+			rd := ioutil.NewLimitedReaderAt(data, s, e)
+			i, _, err = ioutil.LastIndexCtx(cctx, rd, e, b, opt)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return false, nil
+		}
+		return false, err
+	}
+	if i < 0 {
+		return false, nil
+	}
+	i -= m
+	a, e := hv.HexIndex(i), hv.HexIndex(i+len(b)-1)+2
+	if reverse {
+		ctx.C.SetSelection(e, a) // cursor at start to allow searching next
+	} else {
+		ctx.C.SetSelection(a, e) // cursor at end to allow searching next
+	}
+	return true, nil
+}
+
+// Parses hex bytes (ex: "7f454c46", "7f 45 4c 46", "0x7f 0x45"). Returns false if the string is not hex.
+func ParseHexBytes(s string) ([]byte, bool) {
+	w := []string{}
+	for _, f := range strings.Fields(s) {
+		f = strings.TrimPrefix(strings.TrimPrefix(f, "0x"), "0X")
+		w = append(w, f)
+	}
+	b, err := hex.DecodeString(strings.Join(w, ""))
+	if err != nil || len(b) == 0 {
+		return nil, false
+	}
+	return b, true
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"io"
 
+	"github.com/friedelschoen/glake/internal/keymap"
 	"github.com/friedelschoen/glake/internal/ui/driver"
 )
 
@@ -103,136 +104,52 @@ func (in *Input) onMouseClick(ev *driver.MouseClick) (bool, error) {
 }
 
 func (in *Input) onKeyDown(ev *driver.KeyDown) (bool, error) {
-	var err error
-	makeCursorVisible := func() {
+	actions, ok := keymap.Get(in.ctx.Keys).Lookup(&in.ctx.Seq, ev.Key)
+	if !ok {
+		// prefix of a broken sequence
+		if _, err := in.keyActions(actions); err != nil {
+			return true, err
+		}
+		if ev.Key.Rune == 0 {
+			return len(actions) > 0, nil
+		}
+		handled, err := AutoPairInsert(in.ctx, ev.Key.Rune)
+		if !handled {
+			err = InsertString(in.ctx, string(ev.Key.Rune))
+		}
 		if err == nil {
 			in.ctx.Fns.MakeIndexVisible(in.ctx.C.Index())
 		}
+		return true, err
 	}
+	if len(actions) == 0 {
+		return true, nil // pending key sequence
+	}
+	return in.keyActions(actions)
+}
 
-	switch {
-	case ev.Key.Is("C-S-Right"):
-		err = MoveCursorJumpRight(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("C-Right"):
-		err = MoveCursorJumpRight(in.ctx, false)
-		makeCursorVisible()
-	case ev.Key.Is("S-Right"):
-		err = MoveCursorRight(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("Right"):
-		err = MoveCursorRight(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("C-S-Left"):
-		err = MoveCursorJumpLeft(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("C-Left"):
-		err = MoveCursorJumpLeft(in.ctx, false)
-		makeCursorVisible()
-	case ev.Key.Is("S-Left"):
-		err = MoveCursorLeft(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("Left"):
-		err = MoveCursorLeft(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("C-A-Up"):
-		err = MoveLineUp(in.ctx)
-		makeCursorVisible()
-	case ev.Key.Is("C-S-Up"), ev.Key.Is("S-Up"):
-		MoveCursorUp(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("Up"):
-		MoveCursorUp(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("C-A-Down"):
-		err = MoveLineDown(in.ctx)
-		makeCursorVisible()
-	case ev.Key.Is("C-S-Down"), ev.Key.Is("S-Down"):
-		MoveCursorDown(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("Down"):
-		MoveCursorDown(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("C-S-Home"):
-		StartOfString(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("C-Home"):
-		StartOfString(in.ctx, false)
-		makeCursorVisible()
-	case ev.Key.Is("S-Home"):
-		err = StartOfLine(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("Home"):
-		err = StartOfLine(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("C-S-End"):
-		EndOfString(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("C-End"):
-		EndOfString(in.ctx, false)
-		makeCursorVisible()
-	case ev.Key.Is("S-End"):
-		err = EndOfLine(in.ctx, true)
-		makeCursorVisible()
-	case ev.Key.Is("End"):
-		err = EndOfLine(in.ctx, false)
-		makeCursorVisible()
-
-	case ev.Key.Is("Backspace"):
-		err = Backspace(in.ctx)
-		makeCursorVisible()
-
-	case ev.Key.Is("Delete"):
-		err = Delete(in.ctx)
-		makeCursorVisible() // TODO: on delete?
-
-	case ev.Key.Is("Return"):
-		err = AutoIndent(in.ctx)
-		makeCursorVisible()
-
-	case ev.Key.Is("S-Tab"):
-		// TODO: using KSymTabLeft case, this still needed?
-		err = TabLeft(in.ctx)
-		makeCursorVisible()
-	case ev.Key.Is("Tab"):
-		err = TabRight(in.ctx)
-		makeCursorVisible()
-
-	case ev.Key.Is("PageUp"):
-		PageUp(in.ctx, true)
-	case ev.Key.Is("PageDown"):
-		PageUp(in.ctx, false)
-
-	case ev.Key.Is("ctrl-D"):
-		err = Comment(in.ctx)
-	case ev.Key.Is("ctrl-C"):
-		err = Copy(in.ctx)
-	case ev.Key.Is("ctrl-X"):
-		err = Cut(in.ctx)
-	case ev.Key.Is("ctrl-V"):
-		Paste(in.ctx)
-	case ev.Key.Is("ctrl-K"):
-		err = RemoveLines(in.ctx)
-	case ev.Key.Is("ctrl-A"):
-		err = SelectAll(in.ctx)
-	case ev.Key.Is("ctrl-Z"):
-		err = Undo(in.ctx)
-	case ev.Key.Is("ctrl-shift-D"):
-		err = Uncomment(in.ctx)
-	case ev.Key.Is("ctrl-shift-Z"):
-		err = Redo(in.ctx)
-
-	case ev.Key.Rune != 0:
-		err = InsertString(in.ctx, string(ev.Key.Rune))
-		makeCursorVisible()
+// Runs the actions in order, handled if the last one was handled.
+func (in *Input) keyActions(actions []string) (bool, error) {
+	handled := false
+	for _, a := range actions {
+		h, err := in.keyAction(a)
+		if err != nil {
+			return true, err
+		}
+		handled = h
+	}
+	return handled, nil
+}
 
-	default:
-		return false, nil
+func (in *Input) keyAction(action string) (bool, error) {
+	ka, ok := keyActions[action]
+	if !ok {
+		// not an editing action (ex: command), let the owner handle it
+		return in.ctx.Fns.RunKeyAction(action), nil
+	}
+	err := ka.fn(in.ctx)
+	if err == nil && ka.cursorVisible {
+		in.ctx.Fns.MakeIndexVisible(in.ctx.C.Index())
 	}
 	return true, err
 }
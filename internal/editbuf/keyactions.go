@@ -0,0 +1,66 @@
+package editbuf
+
+//godebug:annotatefile
+
+type keyAction struct {
+	fn            func(ctx *EditorBuffer) error
+	cursorVisible bool // make the cursor visible after running
+}
+
+// Actions that can be bound to keys in the text area and toolbar keymaps.
+var keyActions = map[string]keyAction{
+	"cursorRight":           {sel1(MoveCursorRight, false), true},
+	"cursorRightSelect":     {sel1(MoveCursorRight, true), true},
+	"cursorJumpRight":       {sel1(MoveCursorJumpRight, false), true},
+	"cursorJumpRightSelect": {sel1(MoveCursorJumpRight, true), true},
+	"cursorLeft":            {sel1(MoveCursorLeft, false), true},
+	"cursorLeftSelect":      {sel1(MoveCursorLeft, true), true},
+	"cursorJumpLeft":        {sel1(MoveCursorJumpLeft, false), true},
+	"cursorJumpLeftSelect":  {sel1(MoveCursorJumpLeft, true), true},
+	"cursorUp":              {sel2(MoveCursorUp, false), true},
+	"cursorUpSelect":        {sel2(MoveCursorUp, true), true},
+	"cursorDown":            {sel2(MoveCursorDown, false), true},
+	"cursorDownSelect":      {sel2(MoveCursorDown, true), true},
+	"moveLineUp":            {MoveLineUp, true},
+	"moveLineDown":          {MoveLineDown, true},
+
+	"startOfString":       {sel2(StartOfString, false), true},
+	"startOfStringSelect": {sel2(StartOfString, true), true},
+	"startOfLine":         {sel1(StartOfLine, false), true},
+	"startOfLineSelect":   {sel1(StartOfLine, true), true},
+	"endOfString":         {sel2(EndOfString, false), true},
+	"endOfStringSelect":   {sel2(EndOfString, true), true},
+	"endOfLine":           {sel1(EndOfLine, false), true},
+	"endOfLineSelect":     {sel1(EndOfLine, true), true},
+
+	"backspace":  {AutoPairBackspace, true},
+	"delete":     {Delete, true},
+	"autoIndent": {AutoIndent, true},
+	"tabLeft":    {TabLeft, true},
+	"tabRight":   {TabRight, true},
+	"pageUp":     {sel2(PageUp, true), false},
+	"pageDown":   {sel2(PageUp, false), false},
+
+	"matchBracket": {JumpToMatchingBracket, true},
+
+	"comment":       {Comment, false},
+	"uncomment":     {Uncomment, false},
+	"copy":          {Copy, false},
+	"cut":           {Cut, false},
+	"paste":         {noErr(Paste), false},
+	"pastePrevious": {PastePrevious, false},
+	"removeLines":   {RemoveLines, false},
+	"selectAll":     {SelectAll, false},
+	"undo":          {Undo, false},
+	"redo":          {Redo, false},
+}
+
+func sel1(fn func(*EditorBuffer, bool) error, v bool) func(*EditorBuffer) error {
+	return func(ctx *EditorBuffer) error { return fn(ctx, v) }
+}
+func sel2(fn func(*EditorBuffer, bool), v bool) func(*EditorBuffer) error {
+	return func(ctx *EditorBuffer) error { fn(ctx, v); return nil }
+}
+func noErr(fn func(*EditorBuffer)) func(*EditorBuffer) error {
+	return func(ctx *EditorBuffer) error { fn(ctx); return nil }
+}
@@ -5,6 +5,10 @@ func RemoveLines(ctx *EditorBuffer) error {
 	if err != nil {
 		return err
 	}
+	// keep in the clipboard history, without replacing the clipboard
+	if b2, err := ctx.RW.ReadFastAt(a, b-a); err == nil {
+		Clipboard.Add(string(b2))
+	}
 	if err := ctx.RW.OverwriteAt(a, b-a, nil); err != nil {
 		return err
 	}
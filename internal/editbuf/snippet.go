@@ -0,0 +1,122 @@
+package editbuf
+
+import (
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/snippet"
+)
+
+// Placeholders of an inserted snippet, visited with tab/shift-tab.
+type SnippetSession struct {
+	groups [][]*[2]int // ranges of each tabstop in visiting order, the first range is the one edited, the others mirror it
+	cur    int
+}
+
+// Replaces [a,b) with the snippet text and selects the first placeholder.
+func InsertSnippet(ctx *EditorBuffer, a, b int, sn *snippet.Snippet) error {
+	ctx.Snippet = nil
+	if err := ctx.RW.OverwriteAt(a, b-a, []byte(sn.Text)); err != nil {
+		return err
+	}
+	ss := &SnippetSession{}
+	for i, st := range sn.Stops {
+		r := &[2]int{a + st.Start, a + st.End}
+		if i > 0 && sn.Stops[i-1].N == st.N {
+			k := len(ss.groups) - 1
+			ss.groups[k] = append(ss.groups[k], r)
+			continue
+		}
+		ss.groups = append(ss.groups, []*[2]int{r})
+	}
+	ctx.Snippet = ss
+	ss.visit(ctx, 0)
+	return nil
+}
+
+// Moves to the next (or previous) placeholder. Returns false if there is no snippet being edited or the cursor is outside the current placeholder (ends the snippet editing).
+func SnippetJump(ctx *EditorBuffer, back bool) (bool, error) {
+	ss := ctx.Snippet
+	if ss == nil {
+		return false, nil
+	}
+	r := ss.groups[ss.cur][0]
+	if ci := ctx.C.Index(); ci < r[0] || ci > r[1] {
+		ctx.Snippet = nil
+		return false, nil
+	}
+	if err := ss.updateMirrors(ctx); err != nil {
+		ctx.Snippet = nil
+		return true, err
+	}
+	k := ss.cur + 1
+	if back {
+		k = max(0, ss.cur-1)
+	}
+	ss.visit(ctx, k)
+	return true, nil
+}
+
+func (ss *SnippetSession) visit(ctx *EditorBuffer, k int) {
+	ss.cur = k
+	r := ss.groups[k][0]
+	if r[0] == r[1] {
+		ctx.C.SetIndexSelectionOff(r[0])
+	} else {
+		ctx.C.SetSelection(r[0], r[1])
+	}
+	if k == len(ss.groups)-1 {
+		ctx.Snippet = nil // reached $0
+	}
+}
+
+// Copies the text of the current placeholder to its mirrors.
+func (ss *SnippetSession) updateMirrors(ctx *EditorBuffer) error {
+	g := ss.groups[ss.cur]
+	if len(g) < 2 {
+		return nil
+	}
+	b, err := ctx.RW.ReadFastAt(g[0][0], g[0][1]-g[0][0])
+	if err != nil {
+		return err
+	}
+	s := string(b) // copy, the writes can change the buffer
+	for _, r := range g[1:] {
+		b2, err := ctx.RW.ReadFastAt(r[0], r[1]-r[0])
+		if err != nil {
+			return err
+		}
+		if string(b2) == s {
+			continue
+		}
+		// the write updates the ranges (see Update)
+		if err := ctx.RW.OverwriteAt(r[0], r[1]-r[0], []byte(s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Keeps the placeholders positions on writes. Typing at the end of a placeholder extends it.
+func (ss *SnippetSession) Update(ev *ioutil.RWEvWrite) {
+	if ss == nil {
+		return
+	}
+	for _, g := range ss.groups {
+		for _, r := range g {
+			r[0], r[1] = stableSnippetRange(r[0], r[1], ev.Index, ev.Dn, ev.In)
+		}
+	}
+}
+
+func stableSnippetRange(s, e, i, dn, in int) (int, int) {
+	switch {
+	case i+dn < s || (i+dn == s && (dn > 0 || s < e)): // before
+		d := in - dn
+		return s + d, e + d
+	case i > e || (i == e && dn > 0): // after
+		return s, e
+	case i == e: // insert at the end
+		return s, e + in
+	default: // overlaps
+		return min(s, i), max(i+in, e+in-dn)
+	}
+}
@@ -7,8 +7,9 @@ import (
 )
 
 func TabRight(ctx *EditorBuffer) error {
+	unit := ctx.indentUnit()
 	if !ctx.C.HaveSelection() {
-		return InsertString(ctx, "\t")
+		return InsertString(ctx, unit)
 	}
 
 	a, b, newline, err := ctx.CursorSelectionLinesIndexes()
@@ -18,10 +19,10 @@ func TabRight(ctx *EditorBuffer) error {
 
 	// insert at lines start
 	for i := a; i < b; {
-		if err := ctx.RW.OverwriteAt(i, 0, []byte{'\t'}); err != nil {
+		if err := ctx.RW.OverwriteAt(i, 0, []byte(unit)); err != nil {
 			return err
 		}
-		b += 1 // size of \t
+		b += len(unit)
 
 		rd := ctx.LocalReader(i)
 		u, _, err := ioutil.LineEndIndex(rd, i)
@@ -46,19 +47,23 @@ func TabLeft(ctx *EditorBuffer) error {
 		return err
 	}
 
-	// remove from lines start
+	// remove from lines start: a tab, or up to the indent unit size of spaces
 	altered := false
 	for i := a; i < b; {
-		s, err := ctx.RW.ReadFastAt(i, 1)
+		s, err := ctx.RW.ReadFastAt(i, min(len(ctx.indentUnit()), b-i))
 		if err != nil {
 			return err
 		}
-		if bytes.ContainsAny(s, "\t ") {
+		n := len(s) - len(bytes.TrimLeft(s, " "))
+		if len(s) > 0 && s[0] == '\t' {
+			n = 1
+		}
+		if n > 0 {
 			altered = true
-			if err := ctx.RW.OverwriteAt(i, 1, nil); err != nil {
+			if err := ctx.RW.OverwriteAt(i, n, nil); err != nil {
 				return err
 			}
-			b -= 1 // 1 is length of '\t' or ' '
+			b -= n
 		}
 
 		rd := ctx.LocalReader(i)
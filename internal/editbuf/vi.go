@@ -0,0 +1,1220 @@
+package editbuf
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+//godebug:annotatefile
+
+type ViMode int
+
+const (
+	ViNormal ViMode = iota
+	ViInsert
+	ViVisual
+)
+
+func (m ViMode) String() string {
+	switch m {
+	case ViInsert:
+		return "insert"
+	case ViVisual:
+		return "visual"
+	}
+	return "normal"
+}
+
+// Vi-style modal editing state of an editor buffer.
+type Vi struct {
+	Mode         ViMode
+	OnModeChange func(ViMode)
+
+	keys []string          // pending normal mode cmd
+	evs  []*driver.KeyDown // events of the pending cmd
+
+	change    []*driver.KeyDown // change being recorded (ex: "cw" continues in insert mode)
+	recording bool
+	replaying bool
+
+	visualStart int
+
+	search struct {
+		on    bool
+		str   string
+		start int // cursor index when the search started
+	}
+}
+
+func NewVi() *Vi {
+	return &Vi{}
+}
+
+// registers and the last change/search are shared by all buffers
+var viState struct {
+	registers  map[rune]*viRegister
+	lastChange []*driver.KeyDown
+	lastSearch string
+}
+
+type viRegister struct {
+	b        []byte
+	linewise bool
+}
+
+//----------
+
+// Modal input handler. Non key events (ex: mouse) are handled by HandleInput.
+func HandleViInput(ctx *EditorBuffer, ev any) (bool, error) {
+	kd, ok := ev.(*driver.KeyDown)
+	if !ok || ctx.Vi == nil {
+		return HandleInput(ctx, ev)
+	}
+	return ctx.Vi.keyDown(ctx, kd)
+}
+
+func (vi *Vi) keyDown(ctx *EditorBuffer, ev *driver.KeyDown) (bool, error) {
+	k := viKeyName(ev.Key)
+
+	if vi.Mode == ViInsert {
+		if vi.recording {
+			vi.change = append(vi.change, ev)
+		}
+		if k == "<Esc>" {
+			vi.endChange()
+			vi.setMode(ViNormal)
+			// cursor goes back to the inserted text
+			if err := viMoveLeftInLine(ctx); err != nil {
+				return true, err
+			}
+			return true, nil
+		}
+		in := &Input{ctx, ev}
+		return in.onKeyDown(ev)
+	}
+
+	if vi.search.on {
+		return true, vi.searchKey(ctx, k)
+	}
+
+	if k == "" {
+		// not a vi key (ex: ctrl-S), use the key bindings
+		vi.keys, vi.evs = nil, nil
+		in := &Input{ctx, ev}
+		return in.onKeyDown(ev)
+	}
+
+	vi.keys = append(vi.keys, k)
+	vi.evs = append(vi.evs, ev)
+	cmd, done := parseViCmd(vi.keys, vi.Mode == ViVisual)
+	if !done {
+		return true, nil
+	}
+	evs := vi.evs
+	vi.keys, vi.evs = nil, nil
+	if cmd == nil {
+		return true, nil // invalid cmd, discard
+	}
+
+	if !vi.replaying && vi.Mode == ViNormal && cmd.isChange() {
+		vi.change = evs
+		vi.recording = true
+	}
+	err := vi.run(ctx, cmd)
+	if vi.Mode != ViInsert {
+		vi.endChange()
+	}
+	if err == nil {
+		ctx.Fns.MakeIndexVisible(ctx.C.Index())
+	}
+	return true, err
+}
+
+func (vi *Vi) endChange() {
+	if vi.recording {
+		vi.recording = false
+		viState.lastChange = vi.change
+		vi.change = nil
+	}
+}
+
+func (vi *Vi) setMode(m ViMode) {
+	if vi.Mode == m {
+		return
+	}
+	vi.Mode = m
+	if vi.OnModeChange != nil {
+		vi.OnModeChange(m)
+	}
+}
+
+// Reports if escape is used by vi: to leave the insert or visual mode, to end a search or to discard a pending cmd.
+func (vi *Vi) UsesEscape() bool {
+	return vi.Mode != ViNormal || vi.search.on || len(vi.keys) > 0
+}
+
+// Names for the keys used in normal mode. Returns empty for keys not used by vi.
+func viKeyName(k driver.Key) string {
+	if k.Rune != 0 {
+		return string(k.Rune)
+	}
+	switch {
+	case k.Is("Escape"), k.Is("ctrl-["):
+		return "<Esc>"
+	case k.Is("ctrl-R"):
+		return "<C-R>"
+	case k.Is("Left"):
+		return "<Left>"
+	case k.Is("Right"):
+		return "<Right>"
+	case k.Is("Up"):
+		return "<Up>"
+	case k.Is("Down"):
+		return "<Down>"
+	case k.Is("Backspace"):
+		return "<BS>"
+	case k.Is("Return"):
+		return "<CR>"
+	case k.Is("Delete"):
+		return "<Del>"
+	case k.Is("Tab"):
+		return "<Tab>"
+	}
+	return ""
+}
+
+//----------
+
+type viCmd struct {
+	reg   rune   // register, zero if not given
+	count int    // zero if not given
+	op    string // operator: "d", "c", "y"
+	name  string // motion or command (same as op on a line operation: "dd")
+	arg   string // char argument ("f", "t", "F", "T", "r")
+}
+
+func (cmd *viCmd) n() int {
+	return max(1, cmd.count)
+}
+
+func (cmd *viCmd) isChange() bool {
+	if cmd.op == "d" || cmd.op == "c" {
+		return true
+	}
+	return strings.Contains(" i a I A o O x <Del> X p P D C s J r ", " "+cmd.name+" ")
+}
+
+var viMotions = map[string]bool{
+	"h": true, "l": true, "j": true, "k": true,
+	"<Left>": true, "<Right>": true, "<Up>": true, "<Down>": true, "<BS>": true, "<CR>": true,
+	"w": true, "b": true, "e": true,
+	"0": true, "^": true, "$": true,
+	"gg": true, "G": true,
+	"f": true, "t": true, "F": true, "T": true,
+	"n": true, "N": true,
+}
+
+// Returns done=false if more keys are needed. A nil cmd when done means the keys are not a valid cmd.
+func parseViCmd(keys []string, visual bool) (*viCmd, bool) {
+	cmd := &viCmd{}
+	i := 0
+	next := func() (string, bool) {
+		if i >= len(keys) {
+			return "", false
+		}
+		i++
+		return keys[i-1], true
+	}
+	count := func(k string) (string, int, bool) {
+		n := 0
+		for len(k) == 1 && k[0] >= '0' && k[0] <= '9' && (k != "0" || n > 0) {
+			n = n*10 + int(k[0]-'0')
+			var ok bool
+			if k, ok = next(); !ok {
+				return "", 0, false
+			}
+		}
+		return k, n, true
+	}
+
+	k, ok := next()
+	if !ok {
+		return nil, false
+	}
+	if k == "\"" {
+		r, ok := next()
+		if !ok {
+			return nil, false
+		}
+		ru, _ := utf8.DecodeRuneInString(r)
+		if !validViRegister(ru) {
+			return nil, true
+		}
+		cmd.reg = ru
+		if k, ok = next(); !ok {
+			return nil, false
+		}
+	}
+	if k, cmd.count, ok = count(k); !ok {
+		return nil, false
+	}
+
+	if !visual && (k == "d" || k == "c" || k == "y") {
+		cmd.op = k
+		if k, ok = next(); !ok {
+			return nil, false
+		}
+		n := 0
+		if k, n, ok = count(k); !ok {
+			return nil, false
+		}
+		if n > 0 {
+			cmd.count = max(1, cmd.count) * n
+		}
+		if k == cmd.op {
+			cmd.name = k
+			return cmd, true
+		}
+	}
+
+	switch k {
+	case "g":
+		k2, ok := next()
+		if !ok {
+			return nil, false
+		}
+		if k2 != "g" {
+			return nil, true
+		}
+		cmd.name = "gg"
+	case "f", "t", "F", "T", "r":
+		arg, ok := next()
+		if !ok {
+			return nil, false
+		}
+		if utf8.RuneCountInString(arg) != 1 {
+			return nil, true
+		}
+		cmd.name, cmd.arg = k, arg
+	default:
+		cmd.name = k
+	}
+	if cmd.op != "" && !viMotions[cmd.name] {
+		return nil, true
+	}
+	return cmd, true
+}
+
+func validViRegister(ru rune) bool {
+	return ru == '"' || ru == '+' || unicode.IsDigit(ru) || (ru < utf8.RuneSelf && unicode.IsLetter(ru))
+}
+
+//----------
+
+func (vi *Vi) run(ctx *EditorBuffer, cmd *viCmd) error {
+	if vi.Mode == ViVisual {
+		return vi.runVisual(ctx, cmd)
+	}
+	if cmd.op != "" {
+		return vi.operate(ctx, cmd)
+	}
+	if viMotions[cmd.name] {
+		m, err := viMotion(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(m.to)
+		return nil
+	}
+
+	n := cmd.n()
+	ci := ctx.C.Index()
+	switch cmd.name {
+	case "i":
+		vi.setMode(ViInsert)
+	case "a":
+		if err := viMoveRightInLine(ctx); err != nil {
+			return err
+		}
+		vi.setMode(ViInsert)
+	case "I":
+		ls, err := viLineStart(ctx.RW, ci)
+		if err != nil {
+			return err
+		}
+		i, err := viFirstNonBlank(ctx.RW, ls)
+		if err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(i)
+		vi.setMode(ViInsert)
+	case "A":
+		if err := EndOfLine(ctx, false); err != nil {
+			return err
+		}
+		vi.setMode(ViInsert)
+	case "o":
+		if err := EndOfLine(ctx, false); err != nil {
+			return err
+		}
+		if err := AutoIndent(ctx); err != nil {
+			return err
+		}
+		vi.setMode(ViInsert)
+	case "O":
+		ls, err := viLineStart(ctx.RW, ci)
+		if err != nil {
+			return err
+		}
+		if err := ctx.RW.OverwriteAt(ls, 0, []byte("\n")); err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(ls)
+		vi.setMode(ViInsert)
+	case "x", "<Del>", "X", "s":
+		name := map[string]string{"x": "l", "<Del>": "l", "X": "h", "s": "l"}[cmd.name]
+		op := map[string]string{"x": "d", "<Del>": "d", "X": "d", "s": "c"}[cmd.name]
+		return vi.operate(ctx, &viCmd{reg: cmd.reg, count: cmd.count, op: op, name: name})
+	case "D", "C":
+		op := strings.ToLower(cmd.name)
+		return vi.operate(ctx, &viCmd{reg: cmd.reg, count: cmd.count, op: op, name: "$"})
+	case "p", "P":
+		return vi.put(ctx, cmd.reg, n, cmd.name == "p")
+	case "J":
+		return viJoinLines(ctx, max(2, n))
+	case "r":
+		return viReplaceRunes(ctx, cmd.arg, n)
+	case "u":
+		for i := 0; i < n; i++ {
+			if err := Undo(ctx); err != nil {
+				return err
+			}
+		}
+		ctx.C.SetSelectionOff()
+	case "<C-R>":
+		for i := 0; i < n; i++ {
+			if err := Redo(ctx); err != nil {
+				return err
+			}
+		}
+		ctx.C.SetSelectionOff()
+	case ".":
+		return vi.repeat(ctx, cmd.count)
+	case "v":
+		vi.visualStart = ci
+		vi.setMode(ViVisual)
+	case "/":
+		vi.search.on = true
+		vi.search.str = ""
+		vi.search.start = ci
+	}
+	return nil
+}
+
+func (vi *Vi) runVisual(ctx *EditorBuffer, cmd *viCmd) error {
+	if viMotions[cmd.name] {
+		m, err := viMotion(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		ctx.C.SetSelection(vi.visualStart, m.to)
+		return nil
+	}
+
+	exit := func() {
+		ctx.C.SetSelectionOff()
+		vi.setMode(ViNormal)
+	}
+
+	// selection includes the rune at the cursor
+	a, b := vi.visualStart, ctx.C.Index()
+	if a > b {
+		a, b = b, a
+	}
+	if _, size, err := ioutil.ReadRuneAt(ctx.RW, b); err == nil {
+		b += size
+	}
+
+	switch cmd.name {
+	case "<Esc>", "v":
+		exit()
+	case "d", "x", "c", "y":
+		op := map[string]string{"d": "d", "x": "d", "c": "c", "y": "y"}[cmd.name]
+		exit()
+		return vi.applyOp(ctx, op, a, b, false, cmd.reg)
+	case "p", "P":
+		exit()
+		if err := ctx.RW.OverwriteAt(a, b-a, nil); err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(a)
+		return vi.put(ctx, cmd.reg, cmd.n(), false)
+	}
+	return nil
+}
+
+// Repeats the last change by feeding its keys again. A count replaces the count of the change.
+func (vi *Vi) repeat(ctx *EditorBuffer, count int) error {
+	evs := viState.lastChange
+	if len(evs) == 0 || vi.replaying {
+		return nil
+	}
+	if count > 0 {
+		evs = viReplaceCount(evs, count)
+	}
+	vi.replaying = true
+	defer func() { vi.replaying = false }()
+	for _, ev := range evs {
+		if _, err := vi.keyDown(ctx, ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Replaces the counts of a cmd (before the cmd and after an operator: "3d2w") with the count.
+func viReplaceCount(evs []*driver.KeyDown, count int) []*driver.KeyDown {
+	i := 0
+	if len(evs) > 0 && viKeyName(evs[0].Key) == "\"" {
+		i = min(2, len(evs)) // register
+	}
+	res := slices.Clone(evs[:i])
+	for _, ru := range strconv.Itoa(count) {
+		res = append(res, &driver.KeyDown{Key: driver.Key{Rune: ru}})
+	}
+	isDigit := func(k string, first bool) bool {
+		return len(k) == 1 && k[0] >= '0' && k[0] <= '9' && (k != "0" || !first)
+	}
+	skipCount := func() {
+		for first := true; i < len(evs) && isDigit(viKeyName(evs[i].Key), first); first = false {
+			i++
+		}
+	}
+	skipCount()
+	isOp := func(ev *driver.KeyDown) bool {
+		k := viKeyName(ev.Key)
+		return k == "d" || k == "c" || k == "y"
+	}
+	if i < len(evs) && isOp(evs[i]) {
+		res = append(res, evs[i])
+		i++
+		skipCount()
+	}
+	return append(res, evs[i:]...)
+}
+
+//----------
+
+func (vi *Vi) operate(ctx *EditorBuffer, cmd *viCmd) error {
+	ci := ctx.C.Index()
+
+	// line operation: "dd", "cc", "yy"
+	if cmd.name == cmd.op {
+		s, err := viLineStart(ctx.RW, ci)
+		if err != nil {
+			return err
+		}
+		e, ok, err := viLinesDown(ctx.RW, s, cmd.n())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			e = ctx.RW.Max()
+		}
+		return vi.applyOp(ctx, cmd.op, s, e, true, cmd.reg)
+	}
+
+	// "cw" changes up to the end of the word
+	if cmd.op == "c" && cmd.name == "w" {
+		if ru, _, err := ioutil.ReadRuneAt(ctx.RW, ci); err == nil && viRuneClass(ru) != 0 {
+			cmd2 := *cmd
+			cmd2.name = "e"
+			cmd = &cmd2
+		}
+	}
+
+	m, err := viMotion(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	s, e := min(ci, m.to), max(ci, m.to)
+	if m.linewise {
+		if s, err = viLineStart(ctx.RW, s); err != nil {
+			return err
+		}
+		le, err := viLineEnd(ctx.RW, e)
+		if err != nil {
+			return err
+		}
+		e = min(le+1, ctx.RW.Max())
+	} else if m.inclusive {
+		if _, size, err := ioutil.ReadRuneAt(ctx.RW, e); err == nil {
+			e += size
+		}
+	}
+	return vi.applyOp(ctx, cmd.op, s, e, m.linewise, cmd.reg)
+}
+
+func (vi *Vi) applyOp(ctx *EditorBuffer, op string, s, e int, linewise bool, reg rune) error {
+	w, err := ctx.RW.ReadFastAt(s, e-s)
+	if err != nil {
+		return err
+	}
+	viSetRegister(reg, bytes.Clone(w), linewise, op == "y")
+
+	switch op {
+	case "y":
+		ctx.C.SetIndexSelectionOff(s)
+		return nil
+	case "c":
+		// keep the line break of a line change
+		if linewise && e > s && w[len(w)-1] == '\n' {
+			e--
+		}
+	case "d":
+		// deleting the last lines also removes the previous line break
+		if linewise && e == ctx.RW.Max() && s > 0 && (e == s || w[len(w)-1] != '\n') {
+			s--
+		}
+	}
+	if err := ctx.RW.OverwriteAt(s, e-s, nil); err != nil {
+		return err
+	}
+	ctx.C.SetIndexSelectionOff(s)
+
+	if op == "c" {
+		vi.setMode(ViInsert)
+	} else if !linewise {
+		// stay inside the line (ex: "D")
+		if ru, _, err := ioutil.ReadRuneAt(ctx.RW, s); err != nil || ru == '\n' {
+			return viMoveLeftInLine(ctx)
+		}
+	} else {
+		ls, err := viLineStart(ctx.RW, min(s, ctx.RW.Max()))
+		if err != nil {
+			return err
+		}
+		i, err := viFirstNonBlank(ctx.RW, ls)
+		if err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(i)
+	}
+	return nil
+}
+
+func (vi *Vi) put(ctx *EditorBuffer, reg rune, n int, after bool) error {
+	r, err := viGetRegister(reg)
+	if err != nil || r == nil {
+		return err
+	}
+	// ex: linewise yank of the empty last line
+	if len(r.b) == 0 || n == 0 {
+		return nil
+	}
+	ci := ctx.C.Index()
+	w := bytes.Repeat(r.b, n)
+
+	if r.linewise {
+		i, err := viLineStart(ctx.RW, ci)
+		if err != nil {
+			return err
+		}
+		if after {
+			le, err := viLineEnd(ctx.RW, ci)
+			if err != nil {
+				return err
+			}
+			i = le + 1
+			if i > ctx.RW.Max() {
+				// last line without line break
+				i = ctx.RW.Max()
+				w = append([]byte("\n"), bytes.TrimSuffix(w, []byte("\n"))...)
+			}
+		}
+		if err := ctx.RW.OverwriteAt(i, 0, w); err != nil {
+			return err
+		}
+		if w[0] == '\n' {
+			i++
+		}
+		ctx.C.SetIndexSelectionOff(i)
+		return nil
+	}
+
+	i := ci
+	if after {
+		if ru, size, err := ioutil.ReadRuneAt(ctx.RW, ci); err == nil && ru != '\n' {
+			i += size
+		}
+	}
+	if err := ctx.RW.OverwriteAt(i, 0, w); err != nil {
+		return err
+	}
+	// cursor on the last inserted rune
+	_, size := utf8.DecodeLastRune(w)
+	ctx.C.SetIndexSelectionOff(i + len(w) - size)
+	return nil
+}
+func viSetRegister(reg rune, b []byte, linewise, yank bool) {
+	if viState.registers == nil {
+		viState.registers = map[rune]*viRegister{}
+	}
+	r := &viRegister{b: b, linewise: linewise}
+	switch {
+	case reg == '+':
+		driver.SetClipboardData(string(b))
+	case unicode.IsUpper(reg):
+		// append to the register
+		reg = unicode.ToLower(reg)
+		if r0, ok := viState.registers[reg]; ok {
+			r = &viRegister{b: append(bytes.Clone(r0.b), b...), linewise: r0.linewise || linewise}
+		}
+		viState.registers[reg] = r
+	case reg != 0 && reg != '"':
+		viState.registers[reg] = r
+	case yank:
+		viState.registers['0'] = r
+	}
+	viState.registers['"'] = r
+}
+
+func viGetRegister(reg rune) (*viRegister, error) {
+	if reg == '+' {
+		s, err := driver.GetClipboardData()
+		if err != nil {
+			return nil, err
+		}
+		return &viRegister{b: []byte(s), linewise: strings.HasSuffix(s, "\n")}, nil
+	}
+	if reg == 0 {
+		reg = '"'
+	}
+	return viState.registers[unicode.ToLower(reg)], nil
+}
+
+//----------
+
+func (vi *Vi) searchKey(ctx *EditorBuffer, k string) error {
+	s := &vi.search
+	switch k {
+	case "<Esc>":
+		s.on = false
+		ctx.C.SetIndexSelectionOff(s.start)
+		return nil
+	case "<CR>":
+		s.on = false
+		if s.str != "" {
+			viState.lastSearch = s.str
+		}
+		ctx.C.SetSelectionOff()
+		return nil
+	case "<BS>":
+		if s.str == "" {
+			s.on = false
+			return nil
+		}
+		_, size := utf8.DecodeLastRuneInString(s.str)
+		s.str = s.str[:len(s.str)-size]
+	default:
+		if utf8.RuneCountInString(k) != 1 {
+			return nil
+		}
+		s.str += k
+	}
+
+	// incremental: select the match from the starting position
+	ctx.C.SetIndexSelectionOff(s.start)
+	if s.str == "" {
+		return nil
+	}
+	i, ok, err := viSearch(ctx.RW, s.start, s.str, false)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("vi: pattern not found: %v", s.str)
+	}
+	ctx.C.SetSelection(i+len(s.str), i)
+	ctx.Fns.MakeIndexVisible(i)
+	return nil
+}
+
+// Index of the next (or previous) occurrence of str from index ci, wrapping around.
+func viSearch(rd ioutil.ReaderAt, ci int, str string, reverse bool) (int, bool, error) {
+	w := []byte(str)
+	opt := &ioutil.IndexOpt{}
+	ctx := context.Background()
+	if reverse {
+		if i, _, err := ioutil.LastIndexCtx(ctx, rd, ci, w, opt); err != nil || i >= 0 {
+			return i, i >= 0, err
+		}
+		i, _, err := ioutil.LastIndexCtx(ctx, rd, rd.Max(), w, opt)
+		return i, i >= 0, err
+	}
+	if ci < rd.Max() {
+		if i, _, err := ioutil.IndexCtx(ctx, rd, ci+1, w, opt); err != nil || i >= 0 {
+			return i, i >= 0, err
+		}
+	}
+	i, _, err := ioutil.IndexCtx(ctx, rd, rd.Min(), w, opt)
+	return i, i >= 0, err
+}
+
+//----------
+
+type viMotionRes struct {
+	to        int
+	inclusive bool // the rune at "to" is part of an operator range
+	linewise  bool
+}
+
+func viMotion(ctx *EditorBuffer, cmd *viCmd) (*viMotionRes, error) {
+	rd := ctx.RW
+	ci := min(ctx.C.Index(), rd.Max())
+	n := cmd.n()
+	m := &viMotionRes{to: ci}
+
+	var err error
+	switch cmd.name {
+	case "h", "<Left>", "<BS>":
+		ls, err := viLineStart(rd, ci)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n && m.to > ls; i++ {
+			_, size, err := ioutil.ReadLastRuneAt(rd, m.to)
+			if err != nil {
+				return nil, err
+			}
+			m.to -= size
+		}
+	case "l", "<Right>":
+		le, err := viLineEnd(rd, ci)
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < n && m.to < le; i++ {
+			_, size, err := ioutil.ReadRuneAt(rd, m.to)
+			if err != nil {
+				return nil, err
+			}
+			m.to += size
+		}
+	case "j", "<Down>", "<CR>":
+		m.to, err = viLineDown(rd, ci, n)
+		m.linewise = true
+	case "k", "<Up>":
+		m.to, err = viLineDown(rd, ci, -n)
+		m.linewise = true
+	case "w":
+		for i := 0; i < n && err == nil; i++ {
+			m.to, err = viWordForward(rd, m.to)
+		}
+	case "b":
+		for i := 0; i < n && err == nil; i++ {
+			m.to, err = viWordBackward(rd, m.to)
+		}
+	case "e":
+		for i := 0; i < n && err == nil; i++ {
+			m.to, err = viWordEnd(rd, m.to)
+		}
+		m.inclusive = true
+	case "0":
+		m.to, err = viLineStart(rd, ci)
+	case "^":
+		if m.to, err = viLineStart(rd, ci); err == nil {
+			m.to, err = viFirstNonBlank(rd, m.to)
+		}
+	case "$":
+		i, err := viLineDown(rd, ci, n-1)
+		if err != nil {
+			return nil, err
+		}
+		ls, le, err := viLine(rd, i)
+		if err != nil {
+			return nil, err
+		}
+		m.to = le
+		// on the last rune of the line
+		if le > ls {
+			_, size, err := ioutil.ReadLastRuneAt(rd, le)
+			if err != nil {
+				return nil, err
+			}
+			m.to -= size
+		}
+		m.inclusive = true
+	case "gg", "G":
+		ls := rd.Min()
+		if cmd.count > 0 {
+			ls, _, err = viLinesDown(rd, ls, cmd.count-1)
+		} else if cmd.name == "G" {
+			ls, err = viLineStart(rd, rd.Max())
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.to, err = viFirstNonBlank(rd, ls)
+		m.linewise = true
+	case "f", "t", "F", "T":
+		ls, le, err := viLine(rd, ci)
+		if err != nil {
+			return nil, err
+		}
+		line, err := rd.ReadFastAt(ls, le-ls)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := viFindInLine(line, ci-ls, cmd.name, cmd.arg, n)
+		if !ok {
+			return nil, fmt.Errorf("vi: %q not found", cmd.arg)
+		}
+		m.to = ls + i
+		m.inclusive = cmd.name == "f" || cmd.name == "t"
+	case "n", "N":
+		if viState.lastSearch == "" {
+			return nil, fmt.Errorf("vi: no previous search")
+		}
+		for i := 0; i < n; i++ {
+			k, ok, err := viSearch(rd, m.to, viState.lastSearch, cmd.name == "N")
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, fmt.Errorf("vi: pattern not found: %v", viState.lastSearch)
+			}
+			m.to = k
+		}
+	default:
+		return nil, fmt.Errorf("vi: unknown motion: %v", strconv.Quote(cmd.name))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Motions read the lines around the cursor, or chunks when going over many lines, not the whole text.
+const viChunkSize = 32 * 1024
+
+func viLineStart(rd ioutil.ReaderAt, i int) (int, error) {
+	k, _, err := ioutil.LastIndexCtx(context.Background(), rd, i, []byte("\n"), &ioutil.IndexOpt{})
+	if err != nil {
+		return 0, err
+	}
+	if k < 0 {
+		return rd.Min(), nil
+	}
+	return k + 1, nil
+}
+
+// Index of the line break (or the end of the text).
+func viLineEnd(rd ioutil.ReaderAt, i int) (int, error) {
+	k, _, err := ioutil.Index(rd, i, []byte("\n"), false)
+	if err != nil {
+		return 0, err
+	}
+	if k < 0 {
+		return rd.Max(), nil
+	}
+	return k, nil
+}
+
+// Start and end (see viLineEnd) of the line at i.
+func viLine(rd ioutil.ReaderAt, i int) (int, int, error) {
+	ls, err := viLineStart(rd, i)
+	if err != nil {
+		return 0, 0, err
+	}
+	le, err := viLineEnd(rd, i)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ls, le, nil
+}
+
+// Start of the line n lines below the line starting at ls. Returns the start of the last line and false if there are less lines.
+func viLinesDown(rd ioutil.ReaderAt, ls, n int) (int, bool, error) {
+	max := rd.Max()
+	for i := ls; n > 0 && i < max; {
+		b, err := rd.ReadFastAt(i, min(viChunkSize, max-i))
+		if err != nil {
+			return 0, false, err
+		}
+		for j := 0; n > 0; n-- {
+			k := bytes.IndexByte(b[j:], '\n')
+			if k < 0 {
+				break
+			}
+			j += k + 1
+			ls = i + j
+		}
+		i += len(b)
+	}
+	return ls, n == 0, nil
+}
+
+// Start of the line n lines above the line starting at ls, or of the first line.
+func viLinesUp(rd ioutil.ReaderAt, ls, n int) (int, error) {
+	min := rd.Min()
+	// the line break before ls ends the previous line
+	for i := ls - 1; n > 0 && i > min; {
+		a := max(min, i-viChunkSize)
+		b, err := rd.ReadFastAt(a, i-a)
+		if err != nil {
+			return 0, err
+		}
+		for ; n > 0; n-- {
+			k := bytes.LastIndexByte(b, '\n')
+			if k < 0 {
+				break
+			}
+			b = b[:k]
+			ls = a + k + 1
+		}
+		i = a
+	}
+	if n > 0 {
+		return min, nil
+	}
+	return ls, nil
+}
+
+func viFirstNonBlank(rd ioutil.ReaderAt, ls int) (int, error) {
+	i, _, err := ioutil.RuneIndexFn(rd, ls, false, func(ru rune) bool {
+		return ru == ' ' || ru == '\t'
+	})
+	return i, viIgnoreEOF(err)
+}
+
+// Moves n lines down (up if negative) keeping the column (in runes).
+func viLineDown(rd ioutil.ReaderAt, i, n int) (int, error) {
+	ls, err := viLineStart(rd, i)
+	if err != nil {
+		return 0, err
+	}
+	b, err := rd.ReadFastAt(ls, i-ls)
+	if err != nil {
+		return 0, err
+	}
+	col := utf8.RuneCount(b)
+	if n >= 0 {
+		ls, _, err = viLinesDown(rd, ls, n)
+	} else {
+		ls, err = viLinesUp(rd, ls, -n)
+	}
+	if err != nil {
+		return 0, err
+	}
+	k := ls
+	for c := 0; c < col; c++ {
+		ru, size, err := ioutil.ReadRuneAt(rd, k)
+		if err != nil || ru == '\n' {
+			break
+		}
+		k += size
+	}
+	return k, nil
+}
+
+// 0=space, 1=word, 2=other
+func viRuneClass(ru rune) int {
+	switch {
+	case unicode.IsSpace(ru):
+		return 0
+	case ioutil.IsWordRune(ru):
+		return 1
+	}
+	return 2
+}
+
+func viWordForward(rd ioutil.ReaderAt, i int) (int, error) {
+	ru, size, err := ioutil.ReadRuneAt(rd, i)
+	if err != nil {
+		return i, viIgnoreEOF(err)
+	}
+	c := viRuneClass(ru)
+	for c != 0 && viRuneClass(ru) == c {
+		i += size
+		if ru, size, err = ioutil.ReadRuneAt(rd, i); err != nil {
+			return i, viIgnoreEOF(err)
+		}
+	}
+	for viRuneClass(ru) == 0 {
+		// an empty line is a word
+		if ru == '\n' {
+			if ru2, _, err := ioutil.ReadRuneAt(rd, i+size); err == nil && ru2 == '\n' {
+				return i + size, nil
+			}
+		}
+		i += size
+		if ru, size, err = ioutil.ReadRuneAt(rd, i); err != nil {
+			return i, viIgnoreEOF(err)
+		}
+	}
+	return i, nil
+}
+
+func viWordBackward(rd ioutil.ReaderAt, i int) (int, error) {
+	c := 0 // skip spaces, then the runes of the class found
+	for {
+		ru, size, err := ioutil.ReadLastRuneAt(rd, i)
+		if err != nil {
+			return i, viIgnoreEOF(err)
+		}
+		k := viRuneClass(ru)
+		if c == 0 {
+			c = k
+		} else if k != c {
+			return i, nil
+		}
+		i -= size
+	}
+}
+
+func viWordEnd(rd ioutil.ReaderAt, i int) (int, error) {
+	// move at least one rune
+	_, size, err := ioutil.ReadRuneAt(rd, i)
+	if err != nil {
+		return i, viIgnoreEOF(err)
+	}
+	i += size
+	ru, size, err := ioutil.ReadRuneAt(rd, i)
+	for err == nil && viRuneClass(ru) == 0 {
+		i += size
+		ru, size, err = ioutil.ReadRuneAt(rd, i)
+	}
+	if err != nil {
+		return i, viIgnoreEOF(err)
+	}
+	c := viRuneClass(ru)
+	for {
+		ru, size2, err := ioutil.ReadRuneAt(rd, i+size)
+		if err != nil || viRuneClass(ru) != c {
+			return i, viIgnoreEOF(err)
+		}
+		i += size
+		size = size2
+	}
+}
+
+func viIgnoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// Index in the line b of the n-th arg from ci.
+func viFindInLine(b []byte, ci int, name, arg string, n int) (int, bool) {
+	w := []byte(arg)
+	i := ci
+	switch name {
+	case "f", "t":
+		for ; n > 0; n-- {
+			start := i + 1
+			if start > len(b) {
+				return 0, false
+			}
+			k := bytes.Index(b[start:], w)
+			if k < 0 {
+				return 0, false
+			}
+			i = start + k
+		}
+		if name == "t" {
+			_, size := utf8.DecodeLastRune(b[:i])
+			i -= size
+		}
+	case "F", "T":
+		for ; n > 0; n-- {
+			k := bytes.LastIndex(b[:i], w)
+			if k < 0 {
+				return 0, false
+			}
+			i = k
+		}
+		if name == "T" {
+			i += len(w)
+		}
+	}
+	return i, true
+}
+
+func viMoveLeftInLine(ctx *EditorBuffer) error {
+	ci := ctx.C.Index()
+	ru, size, err := ioutil.ReadLastRuneAt(ctx.RW, ci)
+	if err != nil || ru == '\n' {
+		return nil
+	}
+	ctx.C.SetIndexSelectionOff(ci - size)
+	return nil
+}
+
+func viMoveRightInLine(ctx *EditorBuffer) error {
+	ci := ctx.C.Index()
+	ru, size, err := ioutil.ReadRuneAt(ctx.RW, ci)
+	if err != nil || ru == '\n' {
+		return nil
+	}
+	ctx.C.SetIndexSelectionOff(ci + size)
+	return nil
+}
+
+func viJoinLines(ctx *EditorBuffer, n int) error {
+	for i := 1; i < n; i++ {
+		le, err := viLineEnd(ctx.RW, ctx.C.Index())
+		if err != nil {
+			return err
+		}
+		if le >= ctx.RW.Max() {
+			break
+		}
+		e, err := viFirstNonBlank(ctx.RW, le+1)
+		if err != nil {
+			return err
+		}
+		if err := ctx.RW.OverwriteAt(le, e-le, []byte(" ")); err != nil {
+			return err
+		}
+		ctx.C.SetIndexSelectionOff(le)
+	}
+	return nil
+}
+
+func viReplaceRunes(ctx *EditorBuffer, s string, n int) error {
+	ci := ctx.C.Index()
+	le, err := viLineEnd(ctx.RW, ci)
+	if err != nil {
+		return err
+	}
+	e := ci
+	for i := 0; i < n; i++ {
+		if e >= le {
+			return nil // not enough runes in the line
+		}
+		_, size, err := ioutil.ReadRuneAt(ctx.RW, e)
+		if err != nil {
+			return err
+		}
+		e += size
+	}
+	w := strings.Repeat(s, n)
+	if err := ctx.RW.OverwriteAt(ci, e-ci, []byte(w)); err != nil {
+		return err
+	}
+	ctx.C.SetIndexSelectionOff(ci + len(w) - len(s))
+	return nil
+}
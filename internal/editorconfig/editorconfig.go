@@ -0,0 +1,310 @@
+// Package editorconfig reads the properties of a file from ".editorconfig" files (https://editorconfig.org).
+package editorconfig
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const Filename = ".editorconfig"
+
+// Lowercase property names to values (lowercase, except for unknown properties).
+type Properties map[string]string
+
+// Properties of the file, from the ".editorconfig" files in its directory and parents, up to a file with "root = true". Nearer files take precedence.
+func Lookup(filename string) (Properties, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	files := []*file{}
+	for dir := filepath.Dir(filename); ; {
+		f, err := parseFile(filepath.Join(dir, Filename))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if f != nil {
+			files = append(files, f)
+			if f.root {
+				break
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	props := Properties{}
+	for i := len(files) - 1; i >= 0; i-- {
+		files[i].apply(filename, props)
+	}
+	for k, v := range props {
+		if v == "unset" {
+			delete(props, k)
+		}
+	}
+	return props, nil
+}
+
+//----------
+
+func (p Properties) IndentStyle() string { return p["indent_style"] } // "tab", "space", or ""
+
+// Zero if not set.
+func (p Properties) IndentSize() int {
+	if p["indent_size"] == "tab" {
+		return p.TabWidth()
+	}
+	v, _ := strconv.Atoi(p["indent_size"])
+	return max(v, 0)
+}
+
+// Zero if not set, defaults to indent_size.
+func (p Properties) TabWidth() int {
+	if v, err := strconv.Atoi(p["tab_width"]); err == nil && v > 0 {
+		return v
+	}
+	if v, err := strconv.Atoi(p["indent_size"]); err == nil && v > 0 {
+		return v
+	}
+	return 0
+}
+
+func (p Properties) EndOfLine() string { return p["end_of_line"] } // "lf", "crlf", "cr", or ""
+func (p Properties) Charset() string   { return p["charset"] }     // ex: "utf-8", "utf-8-bom", "latin1"
+
+func (p Properties) TrimTrailingWhitespace() (v, ok bool) { return p.bool("trim_trailing_whitespace") }
+func (p Properties) InsertFinalNewline() (v, ok bool)     { return p.bool("insert_final_newline") }
+
+func (p Properties) bool(name string) (bool, bool) {
+	switch p[name] {
+	case "true":
+		return true, true
+	case "false":
+		return false, true
+	}
+	return false, false
+}
+
+//----------
+
+type file struct {
+	dir      string
+	root     bool
+	sections []*section
+}
+
+type section struct {
+	re    *regexp.Regexp
+	props [][2]string
+}
+
+func parseFile(filename string) (*file, error) {
+	fd, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	f := &file{dir: filepath.Dir(filename)}
+	var sec *section
+	sc := bufio.NewScanner(fd)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("%v:%v: bad section", filename, n)
+			}
+			re, err := globRegexp(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("%v:%v: %w", filename, n, err)
+			}
+			sec = &section{re: re}
+			f.sections = append(f.sections, sec)
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			k, v, ok = strings.Cut(line, ":")
+		}
+		if !ok {
+			return nil, fmt.Errorf("%v:%v: expecting key=value", filename, n)
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		v = strings.TrimSpace(v)
+		if knownProperty(k) {
+			v = strings.ToLower(v)
+		}
+		if sec == nil { // preamble
+			if k == "root" {
+				f.root = v == "true"
+			}
+			continue
+		}
+		sec.props = append(sec.props, [2]string{k, v})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *file) apply(filename string, props Properties) {
+	rel, err := filepath.Rel(f.dir, filename)
+	if err != nil {
+		return
+	}
+	rel = filepath.ToSlash(rel)
+	for _, sec := range f.sections {
+		if sec.re.MatchString(rel) {
+			for _, kv := range sec.props {
+				props[kv[0]] = kv[1]
+			}
+		}
+	}
+}
+
+func knownProperty(k string) bool {
+	switch k {
+	case "indent_style", "indent_size", "tab_width", "end_of_line", "charset", "trim_trailing_whitespace", "insert_final_newline", "root":
+		return true
+	}
+	return false
+}
+
+//----------
+
+// Regexp matching paths relative to the .editorconfig directory. A glob without a '/' matches the basename in any directory.
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	anyDir := !strings.Contains(glob, "/")
+	glob = strings.TrimPrefix(glob, "/")
+
+	sb := &strings.Builder{}
+	sb.WriteString("^")
+	if anyDir {
+		sb.WriteString("(?:.*/)?")
+	}
+	rs := []rune(glob)
+	braces := 0
+	for i := 0; i < len(rs); i++ {
+		c := rs[i]
+		switch c {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(string(rs[i])))
+			}
+		case '*':
+			if i+1 < len(rs) && rs[i+1] == '*' {
+				i++
+				sb.WriteString(".*")
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			k := runeIndex(rs, i, ']')
+			if k < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			class := rs[i+1 : k]
+			i = k
+			sb.WriteString("[")
+			if len(class) > 0 && class[0] == '!' {
+				sb.WriteString("^")
+				class = class[1:]
+			}
+			for _, c2 := range class {
+				if c2 == '\\' || c2 == '[' || c2 == ']' || c2 == '^' {
+					sb.WriteString(`\`)
+				}
+				sb.WriteRune(c2)
+			}
+			sb.WriteString("]")
+		case '{':
+			// numeric range
+			if k := runeIndex(rs, i, '}'); k >= 0 {
+				inner := string(rs[i+1 : k])
+				if a, b, ok := numRange(inner); ok {
+					sb.WriteString(numRangeRegexp(a, b))
+					i = k
+					continue
+				}
+				if !strings.Contains(inner, ",") {
+					sb.WriteString(regexp.QuoteMeta("{" + inner + "}"))
+					i = k
+					continue
+				}
+			}
+			braces++
+			sb.WriteString("(?:")
+		case '}':
+			if braces > 0 {
+				braces--
+				sb.WriteString(")")
+			} else {
+				sb.WriteString(`\}`)
+			}
+		case ',':
+			if braces > 0 {
+				sb.WriteString("|")
+			} else {
+				sb.WriteString(",")
+			}
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	if braces > 0 {
+		return nil, fmt.Errorf("unbalanced braces: %v", glob)
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
+
+func runeIndex(rs []rune, i int, ru rune) int {
+	for ; i < len(rs); i++ {
+		if rs[i] == ru {
+			return i
+		}
+	}
+	return -1
+}
+
+func numRange(s string) (int, int, bool) {
+	as, bs, ok := strings.Cut(s, "..")
+	if !ok {
+		return 0, 0, false
+	}
+	a, err1 := strconv.Atoi(as)
+	b, err2 := strconv.Atoi(bs)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return min(a, b), max(a, b), true
+}
+
+// Alternation of the numbers (ranges are expected to be small).
+func numRangeRegexp(a, b int) string {
+	if b-a > 1000 {
+		return `[+-]?\d+`
+	}
+	u := []string{}
+	for i := a; i <= b; i++ {
+		u = append(u, strconv.Itoa(i))
+	}
+	return "(?:" + strings.Join(u, "|") + ")"
+}
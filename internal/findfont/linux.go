@@ -9,7 +9,7 @@ package findfont
 #include <string.h>
 #include <fontconfig/fontconfig.h>
 
-char *find_font(const char *font_name) {
+char *find_font(const char *font_name, char **family) {
     FcInit();
     FcPattern *pattern = FcNameParse((const FcChar8 *)font_name);
     FcConfigSubstitute(NULL, pattern, FcMatchPattern);
@@ -18,10 +18,15 @@ char *find_font(const char *font_name) {
     FcResult result;
     FcPattern *match = FcFontMatch(NULL, pattern, &result);
     FcChar8 *file = NULL;
+    FcChar8 *fam = NULL;
 
+    *family = NULL;
     if (match) {
         if (FcPatternGetString(match, FC_FILE, 0, &file) == FcResultMatch) {
             char *file_path = strdup((const char *)file);
+            if (FcPatternGetString(match, FC_FAMILY, 0, &fam) == FcResultMatch) {
+                *family = strdup((const char *)fam);
+            }
             FcPatternDestroy(match);
             FcPatternDestroy(pattern);
             FcFini();
@@ -45,15 +50,31 @@ import (
 var ErrNoMatch = errors.New("font not found")
 
 func GetFontData(query string) ([]byte, error) {
+	b, _, err := GetFontDataFamily(query)
+	return b, err
+}
+
+// Font data and family name of the font matching the query (ex: "mono:bold"). A font is always matched, the family can be another than the one queried (ex: no bold variant).
+func GetFontDataFamily(query string) ([]byte, string, error) {
 	cquery := C.CString(query)
 	defer C.free(unsafe.Pointer(cquery))
 
-	cpath := C.find_font(cquery)
+	var cfamily *C.char
+	cpath := C.find_font(cquery, &cfamily)
 	if cpath == nil {
-		return nil, ErrNoMatch
+		return nil, "", ErrNoMatch
 	}
 	defer C.free(unsafe.Pointer(cpath))
+	family := ""
+	if cfamily != nil {
+		family = C.GoString(cfamily)
+		C.free(unsafe.Pointer(cfamily))
+	}
 
 	path := C.GoString(cpath)
-	return os.ReadFile(path)
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return b, family, nil
 }
@@ -0,0 +1,122 @@
+// Minimal .gitignore pattern matching used to walk project trees.
+package gitignore
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+type pattern struct {
+	base     string   // slash separated dir (relative to root) of the .gitignore file
+	segs     []string // pattern split by "/"
+	negate   bool
+	dirOnly  bool
+	anchored bool // pattern contains a slash (not counting a trailing one)
+}
+
+// Matcher holds the patterns of all .gitignore files seen so far.
+type Matcher struct {
+	patterns []*pattern
+}
+
+// Reads the ".gitignore" file inside dir (if it exists). The dir argument is relative to the walk root, slash separated, empty for the root itself.
+func (m *Matcher) readDir(root, dir string) {
+	f, err := os.Open(path.Join(root, dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if p, ok := parsePattern(dir, sc.Text()); ok {
+			m.patterns = append(m.patterns, p)
+		}
+	}
+}
+
+func parsePattern(base, line string) (*pattern, bool) {
+	line = strings.TrimRight(line, "\r")
+	// trailing spaces are ignored unless escaped
+	if !strings.HasSuffix(line, "\\ ") {
+		line = strings.TrimRight(line, " \t")
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, false
+	}
+
+	p := &pattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, "\\!") || strings.HasPrefix(line, "\\#") {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		p.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	if line == "" {
+		return nil, false
+	}
+	p.segs = strings.Split(line, "/")
+	return p, true
+}
+
+// Reports if name (relative to the walk root, slash separated) is ignored. The last matching pattern decides, allowing negations to re-include files.
+func (m *Matcher) Match(name string, isDir bool) bool {
+	ignored := false
+	for _, p := range m.patterns {
+		if p.match(name, isDir) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func (p *pattern) match(name string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+	if p.base != "" {
+		if !strings.HasPrefix(name, p.base+"/") {
+			return false
+		}
+		name = name[len(p.base)+1:]
+	}
+	if !p.anchored {
+		ok, _ := path.Match(p.segs[0], path.Base(name))
+		return ok
+	}
+	return matchSegs(p.segs, strings.Split(name, "/"))
+}
+
+func matchSegs(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			pat = pat[1:]
+			if len(pat) == 0 {
+				return true
+			}
+			for i := range name {
+				if matchSegs(pat, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,48 @@
+package gitignore
+
+import (
+	"context"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// Walks the tree rooted at root calling fn with the path (relative to root) of every regular file not ignored by the ".gitignore" files found on the way. The ".git" directories are always skipped.
+func Walk(ctx context.Context, root string, fn func(name string) error) error {
+	m := &Matcher{}
+	return walkDir(ctx, m, root, "", fn)
+}
+
+func walkDir(ctx context.Context, m *Matcher, root, dir string, fn func(string) error) error {
+	m.readDir(root, dir)
+
+	des, err := os.ReadDir(filepath.Join(root, filepath.FromSlash(dir)))
+	if err != nil {
+		return nil // unreadable dirs are skipped
+	}
+	// note: entries are sorted by name
+	for _, de := range des {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name := path.Join(dir, de.Name())
+		switch {
+		case de.IsDir():
+			if de.Name() == ".git" || m.Match(name, true) {
+				continue
+			}
+			if err := walkDir(ctx, m, root, name, fn); err != nil {
+				return err
+			}
+		case de.Type().IsRegular():
+			if m.Match(name, false) {
+				continue
+			}
+			if err := fn(filepath.FromSlash(name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
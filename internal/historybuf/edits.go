@@ -34,12 +34,13 @@ func (edits *Edits) Append(ur *UndoRedo) {
 }
 
 func (edits *Edits) MergeEdits(edits2 *Edits) {
+	empty := len(edits.list) == 0
 	// append list
-	for _, ur := range edits.list {
+	for _, ur := range edits2.list {
 		edits.Append(ur)
 	}
 	// merge cursor position
-	if len(edits.list) == 0 {
+	if empty {
 		edits.preCursor = edits2.preCursor
 	}
 	edits.postCursor = edits2.postCursor
@@ -1,9 +1,9 @@
 package historybuf
 
 import (
-	"container/list"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/friedelschoen/glake/internal/editbuf"
 )
@@ -11,42 +11,44 @@ import (
 ////godebug:annotatefile
 
 type History struct {
-	maxLen int // max elements in list // TODO: max data size
-	hlist  *HList
+	maxLen int // max nodes in the tree // TODO: max data size
+	htree  *HTree
 	ugroup struct { // undo group
 		sync.Mutex
 		active int
-		ohlist *HList // original list
+		ohtree *HTree // original tree
 		c      editbuf.SimpleCursor
 	}
 }
 
 func NewHistory(maxLen int) *History {
-	h := &History{hlist: NewHList(), maxLen: maxLen}
+	h := &History{htree: NewHTree(), maxLen: maxLen}
 	return h
 }
 
-func (h *History) Append(edits *Edits) { h.hlist.Append(edits, h.maxLen) }
-func (h *History) Clear()              { h.hlist.Clear() }
-func (h *History) ClearUndones()       { h.hlist.ClearUndones() }
-
-//func (h *History) MergeNDoneBack(n int) { h.hlist.MergeNDoneBack(n) }
+func (h *History) Append(edits *Edits) { h.htree.Append(edits, h.maxLen) }
+func (h *History) Clear()              { h.htree.Clear() }
+func (h *History) ClearUndones()       { h.htree.ClearUndones() }
 
 func (h *History) UndoRedo(redo, peek bool) (*Edits, bool) {
-	// the call to undo could be inside an undogroup, use the original list; usually this is ok since the only operations should be undo/redo, but if other write operations are done while on this undogroup, there could be undefined behaviour (programmer responsability)
+	// the call to undo could be inside an undogroup, use the original tree; usually this is ok since the only operations should be undo/redo, but if other write operations are done while on this undogroup, there could be undefined behaviour (programmer responsability)
 	h.ugroup.Lock()
 	defer h.ugroup.Unlock()
 
-	hl := h.hlist
-	if h.ugroup.ohlist != nil {
-		hl = h.ugroup.ohlist
-	}
-
+	ht := h.tree()
 	if redo {
-		return hl.Redo(peek)
+		return ht.Redo(peek)
 	} else {
-		return hl.Undo(peek)
+		return ht.Undo(peek)
+	}
+}
+
+// Tree with the undone/redone states (the original tree if inside an undo group). Needs the ugroup lock.
+func (h *History) tree() *HTree {
+	if h.ugroup.ohtree != nil {
+		return h.ugroup.ohtree
 	}
+	return h.htree
 }
 
 func (h *History) BeginUndoGroup(c editbuf.SimpleCursor) {
@@ -58,9 +60,9 @@ func (h *History) BeginUndoGroup(c editbuf.SimpleCursor) {
 		return
 	}
 
-	// replace hlist
-	h.ugroup.ohlist = h.hlist
-	h.hlist = NewHList()
+	// replace htree
+	h.ugroup.ohtree = h.htree
+	h.htree = NewHTree()
 
 	// keep cursordata
 	h.ugroup.c = c
@@ -78,144 +80,343 @@ func (h *History) EndUndoGroup(c editbuf.SimpleCursor) {
 		return
 	}
 
-	// merge all, should then have either 0 or 1 element
-	h.hlist.mergeToDoneBack(h.hlist.list.Front())
-	if h.hlist.list.Len() > 1 {
-		panic(fmt.Sprintf("history undo group merge: %v", h.hlist.list.Len()))
-	}
-
-	if h.hlist.list.Len() == 1 {
+	// merge all, should then have either 0 or 1 node
+	edits, ok := h.htree.mergePath()
+	if ok {
 		// overwrite undogroup cursors - allows a setbytes to not end with the full content selected since it overwrites all
-		edits := h.hlist.list.Front().Value.(*Edits)
 		edits.preCursor = h.ugroup.c
 		edits.postCursor = c
-		// append undogroup elements to the original list
-		h.ugroup.ohlist.Append(edits, h.maxLen)
+		// append undogroup elements to the original tree
+		h.ugroup.ohtree.Append(edits, h.maxLen)
 	}
 
-	// restore original list
-	h.hlist = h.ugroup.ohlist
-	h.ugroup.ohlist = nil
+	// restore original tree
+	h.htree = h.ugroup.ohtree
+	h.ugroup.ohtree = nil
+}
+
+//----------
+
+// Root of the tree (the oldest state kept) and the current state.
+func (h *History) Nodes() (root, cur *HNode) {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	ht := h.tree()
+	return ht.root, ht.cur
 }
 
-type HList struct {
-	list   *list.List
-	undone *list.Element
+// Number of undos and redos to move from the current state to the state of the node n. The redo branches are set to reach n, but undoing also sets them, so this should be called again after the undos.
+func (h *History) PathTo(n *HNode) (int, int, error) {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	return h.tree().pathTo(n)
 }
 
-func NewHList() *HList {
-	return &HList{list: list.New()}
+// Node with the most recent state at or before t (the root if none).
+func (h *History) NodeAt(t time.Time) *HNode {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	ht := h.tree()
+	best := ht.root
+	ht.walk(func(n *HNode) {
+		if n != ht.root && !n.time.After(t) && n.seq > best.seq {
+			best = n
+		}
+	})
+	return best
+}
+
+// Node by its sequence number.
+func (h *History) NodeSeq(seq int) (*HNode, bool) {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	ht := h.tree()
+	var u *HNode
+	ht.walk(func(n *HNode) {
+		if n.seq == seq {
+			u = n
+		}
+	})
+	return u, u != nil
 }
 
-func (hl *HList) DoneBack() *list.Element {
-	if hl.undone != nil {
-		return hl.undone.Prev()
+// Tip of the neighbour branch (next or previous in creation order) at the closest fork above the current state.
+func (h *History) BranchTip(next bool) (*HNode, bool) {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	ht := h.tree()
+	for n := ht.cur; n.parent != nil; n = n.parent {
+		sibs := n.parent.children
+		if len(sibs) < 2 {
+			continue
+		}
+		k := 0
+		for i, s := range sibs {
+			if s == n {
+				k = i
+			}
+		}
+		if next {
+			k = (k + 1) % len(sibs)
+		} else {
+			k = (k - 1 + len(sibs)) % len(sibs)
+		}
+		return sibs[k].tip(), true
 	}
-	return hl.list.Back()
+	return nil, false
+}
+
+//----------
+
+// Undo tree: each node holds the edits from the parent state to the node state. Making a new edit after undoing starts a new branch, keeping the undone edits.
+type HTree struct {
+	root *HNode
+	cur  *HNode
+	size int // number of nodes (excluding root)
+	seq  int
+}
+
+func NewHTree() *HTree {
+	ht := &HTree{}
+	ht.Clear()
+	return ht
 }
 
-func (hl *HList) Append(edits *Edits, maxLen int) {
+func (ht *HTree) Append(edits *Edits, maxLen int) {
 	if edits.Empty() {
 		return
 	}
-	hl.ClearUndones()       // make back clear
-	hl.list.PushBack(edits) // add to the back
-	hl.clearOlds(maxLen)
-	tryToMergeLastTwoEdits(hl) // simplify history
+	ht.seq++
+	n := &HNode{parent: ht.cur, edits: edits, time: time.Now(), seq: ht.seq}
+	ht.cur.children = append(ht.cur.children, n)
+	ht.cur.redo = n
+	ht.cur = n
+	ht.size++
+	ht.clearOlds(maxLen)
+	tryToMergeLastTwoEdits(ht) // simplify history
 }
 
-func (hl *HList) Undo(peek bool) (*Edits, bool) {
-	u := hl.DoneBack()
-	if u == nil {
+func (ht *HTree) Undo(peek bool) (*Edits, bool) {
+	n := ht.cur
+	if n.parent == nil {
 		return nil, false
 	}
 	if !peek {
-		hl.undone = u
+		n.parent.redo = n
+		ht.cur = n.parent
 	}
-	return u.Value.(*Edits), true
+	return n.edits, true
 }
 
-func (hl *HList) Redo(peek bool) (*Edits, bool) {
-	u := hl.undone
-	if u == nil {
+func (ht *HTree) Redo(peek bool) (*Edits, bool) {
+	n := ht.cur.redoChild()
+	if n == nil {
 		return nil, false
 	}
 	if !peek {
-		hl.undone = hl.undone.Next()
+		ht.cur = n
 	}
-	return u.Value.(*Edits), true
+	return n.edits, true
 }
 
-func (hl *HList) Clear() {
-	hl.list = list.New()
-	hl.undone = nil
+func (ht *HTree) Clear() {
+	ht.root = &HNode{time: time.Now()}
+	ht.cur = ht.root
+	ht.size = 0
 }
 
-func (hl *HList) ClearUndones() {
-	for e := hl.undone; e != nil; {
-		u := e.Next()
-		hl.list.Remove(e)
-		e = u
+// Removes the states after the current one (all branches).
+func (ht *HTree) ClearUndones() {
+	for _, c := range ht.cur.children {
+		ht.size -= c.count()
 	}
-	hl.undone = nil
+	ht.cur.children = nil
+	ht.cur.redo = nil
 }
 
-func (hl *HList) clearOlds(maxLen int) {
-	for hl.list.Len() > maxLen {
-		e := hl.list.Front()
-		if e == hl.undone {
-			break
+func (ht *HTree) clearOlds(maxLen int) {
+	for ht.size > maxLen && ht.pruneOldest() {
+	}
+}
+
+// Removes the oldest state: the root if it has only one branch (and is not the current state), otherwise the oldest leaf outside of the current state.
+func (ht *HTree) pruneOldest() bool {
+	if len(ht.root.children) == 1 && ht.cur != ht.root {
+		n := ht.root.children[0]
+		n.parent = nil
+		n.edits = nil
+		ht.root = n
+		ht.size--
+		return true
+	}
+	var leaf *HNode
+	ht.walk(func(n *HNode) {
+		if n != ht.root && len(n.children) == 0 && n != ht.cur {
+			if leaf == nil || n.seq < leaf.seq {
+				leaf = n
+			}
 		}
-		hl.list.Remove(e)
+	})
+	if leaf == nil {
+		return false
 	}
+	leaf.parent.removeChild(leaf)
+	ht.size--
+	return true
 }
 
-func (hl *HList) mergeToDoneBack(elem *list.Element) {
-	for hl.mergeNextNotUndone(elem) {
+// Merges the edits from the root to the current state into one node.
+func (ht *HTree) mergePath() (*Edits, bool) {
+	path := ht.cur.path()
+	if len(path) == 0 {
+		return nil, false
+	}
+	edits := &Edits{}
+	for _, n := range path {
+		edits.MergeEdits(n.edits)
 	}
+	return edits, true
 }
-func (hl *HList) mergeNextNotUndone(elem *list.Element) bool {
-	if elem == nil {
-		return false
+
+// Last two states (the current and its parent), mergeable if there are no other branches.
+func (ht *HTree) lastTwo() (*HNode, *HNode, bool) {
+	n := ht.cur
+	p := n.parent
+	if p == nil || p.parent == nil || len(p.children) != 1 || len(n.children) != 0 {
+		return nil, nil, false
 	}
-	if elem == hl.undone {
-		return false
+	return p, n, true
+}
+
+// Merges the current state into its parent.
+func (ht *HTree) mergeLastTwo() {
+	p, n, ok := ht.lastTwo()
+	if !ok {
+		return
 	}
-	edits := elem.Value.(*Edits)
-	next := elem.Next()
-	if next == nil {
-		return false
+	p.edits.MergeEdits(n.edits)
+	p.time = n.time
+	p.children = nil
+	p.redo = nil
+	ht.cur = p
+	ht.size--
+}
+
+func (ht *HTree) pathTo(target *HNode) (int, int, error) {
+	if target.root() != ht.root {
+		return 0, 0, fmt.Errorf("history: state not found")
 	}
-	nextEdits := next.Value.(*Edits)
-	edits.MergeEdits(nextEdits)
-	hl.list.Remove(next)
-	return true
+	// up to the common ancestor, setting the redo branches down to the target
+	a, b := ht.cur, target
+	da, db := a.depth(), b.depth()
+	undos, redos := 0, 0
+	for ; da > db; da-- {
+		a = a.parent
+		undos++
+	}
+	for ; db > da; db-- {
+		b.parent.redo = b
+		b = b.parent
+		redos++
+	}
+	for a != b {
+		b.parent.redo = b
+		a, b = a.parent, b.parent
+		undos++
+		redos++
+	}
+	return undos, redos, nil
 }
 
-//func (hl *HList) MergeNDoneBack(n int) {
-//	e := hl.DoneBack()
-//	for ; n > 0 && e != nil; e = e.Prev() {
-//		n--
-//	}
-//	hl.mergeToDoneBack(e)
-//}
-
-func (hl *HList) NDoneBack(n int) ([]*Edits, []*list.Element) {
-	b := hl.DoneBack()
-	w := []*Edits{}
-	u := []*list.Element{}
-	for e := b; e != nil && n > 0; e = e.Prev() {
-		edits := e.Value.(*Edits)
-		w = append(w, edits)
-		u = append(u, e)
-		n--
-	}
-	// reverse append order
-	l := len(w)
-	for i := 0; i < l/2; i++ {
-		k := l - 1 - i
-		w[i], w[k] = w[k], w[i]
-		u[i], u[k] = u[k], u[i]
-	}
-	return w, u
+func (ht *HTree) walk(fn func(*HNode)) {
+	var visit func(n *HNode)
+	visit = func(n *HNode) {
+		fn(n)
+		for _, c := range n.children {
+			visit(c)
+		}
+	}
+	visit(ht.root)
+}
+
+//----------
+
+type HNode struct {
+	parent   *HNode
+	children []*HNode // in creation order
+	redo     *HNode   // child to follow on redo (last visited branch)
+	edits    *Edits   // nil at the root
+	time     time.Time
+	seq      int // creation order, zero at the initial root
+}
+
+func (n *HNode) Parent() *HNode     { return n.parent }
+func (n *HNode) Children() []*HNode { return n.children }
+func (n *HNode) Edits() *Edits      { return n.edits }
+func (n *HNode) Time() time.Time    { return n.time }
+func (n *HNode) Seq() int           { return n.seq }
+
+func (n *HNode) redoChild() *HNode {
+	if n.redo != nil {
+		return n.redo
+	}
+	if len(n.children) > 0 {
+		return n.children[len(n.children)-1]
+	}
+	return nil
+}
+
+// Follows the redo branches to the last state.
+func (n *HNode) tip() *HNode {
+	for c := n.redoChild(); c != nil; c = n.redoChild() {
+		n = c
+	}
+	return n
+}
+
+func (n *HNode) depth() int {
+	d := 0
+	for ; n.parent != nil; n = n.parent {
+		d++
+	}
+	return d
+}
+
+func (n *HNode) root() *HNode {
+	for n.parent != nil {
+		n = n.parent
+	}
+	return n
+}
+
+// Nodes from the root (excluded) to n.
+func (n *HNode) path() []*HNode {
+	u := []*HNode{}
+	for ; n.parent != nil; n = n.parent {
+		u = append(u, n)
+	}
+	for i, j := 0, len(u)-1; i < j; i, j = i+1, j-1 {
+		u[i], u[j] = u[j], u[i]
+	}
+	return u
+}
+
+func (n *HNode) count() int {
+	c := 1
+	for _, c2 := range n.children {
+		c += c2.count()
+	}
+	return c
+}
+
+func (n *HNode) removeChild(c *HNode) {
+	for i, c2 := range n.children {
+		if c2 == c {
+			n.children = append(n.children[:i], n.children[i+1:]...)
+			break
+		}
+	}
+	if n.redo == c {
+		n.redo = nil
+	}
 }
@@ -7,14 +7,14 @@ import (
 
 ////godebug:annotatefile
 
-func tryToMergeLastTwoEdits(hl *HList) {
-	editsL, elemsL := hl.NDoneBack(2)
-	if len(editsL) != 2 {
+func tryToMergeLastTwoEdits(ht *HTree) {
+	n1, n2, ok := ht.lastTwo()
+	if !ok {
 		return
 	}
-	if insertConsecutiveLetters(editsL[0], editsL[1]) ||
-		consecutiveSpaces(editsL[0], editsL[1]) {
-		hl.mergeToDoneBack(elemsL[0])
+	if insertConsecutiveLetters(n1.edits, n2.edits) ||
+		consecutiveSpaces(n1.edits, n2.edits) {
+		ht.mergeLastTwo()
 	}
 }
 
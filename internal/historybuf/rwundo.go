@@ -48,4 +48,38 @@ func (rw *RWUndo) UndoRedo(redo, peek bool) (editbuf.SimpleCursor, bool, error)
 	return c, true, nil
 }
 
+// Moves to the state of the node n (undoing up to the common state, then redoing down the branch of n).
+func (rw *RWUndo) GoTo(n *HNode) (editbuf.SimpleCursor, bool, error) {
+	undos, _, err := rw.History.PathTo(n)
+	if err != nil {
+		return editbuf.SimpleCursor{}, false, err
+	}
+	c, moved := editbuf.SimpleCursor{}, false
+	step := func(redo bool) error {
+		c2, ok, err := rw.UndoRedo(redo, false)
+		if err != nil {
+			return err
+		}
+		if ok {
+			c, moved = c2, true
+		}
+		return nil
+	}
+	for ; undos > 0; undos-- {
+		if err := step(false); err != nil {
+			return c, moved, err
+		}
+	}
+	_, redos, err := rw.History.PathTo(n)
+	if err != nil {
+		return c, moved, err
+	}
+	for ; redos > 0; redos-- {
+		if err := step(true); err != nil {
+			return c, moved, err
+		}
+	}
+	return c, moved, nil
+}
+
 // used in tests
@@ -0,0 +1,168 @@
+package historybuf
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/friedelschoen/glake/internal/editbuf"
+)
+
+// Serializable copy of the history (ex: gob), allows keeping the history across restarts.
+type Snapshot struct {
+	Nodes   []*SnapshotNode // the root first, parents before children
+	Current int             // index of the current state
+	Seq     int             // last node sequence number
+}
+
+type SnapshotNode struct {
+	Parent     int // index of the parent, -1 for the root
+	Seq        int
+	Time       time.Time
+	Redo       bool // branch followed by the parent on redo
+	List       []*UndoRedo
+	PreCursor  SnapshotCursor
+	PostCursor SnapshotCursor
+}
+
+type SnapshotCursor struct {
+	Index    int
+	SelOn    bool
+	SelIndex int
+}
+
+//----------
+
+// Snapshot of the history with at most maxSize bytes of edits data. The oldest states are dropped first.
+func (h *History) Snapshot(maxSize int) *Snapshot {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+
+	ht := h.tree().clone()
+	for ht.dataSize() > maxSize && ht.pruneOldest() {
+	}
+
+	s := &Snapshot{Seq: ht.seq}
+	index := map[*HNode]int{}
+	ht.walk(func(n *HNode) {
+		sn := &SnapshotNode{Parent: -1, Seq: n.seq, Time: n.time}
+		if n.parent != nil {
+			sn.Parent = index[n.parent]
+			sn.Redo = n.parent.redo == n
+		}
+		if n.edits != nil {
+			sn.List = n.edits.list
+			sn.PreCursor = snapshotCursor(n.edits.preCursor)
+			sn.PostCursor = snapshotCursor(n.edits.postCursor)
+		}
+		if n == ht.cur {
+			s.Current = len(s.Nodes)
+		}
+		index[n] = len(s.Nodes)
+		s.Nodes = append(s.Nodes, sn)
+	})
+	return s
+}
+
+// Replaces the history with the snapshot. The snapshot should have been taken with the same content.
+func (h *History) Restore(s *Snapshot) error {
+	if s == nil || len(s.Nodes) == 0 || s.Nodes[0].Parent != -1 || s.Current < 0 || s.Current >= len(s.Nodes) {
+		return fmt.Errorf("history: bad snapshot")
+	}
+	ht := &HTree{seq: s.Seq}
+	nodes := make([]*HNode, len(s.Nodes))
+	for i, sn := range s.Nodes {
+		n := &HNode{seq: sn.Seq, time: sn.Time}
+		nodes[i] = n
+		if i == 0 {
+			ht.root = n
+			continue
+		}
+		if sn.Parent < 0 || sn.Parent >= i {
+			return fmt.Errorf("history: bad snapshot")
+		}
+		n.edits = &Edits{
+			list:       sn.List,
+			preCursor:  sn.PreCursor.cursor(),
+			postCursor: sn.PostCursor.cursor(),
+		}
+		n.parent = nodes[sn.Parent]
+		n.parent.children = append(n.parent.children, n)
+		if sn.Redo {
+			n.parent.redo = n
+		}
+		ht.size++
+	}
+	ht.cur = nodes[s.Current]
+	ht.clearOlds(h.maxLen)
+
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	if h.ugroup.ohtree != nil {
+		h.ugroup.ohtree = ht
+	} else {
+		h.htree = ht
+	}
+	return nil
+}
+
+// Reports if the history has no edits.
+func (h *History) Empty() bool {
+	h.ugroup.Lock()
+	defer h.ugroup.Unlock()
+	return h.tree().size == 0
+}
+
+//----------
+
+// Copy of the tree structure (the edits are shared).
+func (ht *HTree) clone() *HTree {
+	ht2 := &HTree{size: ht.size, seq: ht.seq}
+	var visit func(n, parent *HNode) *HNode
+	visit = func(n, parent *HNode) *HNode {
+		n2 := &HNode{parent: parent, edits: n.edits, time: n.time, seq: n.seq}
+		if n == ht.cur {
+			ht2.cur = n2
+		}
+		for _, c := range n.children {
+			c2 := visit(c, n2)
+			n2.children = append(n2.children, c2)
+			if n.redo == c {
+				n2.redo = c2
+			}
+		}
+		return n2
+	}
+	ht2.root = visit(ht.root, nil)
+	return ht2
+}
+
+// Bytes of edits data.
+func (ht *HTree) dataSize() int {
+	size := 0
+	ht.walk(func(n *HNode) {
+		if n.edits != nil {
+			for _, ur := range n.edits.list {
+				size += len(ur.D) + len(ur.I)
+			}
+		}
+	})
+	return size
+}
+
+func snapshotCursor(c editbuf.SimpleCursor) SnapshotCursor {
+	sc := SnapshotCursor{Index: c.Index()}
+	if si, ci, ok := c.SelectionIndexesUnsorted(); ok {
+		sc = SnapshotCursor{Index: ci, SelOn: true, SelIndex: si}
+	}
+	return sc
+}
+
+func (sc SnapshotCursor) cursor() editbuf.SimpleCursor {
+	c := editbuf.SimpleCursor{}
+	if sc.SelOn {
+		c.SetSelection(sc.SelIndex, sc.Index)
+	} else {
+		c.SetIndexSelectionOff(sc.Index)
+	}
+	return c
+}
@@ -0,0 +1,91 @@
+package internalcmds
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/friedelschoen/glake/internal/command"
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/sam"
+)
+
+// Runs a sam command (structural regular expressions) on the row text, starting with the selection as dot.
+func Edit(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+
+	// the toolbar splits parts at "|", a shell pipe needs to be escaped
+	src := strings.ReplaceAll(args.Part.FromArgString(1), `\|`, "|")
+	if strings.TrimSpace(src) == "" {
+		return fmt.Errorf("missing command")
+	}
+	c, err := sam.Parse(src)
+	if err != nil {
+		return err
+	}
+
+	ta := erow.Row.TextArea
+	ctx := ta.EditCtx()
+	b, err := ioutil.ReadFastFull(ctx.RW)
+	if err != nil {
+		return err
+	}
+	b = bytes.Clone(b)
+
+	dot := sam.Range{Start: ctx.C.Index(), End: ctx.C.Index()}
+	if s, e, ok := ctx.C.SelectionIndexes(); ok {
+		dot = sam.Range{Start: s, End: e}
+	}
+
+	dir := erow.Info.Dir()
+	shell := func(cmd string, in []byte) ([]byte, error) {
+		return command.RunCmdStdin(args.Ctx, dir, bytes.NewReader(in), "sh", "-c", cmd)
+	}
+	res, err := sam.Exec(c, b, dot, shell)
+	if err != nil {
+		return err
+	}
+
+	if len(res.Output) > 0 {
+		args.Ed.Message(string(res.Output))
+	}
+
+	if len(res.Edits) == 0 {
+		if res.Dot.Start == res.Dot.End {
+			ctx.C.SetIndexSelectionOff(res.Dot.Start)
+		} else {
+			ctx.C.SetSelection(res.Dot.Start, res.Dot.End)
+		}
+		erow.MakeRangeVisibleAndFlash(res.Dot.Start, res.Dot.End-res.Dot.Start)
+		return nil
+	}
+
+	// apply from last to first to keep the original offsets valid
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	delta := 0
+	for i := len(res.Edits) - 1; i >= 0; i-- {
+		e := res.Edits[i]
+		if err := ctx.RW.OverwriteAt(e.Start, e.End-e.Start, e.Text); err != nil {
+			return err
+		}
+		if i < len(res.Edits)-1 {
+			delta += len(e.Text) - (e.End - e.Start)
+		}
+	}
+
+	// select from the first change to the end of the last change
+	first, last := res.Edits[0], res.Edits[len(res.Edits)-1]
+	end := last.Start + delta + len(last.Text)
+	if first.Start == end {
+		ctx.C.SetIndexSelectionOff(end)
+	} else {
+		ctx.C.SetSelection(first.Start, end)
+	}
+	erow.MakeRangeVisibleAndFlash(first.Start, end-first.Start)
+	return nil
+}
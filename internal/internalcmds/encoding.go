@@ -0,0 +1,105 @@
+package internalcmds
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/textencoding"
+)
+
+// Sets the encoding used to save the file. With "-reload", reads the file again decoding it with the encoding.
+func SetEncoding(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	if !erow.Info.IsFileButNotDir() {
+		return fmt.Errorf("not a file")
+	}
+	args2 := args.Part.ArgsUnquoted()[1:]
+	reload := false
+	if len(args2) > 0 && args2[0] == "-reload" {
+		reload = true
+		args2 = args2[1:]
+	}
+	if len(args2) != 1 {
+		args.Ed.Messagef("%v: %v", erow.Info.Name(), erow.Info.Format())
+		return nil
+	}
+	enc, err := textencoding.EncodingName(args2[0])
+	if err != nil {
+		return err
+	}
+	if reload {
+		return erow.Info.ReloadFileWithEncoding(enc)
+	}
+	f := erow.Info.Format()
+	f.Encoding = enc
+	erow.Info.SetFormat(f)
+	return nil
+}
+
+// Sets the line endings used to save the file: lf, crlf, or cr.
+func SetLineEnding(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	if !erow.Info.IsFileButNotDir() {
+		return fmt.Errorf("not a file")
+	}
+	args2 := args.Part.ArgsUnquoted()[1:]
+	if len(args2) != 1 {
+		return fmt.Errorf("expecting line ending: lf, crlf, or cr")
+	}
+	eol, err := textencoding.EOLName(args2[0])
+	if err != nil {
+		return err
+	}
+	f := erow.Info.Format()
+	if f.EOL == "mixed" {
+		// the text was kept as is, the "\r\n" line endings become "\n"
+		if err := removeCRLF(erow); err != nil {
+			return err
+		}
+	}
+	f.EOL = eol
+	erow.Info.SetFormat(f)
+	return nil
+}
+
+func removeCRLF(erow *core.ERow) error {
+	ta := erow.Row.TextArea
+	ctx := ta.EditCtx()
+	b, err := ioutil.ReadFastFull(ctx.RW)
+	if err != nil {
+		return err
+	}
+	idx := []int{}
+	for i := 0; ; i++ {
+		k := bytes.Index(b[i:], []byte("\r\n"))
+		if k < 0 {
+			break
+		}
+		i += k
+		idx = append(idx, i)
+	}
+
+	// delete from last to first to keep the indexes valid
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	ci := ctx.C.Index()
+	for k := len(idx) - 1; k >= 0; k-- {
+		i := idx[k]
+		if err := ctx.RW.OverwriteAt(i, 1, nil); err != nil {
+			return err
+		}
+		if i < ci {
+			ci--
+		}
+	}
+	ctx.C.SetIndexSelectionOff(ci)
+	return nil
+}
@@ -43,7 +43,17 @@ func Find(args *core.InternalCmdArgs) error {
 
 	str := strings.Join(w, " ")
 
-	found, err := editbuf.Find(args.Ctx, erow.Row.TextArea.EditCtx(), str, *reverseFlag, iopt)
+	var found bool
+	if ectx := erow.Row.TextArea.EditCtx(); ectx.Hex != nil {
+		// hex view: search the bytes (hex, or the text if not hex)
+		b, ok := editbuf.ParseHexBytes(str)
+		if !ok {
+			b = []byte(str)
+		}
+		found, err = editbuf.HexFind(args.Ctx, ectx, b, *reverseFlag)
+	} else {
+		found, err = editbuf.Find(args.Ctx, ectx, str, *reverseFlag, iopt)
+	}
 	if err != nil {
 		return err
 	}
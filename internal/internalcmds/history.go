@@ -0,0 +1,131 @@
+package internalcmds
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/historybuf"
+)
+
+// Moves the row content to a state of the undo tree: "#<n>" (see HistoryTree), or a duration ago (ex: "5m").
+func UndoTo(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	args2 := args.Part.Args[1:]
+	if len(args2) != 1 {
+		return fmt.Errorf("expecting #<n> or a duration")
+	}
+	s := args2[0].UnquotedString()
+
+	ta := erow.Row.TextArea
+	hist := ta.History()
+	var n *historybuf.HNode
+	if u, ok := strings.CutPrefix(s, "#"); ok {
+		seq, err := strconv.Atoi(u)
+		if err != nil {
+			return err
+		}
+		n, ok = hist.NodeSeq(seq)
+		if !ok {
+			return fmt.Errorf("history state not found: %v", s)
+		}
+	} else {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		n = hist.NodeAt(time.Now().Add(-d))
+	}
+	return ta.UndoTo(n)
+}
+
+// Moves to the tip of the next/previous branch of the undo tree.
+func UndoBranch(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	next := true
+	if len(args.Part.Args) > 1 {
+		switch s := args.Part.Args[1].UnquotedString(); s {
+		case "next":
+		case "prev":
+			next = false
+		default:
+			return fmt.Errorf("expecting next or prev: %v", s)
+		}
+	}
+	ta := erow.Row.TextArea
+	n, ok := ta.History().BranchTip(next)
+	if !ok {
+		return fmt.Errorf("no other branches")
+	}
+	return ta.UndoTo(n)
+}
+
+// Shows the undo tree of the row in the +HistoryTree row.
+func HistoryTree(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	root, cur := erow.Row.TextArea.History().Nodes()
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "history: %v\n", erow.Info.Name())
+	fmt.Fprintf(buf, "(use \"UndoTo #<n>\" on the row)\n")
+	now := time.Now()
+	var write func(n *historybuf.HNode, prefix, branch string)
+	write = func(n *historybuf.HNode, prefix, branch string) {
+		for {
+			fmt.Fprintf(buf, "%v%v%v\n", prefix, branch, historyNodeString(n, now, n == cur))
+			if branch == "├─" {
+				prefix += "│ "
+			} else if branch == "└─" {
+				prefix += "  "
+			}
+			branch = ""
+			cs := n.Children()
+			if len(cs) != 1 {
+				for i, c := range cs {
+					b := "├─"
+					if i == len(cs)-1 {
+						b = "└─"
+					}
+					write(c, prefix, b)
+				}
+				return
+			}
+			n = cs[0]
+		}
+	}
+	write(root, "", "")
+
+	erow2, _ := core.ExistingERowOrNewBasic(args.Ed, "+HistoryTree")
+	erow2.Row.TextArea.SetBytesClearPos(buf.Bytes())
+	erow2.Flash()
+	return nil
+}
+
+func historyNodeString(n *historybuf.HNode, now time.Time, current bool) string {
+	t := n.Time()
+	s := fmt.Sprintf("#%v %v (%v ago)", n.Seq(), t.Format("15:04:05"), now.Sub(t).Round(time.Second))
+	if edits := n.Edits(); edits != nil {
+		ins, del := 0, 0
+		for _, ur := range edits.Entries() {
+			ins += len(ur.I)
+			del += len(ur.D)
+		}
+		s += fmt.Sprintf(" +%v -%v", ins, del)
+	}
+	if current {
+		s += " <- current"
+	}
+	return s
+}
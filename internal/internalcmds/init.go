@@ -35,13 +35,34 @@ func init() {
 	cmd(Reload, "Reload")
 	cmd(ReloadAllFiles, "ReloadAllFiles")
 	cmd(ReloadAll, "ReloadAll")
+	cmd(MakeEditable, "MakeEditable")
+	cmd(SetEncoding, "SetEncoding")
+	cmd(SetLineEnding, "SetLineEnding")
 
 	cmd(Stop, "Stop")
 	cmd(Clear, "Clear")
 
+	cmd(UndoTo, "UndoTo")
+	cmd(UndoBranch, "UndoBranch")
+	cmd(HistoryTree, "HistoryTree")
+
 	cmd(Find, "Find")
 	cmd(Replace, "Replace")
+	cmd(ReplaceAll, "ReplaceAll")
+	cmd(ReplaceAllApply, "ReplaceAllApply")
 	cmd(GotoLine, "GotoLine", "GoToLine")
+	cmd(MatchBracket, "MatchBracket")
+	cmd(Search, "Search")
+	cmd(Edit, "Edit")
+	cmd(ListKeys, "ListKeys")
+	cmd(ViMode, "ViMode")
+	cmd(MacroRecord, "MacroRecord")
+	cmd(MacroReplay, "MacroReplay")
+	cmd(MacroSave, "MacroSave")
+	cmd(ListMacros, "ListMacros")
+	cmd(ClipboardHistory, "ClipboardHistory")
+	cmd(Recovery, "Recovery")
+	cmd(Snippet, "Snippet")
 
 	cmd(CopyFilePosition, "CopyFilePosition")
 	cmd(RuneCodes, "RuneCodes")
@@ -66,4 +87,5 @@ func init() {
 	cmd(CtxutilCallsState, "CtxutilCallsState")
 
 	cmd(sortTextLines, "SortTextLines")
+	cmd(Transform, "Transform")
 }
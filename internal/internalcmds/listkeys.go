@@ -0,0 +1,22 @@
+package internalcmds
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/keymap"
+)
+
+func ListKeys(args *core.InternalCmdArgs) error {
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "key bindings:")
+	for _, c := range keymap.Contexts {
+		fmt.Fprintf(buf, "\n%v:", c)
+		for _, b := range keymap.Get(c).Bindings() {
+			fmt.Fprintf(buf, "\n\t%v\t%v", b.KeysString(), b.Action)
+		}
+	}
+	args.Ed.Message(buf.String())
+	return nil
+}
@@ -0,0 +1,69 @@
+package internalcmds
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/friedelschoen/glake/internal/core"
+)
+
+func MacroRecord(args *core.InternalCmdArgs) error {
+	return args.Ed.Macros.ToggleRecording()
+}
+
+func MacroReplay(args *core.InternalCmdArgs) error {
+	// setup flagset
+	fs := flag.NewFlagSet("MacroReplay", flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // don't output to stderr
+	nFlag := fs.Int("n", 1, "number of times to replay")
+	allFlag := fs.Bool("all", false, "replay until the cursor stops moving or reaches the end of the text")
+	if err := parseFlagSetHandleUsage(args, fs); err != nil {
+		return err
+	}
+
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+
+	name := ""
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		name = fs.Arg(0)
+	default:
+		return fmt.Errorf("expecting at most one macro name")
+	}
+	if *nFlag < 1 {
+		return fmt.Errorf("bad count: %v", *nFlag)
+	}
+
+	keys, err := args.Ed.Macros.Macro(name)
+	if err != nil {
+		return err
+	}
+	n, err := args.Ed.Macros.Replay(erow, keys, *nFlag, *allFlag)
+	if err != nil {
+		return err
+	}
+	if *allFlag {
+		args.Ed.Messagef("macro: replayed %v times", n)
+	}
+	return nil
+}
+
+func MacroSave(args *core.InternalCmdArgs) error {
+	args2 := args.Part.Args[1:]
+	if len(args2) != 1 {
+		return fmt.Errorf("expecting macro name")
+	}
+	return args.Ed.Macros.Save(args2[0].UnquotedString())
+}
+
+func ListMacros(args *core.InternalCmdArgs) error {
+	names := args.Ed.Macros.Names()
+	args.Ed.Messagef("macros:\n\t%v", strings.Join(names, "\n\t"))
+	return nil
+}
@@ -1,6 +1,7 @@
 package internalcmds
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/friedelschoen/glake/internal/context"
 	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/editbuf"
 	"github.com/friedelschoen/glake/internal/multierror"
 	"github.com/friedelschoen/glake/internal/ui"
 )
@@ -40,6 +42,16 @@ func ListSessions(args *core.InternalCmdArgs) error {
 	return nil
 }
 
+func ClipboardHistory(args *core.InternalCmdArgs) error {
+	core.ListClipboardHistory(args.Ed)
+	return nil
+}
+
+func Recovery(args *core.InternalCmdArgs) error {
+	core.ListRecovery(args.Ed, false)
+	return nil
+}
+
 func NewColumn(args *core.InternalCmdArgs) error {
 	args.Ed.NewColumn()
 	return nil
@@ -98,6 +110,13 @@ func Reload(args *core.InternalCmdArgs) error {
 	}
 	return erow.Reload()
 }
+func MakeEditable(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	return erow.Info.MakeEditable()
+}
 func ReloadAllFiles(args *core.InternalCmdArgs) error {
 	me := &multierror.MultiError{}
 	for _, info := range args.Ed.ERowInfos() {
@@ -229,10 +248,22 @@ func OpenExternal(args *core.InternalCmdArgs) error {
 }
 
 func ColorTheme(args *core.InternalCmdArgs) error {
-	name := args.Part.Args[0].String()
-	ui.SetColorscheme(name, args.Ed.UI.Root)
-	args.Ed.UI.Root.MarkNeedsLayoutAndPaint()
-	return nil
+	// list available themes
+	if len(args.Part.Args) < 2 {
+		buf := &bytes.Buffer{}
+		fmt.Fprintf(buf, "color themes:")
+		for _, name := range ui.ColorThemes() {
+			mark := ""
+			if name == ui.ColorTheme {
+				mark = " (current)"
+			}
+			fmt.Fprintf(buf, "\n\t%v%v", name, mark)
+		}
+		args.Ed.Message(buf.String())
+		return nil
+	}
+	name := args.Part.Args[1].UnquotedString()
+	return args.Ed.SetColorTheme(name)
 }
 
 func FontRunes(args *core.InternalCmdArgs) error {
@@ -258,3 +289,66 @@ func CtxutilCallsState(args *core.InternalCmdArgs) error {
 	args.Ed.Messagef("%s", s)
 	return nil
 }
+
+func ViMode(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	ta := erow.Row.TextArea
+	on := !ta.ViMode()
+	if len(args.Part.Args) > 1 {
+		switch s := args.Part.Args[1].UnquotedString(); s {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			return fmt.Errorf("expecting on or off: %v", s)
+		}
+	}
+	ta.SetViMode(on)
+	return nil
+}
+
+func MatchBracket(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	ta := erow.Row.TextArea
+	if err := editbuf.JumpToMatchingBracket(ta.EditCtx()); err != nil {
+		return err
+	}
+	ta.MakeCursorVisible()
+	return nil
+}
+
+// Inserts a snippet by name or prefix, replacing the selection (available as $TM_SELECTED_TEXT). Lists the row snippets if no name is given.
+func Snippet(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	sn := erow.Ed.Snippets
+	args2 := args.Part.Args[1:]
+	if len(args2) == 0 {
+		args.Ed.Messagef("%v", sn.List(erow))
+		return nil
+	}
+	if len(args2) != 1 {
+		return fmt.Errorf("expecting a snippet name")
+	}
+	name := args2[0].UnquotedString()
+	def, ok := sn.Find(erow, name)
+	if !ok {
+		return fmt.Errorf("snippet not found: %v", name)
+	}
+	ta := erow.Row.TextArea
+	a, b, ok := ta.Cursor().SelectionIndexes()
+	if !ok {
+		a = ta.CursorIndex()
+		b = a
+	}
+	return sn.Insert(erow, def, a, b)
+}
@@ -0,0 +1,305 @@
+package internalcmds
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/eventregister"
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/parser"
+	"github.com/friedelschoen/glake/internal/ui"
+)
+
+// reviews waiting to be applied, keyed by the review row
+var replaceReviews = struct {
+	sync.Mutex
+	m map[*core.ERow]*replaceReview
+}{m: map[*core.ERow]*replaceReview{}}
+
+type replaceReview struct {
+	dir  string
+	mu   sync.Mutex
+	hits map[string]*replaceHit // keyed by "path:line:col"
+}
+
+type replaceHit struct {
+	filename   string // absolute
+	start, end int
+	old, new   []byte
+}
+
+func ReplaceAll(args *core.InternalCmdArgs) error {
+	// setup flagset
+	fs := flag.NewFlagSet("ReplaceAll", flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // don't output to stderr
+	reFlag := fs.Bool("re", false, "old is a regular expression, new can reference submatches ($1, ${name})")
+	icaseFlag := fs.Bool("icase", false, "ignore case: 'a' will also match 'A'")
+	globFlag := fs.String("glob", "", "only replace in files matching the glob pattern (ex: *.go)")
+	if err := parseFlagSetHandleUsage(args, fs); err != nil {
+		return err
+	}
+
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	if erow.Info.IsSpecial() {
+		return fmt.Errorf("can't run on special row")
+	}
+
+	args2 := fs.Args()
+	if len(args2) != 2 {
+		return fmt.Errorf("expecting 2 arguments")
+	}
+	oldStr, newStr := unquotedArgs(args2[:1]), unquotedArgs(args2[1:])
+	if oldStr == "" {
+		return fmt.Errorf("empty string to replace")
+	}
+	re, err := searchRegexp(oldStr, *reFlag, *icaseFlag)
+	if err != nil {
+		return err
+	}
+	if *globFlag != "" {
+		if _, err := filepath.Match(*globFlag, ""); err != nil {
+			return err
+		}
+	}
+	expand := func(m *searchMatch) []byte {
+		if !*reFlag {
+			return []byte(newStr)
+		}
+		return re.Expand(nil, []byte(newStr), m.text, m.submatch)
+	}
+
+	// content of open files (might differ from the saved files)
+	open := map[string][]byte{}
+	for _, info := range args.Ed.ERowInfos() {
+		if erow0, ok := info.FirstERow(); ok && info.IsFileButNotDir() {
+			if b, err := erow0.Row.TextArea.Bytes(); err == nil {
+				open[info.Name()] = bytes.Clone(b)
+			}
+		}
+	}
+
+	// review row
+	info := args.Ed.ReadERowInfo(erow.Info.Dir())
+	erow2 := core.NewBasicERow(info, erow.Row.PosBelow())
+	ioutil.Append(erow2.Row.Toolbar.RW(), []byte(" | ReplaceAllApply | Stop"))
+	erow2.Flash()
+
+	rev := &replaceReview{dir: info.Name(), hits: map[string]*replaceHit{}}
+	replaceReviews.Lock()
+	replaceReviews.m[erow2] = rev
+	replaceReviews.Unlock()
+
+	// forget the review when the row closes
+	var reg *eventregister.Regist
+	reg = args.Ed.EEvents.Register(core.PreRowCloseEEventId, func(ev0 any) {
+		ev := ev0.(*core.PreRowCloseEEvent)
+		if ev.ERow == erow2 {
+			replaceReviews.Lock()
+			delete(replaceReviews.m, erow2)
+			replaceReviews.Unlock()
+			reg.Unregister()
+		}
+	})
+
+	erow2.Exec.RunAsync(func(ctx context.Context, rw io.ReadWriter) error {
+		// NOTE: not running in UI goroutine here
+
+		fmt.Fprintf(rw, "# replace %q with %q: uncheck hits with \"[ ]\" (or delete the lines), then run ReplaceAllApply\n", oldStr, newStr)
+
+		var wmu sync.Mutex
+		nFiles, nMatches := 0, 0
+		err := walkSearchFiles(ctx, rev.dir, *globFlag, func(name string) {
+			filename := filepath.Join(rev.dir, name)
+			b, ok := open[filename]
+			if !ok {
+				b2, err := os.ReadFile(filename)
+				if err != nil {
+					return
+				}
+				b = b2
+			}
+			matches := searchBytes(b, re)
+			if len(matches) == 0 {
+				return
+			}
+
+			buf := &bytes.Buffer{}
+			ename := parser.EscapeFilename(name)
+			rev.mu.Lock()
+			for _, m := range matches {
+				key := fmt.Sprintf("%v:%v:%v", ename, m.line, m.col)
+				rev.hits[key] = &replaceHit{
+					filename: filename,
+					start:    m.start,
+					end:      m.end,
+					old:      bytes.Clone(b[m.start:m.end]),
+					new:      expand(m),
+				}
+				fmt.Fprintf(buf, "[x] %v: %s\n", key, m.text)
+			}
+			rev.mu.Unlock()
+
+			wmu.Lock()
+			defer wmu.Unlock()
+			nFiles++
+			nMatches += len(matches)
+			_, _ = rw.Write(buf.Bytes())
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(rw, "# %v matches in %v files\n", nMatches, nFiles)
+		return nil
+	})
+
+	return nil
+}
+
+func ReplaceAllApply(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+
+	replaceReviews.Lock()
+	rev, ok := replaceReviews.m[erow]
+	replaceReviews.Unlock()
+	if !ok {
+		return fmt.Errorf("not a ReplaceAll review row")
+	}
+	if erow.Row.HasState(ui.RowStateExecuting) {
+		return fmt.Errorf("review is still being collected")
+	}
+
+	// checked hits grouped by file
+	rev.mu.Lock()
+	files := map[string][]*replaceHit{}
+	for _, line := range strings.Split(erow.Row.TextArea.Str(), "\n") {
+		rest, ok := strings.CutPrefix(line, "[x] ")
+		if !ok {
+			continue
+		}
+		if h, ok := rev.reviewHit(rest); ok {
+			files[h.filename] = append(files[h.filename], h)
+		}
+	}
+	rev.mu.Unlock()
+	if len(files) == 0 {
+		return fmt.Errorf("no checked hits to replace")
+	}
+
+	// apply in a stable order
+	names := []string{}
+	for filename := range files {
+		names = append(names, filename)
+	}
+	slices.Sort(names)
+
+	buf := &bytes.Buffer{}
+	total := 0
+	for _, filename := range names {
+		n, skipped, err := applyReplaceHits(args.Ed, filename, files[filename])
+		name := filename
+		if u, err := filepath.Rel(rev.dir, filename); err == nil {
+			name = u
+		}
+		name = parser.EscapeFilename(name)
+		if err != nil {
+			fmt.Fprintf(buf, "%v: error: %v\n", name, err)
+			continue
+		}
+		fmt.Fprintf(buf, "%v: %v replaced", name, n)
+		if skipped > 0 {
+			fmt.Fprintf(buf, ", %v skipped (content changed)", skipped)
+		}
+		fmt.Fprintf(buf, "\n")
+		total += n
+	}
+	fmt.Fprintf(buf, "# %v replaced in %v files\n", total, len(names))
+
+	// review is done
+	replaceReviews.Lock()
+	delete(replaceReviews.m, erow)
+	replaceReviews.Unlock()
+	erow.Row.TextArea.SetStrClearHistory(buf.String())
+
+	return nil
+}
+
+// Finds the hit of a review line (without the checkbox). The text after the "path:line:col" key can contain ": " as well.
+func (rev *replaceReview) reviewHit(line string) (*replaceHit, bool) {
+	for i := 0; ; {
+		k := strings.Index(line[i:], ": ")
+		if k < 0 {
+			return nil, false
+		}
+		i += k
+		if h, ok := rev.hits[line[:i]]; ok {
+			return h, true
+		}
+		i += 2
+	}
+}
+
+// Replaces the hits in the open rows (as one undoable edit) or directly in the file.
+func applyReplaceHits(ed *core.Editor, filename string, hits []*replaceHit) (int, int, error) {
+	// last to first to keep the previous offsets valid
+	slices.SortFunc(hits, func(a, b *replaceHit) int { return b.start - a.start })
+
+	apply := func(rw ioutil.ReadWriterAt) (int, int, error) {
+		n, skipped := 0, 0
+		prevStart := rw.Max() + 1
+		for _, h := range hits {
+			if h.end > prevStart || !ioutil.HasPrefix(rw, h.start, h.old) {
+				skipped++
+				continue
+			}
+			if err := rw.OverwriteAt(h.start, h.end-h.start, h.new); err != nil {
+				return n, skipped, err
+			}
+			prevStart = h.start
+			n++
+		}
+		return n, skipped, nil
+	}
+
+	if info, ok := ed.ERowInfo(filename); ok && info.IsFileButNotDir() {
+		if erow0, ok := info.FirstERow(); ok {
+			ta := erow0.Row.TextArea
+			ta.BeginUndoGroup()
+			defer ta.EndUndoGroup()
+			return apply(ta.RW())
+		}
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return 0, 0, err
+	}
+	rw := ioutil.NewBytesReadWriterAt(b)
+	n, skipped, err := apply(rw)
+	if err != nil || n == 0 {
+		return n, skipped, err
+	}
+	b2, err := ioutil.ReadFastFull(rw)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := ioutil.WriteFileAtomic(filename, b2); err != nil {
+		return 0, 0, err
+	}
+	return n, skipped, nil
+}
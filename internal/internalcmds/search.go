@@ -0,0 +1,224 @@
+package internalcmds
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/gitignore"
+	"github.com/friedelschoen/glake/internal/ioutil"
+	"github.com/friedelschoen/glake/internal/parser"
+)
+
+func Search(args *core.InternalCmdArgs) error {
+	// setup flagset
+	fs := flag.NewFlagSet("Search", flag.ContinueOnError)
+	fs.SetOutput(io.Discard) // don't output to stderr
+	reFlag := fs.Bool("re", false, "text is a regular expression")
+	icaseFlag := fs.Bool("icase", false, "ignore case: 'a' will also match 'A'")
+	globFlag := fs.String("glob", "", "only search files matching the glob pattern (ex: *.go)")
+	if err := parseFlagSetHandleUsage(args, fs); err != nil {
+		return err
+	}
+
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	if erow.Info.IsSpecial() {
+		return fmt.Errorf("can't run on special row")
+	}
+
+	str := unquotedArgs(fs.Args())
+	if str == "" {
+		return fmt.Errorf("missing search text")
+	}
+	re, err := searchRegexp(str, *reFlag, *icaseFlag)
+	if err != nil {
+		return err
+	}
+	if *globFlag != "" {
+		if _, err := filepath.Match(*globFlag, ""); err != nil {
+			return err
+		}
+	}
+
+	// output on the directory row, or on a new row of the file directory
+	erow2 := erow
+	if !erow.Info.IsDir() {
+		info := erow.Ed.ReadERowInfo(erow.Info.Dir())
+		erow2 = core.NewBasicERow(info, erow.Row.PosBelow())
+		ioutil.Append(erow2.Row.Toolbar.RW(), []byte(" | Stop"))
+		erow2.Flash()
+	}
+
+	dir := erow2.Info.Name()
+	erow2.Exec.RunAsync(func(ctx context.Context, rw io.ReadWriter) error {
+		// NOTE: not running in UI goroutine here
+		return searchDir(ctx, rw, dir, re, *globFlag)
+	})
+
+	return nil
+}
+
+func unquotedArgs(args []string) string {
+	w := []string{}
+	for _, arg := range args {
+		if u, err := parser.UnquoteStringBs(arg); err == nil {
+			arg = u
+		}
+		w = append(w, arg)
+	}
+	return strings.Join(w, " ")
+}
+
+func searchRegexp(str string, isRe, icase bool) (*regexp.Regexp, error) {
+	if !isRe {
+		str = regexp.QuoteMeta(str)
+	}
+	if icase {
+		str = "(?i)" + str
+	}
+	return regexp.Compile(str)
+}
+
+//----------
+
+type searchMatch struct {
+	line, col  int // one-based
+	start, end int // byte offsets in the file
+	text       []byte
+	submatch   []int // submatch indexes relative to text
+}
+
+// Walks dir (honoring .gitignore) and writes "path:line:col: text" lines for every match.
+func searchDir(ctx context.Context, w io.Writer, dir string, re *regexp.Regexp, glob string) error {
+	var wmu sync.Mutex
+	nFiles, nMatches := 0, 0
+
+	err := walkSearchFiles(ctx, dir, glob, func(name string) {
+		matches, err := searchFile(filepath.Join(dir, name), re)
+		if err != nil || len(matches) == 0 {
+			return
+		}
+		buf := &bytes.Buffer{}
+		ename := parser.EscapeFilename(name)
+		for _, m := range matches {
+			fmt.Fprintf(buf, "%v:%v:%v: %s\n", ename, m.line, m.col, m.text)
+		}
+
+		wmu.Lock()
+		defer wmu.Unlock()
+		nFiles++
+		nMatches += len(matches)
+		_, _ = w.Write(buf.Bytes())
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "# %v matches in %v files\n", nMatches, nFiles)
+	return nil
+}
+
+// Runs fn concurrently on every file (relative to dir) to be searched.
+func walkSearchFiles(ctx context.Context, dir, glob string, fn func(name string)) error {
+	names := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range names {
+				if ctx.Err() == nil {
+					fn(name)
+				}
+			}
+		}()
+	}
+
+	err := gitignore.Walk(ctx, dir, func(name string) error {
+		if !matchGlob(glob, name) {
+			return nil
+		}
+		select {
+		case names <- name:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+	close(names)
+	wg.Wait()
+	return err
+}
+
+func matchGlob(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	// patterns with a separator match the full path
+	if strings.ContainsRune(glob, filepath.Separator) {
+		ok, _ := filepath.Match(glob, name)
+		return ok
+	}
+	ok, _ := filepath.Match(glob, filepath.Base(name))
+	return ok
+}
+
+func searchFile(filename string, re *regexp.Regexp) ([]*searchMatch, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return searchBytes(b, re), nil
+}
+
+func searchBytes(b []byte, re *regexp.Regexp) []*searchMatch {
+	if isBinary(b) {
+		return nil
+	}
+
+	res := []*searchMatch{}
+	line, lineStart := 1, 0
+	for lineStart <= len(b) {
+		lineEnd := len(b)
+		if i := bytes.IndexByte(b[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i
+		}
+		text := bytes.TrimRight(b[lineStart:lineEnd], "\r")
+		for _, loc := range re.FindAllSubmatchIndex(text, -1) {
+			if loc[0] == loc[1] {
+				continue // empty match
+			}
+			m := &searchMatch{
+				line:  line,
+				col:   loc[0] + 1,
+				start: lineStart + loc[0],
+				end:   lineStart + loc[1],
+				text:  text,
+
+				submatch: loc,
+			}
+			res = append(res, m)
+		}
+		line++
+		lineStart = lineEnd + 1
+	}
+	return res
+}
+
+// Files with a zero byte in the first block are considered binary.
+func isBinary(b []byte) bool {
+	n := min(len(b), 8000)
+	return bytes.IndexByte(b[:n], 0) >= 0
+}
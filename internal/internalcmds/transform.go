@@ -0,0 +1,414 @@
+package internalcmds
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/friedelschoen/glake/internal/core"
+	"github.com/friedelschoen/glake/internal/ioutil"
+)
+
+type transformOp struct {
+	fn    func(s string, args []string) (string, error)
+	lines bool // operates on whole lines
+	usage string
+}
+
+var transformOps = map[string]*transformOp{
+	"upper":   {fn: noArgs(strings.ToUpper), usage: "upper case"},
+	"lower":   {fn: noArgs(strings.ToLower), usage: "lower case"},
+	"title":   {fn: noArgs(titleCase), usage: "title case"},
+	"camel":   {fn: noArgs(identCase(camelWords)), usage: "camelCase identifiers"},
+	"pascal":  {fn: noArgs(identCase(pascalWords)), usage: "PascalCase identifiers"},
+	"snake":   {fn: noArgs(identCase(sepWords("_"))), usage: "snake_case identifiers"},
+	"kebab":   {fn: noArgs(identCase(sepWords("-"))), usage: "kebab-case identifiers"},
+	"join":    {fn: joinLines, lines: true, usage: "join lines [separator]"},
+	"reverse": {fn: noArgs(linesFn(reverseLines)), lines: true, usage: "reverse lines"},
+	"unique":  {fn: noArgs(linesFn(uniqueLines)), lines: true, usage: "remove duplicate lines"},
+	"trim":    {fn: noArgs(linesFn(trimLines)), lines: true, usage: "trim trailing whitespace"},
+	"align":   {fn: alignLines, lines: true, usage: "align lines on a delimiter <delim>"},
+	"wrap":    {fn: wrapLines, lines: true, usage: "wrap/reflow lines [width], keeping comment prefixes"},
+}
+
+// Transforms the text of the selection, or the current line, as one undo step.
+func Transform(args *core.InternalCmdArgs) error {
+	erow, err := args.ERowOrErr()
+	if err != nil {
+		return err
+	}
+	args2 := args.Part.ArgsUnquoted()[1:]
+	if len(args2) == 0 {
+		return fmt.Errorf("expecting an operation:\n%v", transformUsage())
+	}
+	op, ok := transformOps[args2[0]]
+	if !ok {
+		return fmt.Errorf("unknown operation: %v\n%v", args2[0], transformUsage())
+	}
+
+	ta := erow.Row.TextArea
+	ctx := ta.EditCtx()
+	a, b, ok := ctx.C.SelectionIndexes()
+	if !ok || op.lines {
+		if !ok {
+			a = ctx.C.Index()
+			b = a
+		}
+		a2, b2, err := linesRange(ctx.RW, a, b)
+		if err != nil {
+			return err
+		}
+		a, b = a2, b2
+	}
+	src, err := ctx.RW.ReadFastAt(a, b-a)
+	if err != nil {
+		return err
+	}
+	s := string(src)
+	s2, err := op.fn(s, args2[1:])
+	if err != nil {
+		return err
+	}
+	if s == s2 {
+		return nil
+	}
+
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	if err := ctx.RW.OverwriteAt(a, len(s), []byte(s2)); err != nil {
+		return err
+	}
+	ctx.C.SetSelection(a, a+len(s2))
+	return nil
+}
+
+func transformUsage() string {
+	names := []string{}
+	for name := range transformOps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	u := []string{}
+	for _, name := range names {
+		u = append(u, fmt.Sprintf("\t%v: %v", name, transformOps[name].usage))
+	}
+	return strings.Join(u, "\n")
+}
+
+// Start of the line of a, end of the line of b (without the newline).
+func linesRange(rd ioutil.ReaderAt, a, b int) (int, int, error) {
+	a0, err := ioutil.LineStartIndex(rd, a)
+	if err != nil {
+		return 0, 0, err
+	}
+	// selection ending at the start of a line doesn't include that line
+	if b > a {
+		if ru, _, err := ioutil.ReadLastRuneAt(rd, b); err == nil && ru == '\n' {
+			b--
+		}
+	}
+	b0, isNL, err := ioutil.LineEndIndex(rd, b)
+	if err != nil {
+		return 0, 0, err
+	}
+	if isNL {
+		b0--
+	}
+	return a0, b0, nil
+}
+
+//----------
+
+func noArgs(fn func(string) string) func(string, []string) (string, error) {
+	return func(s string, args []string) (string, error) {
+		if len(args) > 0 {
+			return "", fmt.Errorf("unexpected arguments: %v", args)
+		}
+		return fn(s), nil
+	}
+}
+
+func linesFn(fn func([]string) []string) func(string) string {
+	return func(s string) string {
+		u := strings.Split(s, "\n")
+		return strings.Join(fn(u), "\n")
+	}
+}
+
+//----------
+
+func titleCase(s string) string {
+	rs := []rune(s)
+	start := true
+	for i, ru := range rs {
+		if unicode.IsLetter(ru) || unicode.IsDigit(ru) || ru == '\'' {
+			if start {
+				rs[i] = unicode.ToTitle(ru)
+			} else {
+				rs[i] = unicode.ToLower(ru)
+			}
+			start = false
+		} else {
+			start = true
+		}
+	}
+	return string(rs)
+}
+
+// Applies fn to the words of each identifier (runs of letters, digits, '_' and '-').
+func identCase(fn func(words []string) string) func(string) string {
+	return func(s string) string {
+		isIdent := func(ru rune) bool {
+			return unicode.IsLetter(ru) || unicode.IsDigit(ru) || ru == '_' || ru == '-'
+		}
+		sb := &strings.Builder{}
+		for len(s) > 0 {
+			k := strings.IndexFunc(s, func(ru rune) bool { return !isIdent(ru) })
+			if k < 0 {
+				k = len(s)
+			}
+			if k > 0 {
+				// keep leading/trailing separators (ex: "_private", "--flag")
+				id := s[:k]
+				t := strings.Trim(id, "_-")
+				if t == "" {
+					sb.WriteString(id)
+				} else {
+					i := strings.Index(id, t)
+					sb.WriteString(id[:i])
+					sb.WriteString(fn(identWords(t)))
+					sb.WriteString(id[i+len(t):])
+				}
+				s = s[k:]
+				continue
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			sb.WriteString(s[:size])
+			s = s[size:]
+		}
+		return sb.String()
+	}
+}
+
+// Splits at '_', '-', lower to upper case changes, and before the last upper case rune of an acronym (ex: "HTTPServer": "HTTP", "Server").
+func identWords(s string) []string {
+	words := []string{}
+	rs := []rune(s)
+	start := 0
+	add := func(end int) {
+		if end > start {
+			words = append(words, string(rs[start:end]))
+		}
+	}
+	for i, ru := range rs {
+		switch {
+		case ru == '_' || ru == '-':
+			add(i)
+			start = i + 1
+		case i > start && unicode.IsUpper(ru):
+			prev := rs[i-1]
+			nextLower := i+1 < len(rs) && unicode.IsLower(rs[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				add(i)
+				start = i
+			}
+		}
+	}
+	add(len(rs))
+	return words
+}
+
+func camelWords(words []string) string {
+	if len(words) == 0 {
+		return ""
+	}
+	return strings.ToLower(words[0]) + pascalWords(words[1:])
+}
+
+func pascalWords(words []string) string {
+	sb := &strings.Builder{}
+	for _, w := range words {
+		sb.WriteString(capitalize(w))
+	}
+	return sb.String()
+}
+
+func sepWords(sep string) func([]string) string {
+	return func(words []string) string {
+		u := make([]string, len(words))
+		for i, w := range words {
+			u[i] = strings.ToLower(w)
+		}
+		return strings.Join(u, sep)
+	}
+}
+
+func capitalize(w string) string {
+	ru, size := utf8.DecodeRuneInString(w)
+	return string(unicode.ToUpper(ru)) + strings.ToLower(w[size:])
+}
+
+//----------
+
+func joinLines(s string, args []string) (string, error) {
+	sep := " "
+	switch len(args) {
+	case 0:
+	case 1:
+		sep = args[0]
+	default:
+		return "", fmt.Errorf("expecting at most one separator")
+	}
+	u := strings.Split(s, "\n")
+	for i := range u {
+		if i > 0 {
+			u[i] = strings.TrimLeft(u[i], " \t")
+		}
+		if i < len(u)-1 {
+			u[i] = strings.TrimRight(u[i], " \t")
+		}
+	}
+	return strings.Join(u, sep), nil
+}
+
+func reverseLines(u []string) []string {
+	slices.Reverse(u)
+	return u
+}
+
+func uniqueLines(u []string) []string {
+	seen := map[string]bool{}
+	w := []string{}
+	for _, s := range u {
+		if !seen[s] {
+			seen[s] = true
+			w = append(w, s)
+		}
+	}
+	return w
+}
+
+func trimLines(u []string) []string {
+	for i, s := range u {
+		u[i] = strings.TrimRight(s, " \t\r")
+	}
+	return u
+}
+
+func alignLines(s string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("expecting a delimiter")
+	}
+	delim := args[0]
+	u := strings.Split(s, "\n")
+	col := 0
+	for _, l := range u {
+		if k := strings.Index(l, delim); k >= 0 {
+			col = max(col, textWidth(strings.TrimRight(l[:k], " \t")))
+		}
+	}
+	for i, l := range u {
+		k := strings.Index(l, delim)
+		if k < 0 {
+			continue
+		}
+		left := strings.TrimRight(l[:k], " \t")
+		right := strings.TrimLeft(l[k+len(delim):], " \t")
+		l2 := left + strings.Repeat(" ", col-textWidth(left)) + " " + delim
+		if right != "" {
+			l2 += " " + right
+		}
+		u[i] = l2
+	}
+	return strings.Join(u, "\n"), nil
+}
+
+// Reflows paragraphs (separated by empty lines) to the width. The prefix of the first line of each paragraph (indentation and comment symbols) is repeated on each line.
+func wrapLines(s string, args []string) (string, error) {
+	width := 80
+	switch len(args) {
+	case 0:
+	case 1:
+		v, err := strconv.Atoi(args[0])
+		if err != nil || v <= 0 {
+			return "", fmt.Errorf("bad width: %v", args[0])
+		}
+		width = v
+	default:
+		return "", fmt.Errorf("expecting at most a width")
+	}
+
+	out := []string{}
+	para := []string{}
+	prefix := ""
+	flush := func() {
+		if len(para) == 0 {
+			return
+		}
+		words := strings.Fields(strings.Join(para, " "))
+		line := ""
+		for _, w := range words {
+			if line != "" && textWidth(prefix+line+" "+w) > width {
+				out = append(out, prefix+line)
+				line = ""
+			}
+			if line != "" {
+				line += " "
+			}
+			line += w
+		}
+		out = append(out, prefix+line)
+		para = nil
+	}
+	for _, l := range strings.Split(s, "\n") {
+		p := wrapPrefix(l)
+		text := strings.TrimSpace(l[len(p):])
+		if text == "" {
+			flush()
+			out = append(out, strings.TrimRight(l, " \t"))
+			continue
+		}
+		if len(para) == 0 {
+			prefix = p
+		} else if strings.TrimSpace(p) != strings.TrimSpace(prefix) {
+			// different comment prefix, starts a new paragraph
+			flush()
+			prefix = p
+		}
+		para = append(para, text)
+	}
+	flush()
+	return strings.Join(out, "\n"), nil
+}
+
+// Indentation followed by a comment/quote symbol and a space.
+func wrapPrefix(l string) string {
+	i := len(l) - len(strings.TrimLeft(l, " \t"))
+	for _, sym := range []string{"///", "//", "#", "--", ";;", ";", "%", ">", "*"} {
+		if strings.HasPrefix(l[i:], sym) {
+			i += len(sym)
+			if i < len(l) && l[i] == ' ' {
+				i++
+			}
+			break
+		}
+	}
+	return l[:i]
+}
+
+// Width in columns, tabs to the next multiple of 8.
+func textWidth(s string) int {
+	w := 0
+	for _, ru := range s {
+		if ru == '\t' {
+			w += 8 - w%8
+		} else {
+			w++
+		}
+	}
+	return w
+}
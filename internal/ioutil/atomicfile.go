@@ -0,0 +1,119 @@
+package ioutil
+
+import (
+	"errors"
+	"io/fs"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Writes to a temporary file in the same directory and renames it over filename, so readers never see a partially written file. Symlinks are followed. Keeps the mode, owner and extended attributes of an existing file. Files that can't be replaced without changing them (hard links, owner or attributes that can't be kept, directory not writable) are written in place.
+func WriteFileAtomic(filename string, b []byte) error {
+	if fn, err := filepath.EvalSymlinks(filename); err == nil {
+		filename = fn
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		fi = nil
+	}
+	if fi != nil && (!fi.Mode().IsRegular() || hardLinked(fi)) {
+		return writeFileInPlace(filename, b, fi)
+	}
+
+	// new files get the default mode with the umask, existing files get their mode after the chown
+	perm := os.FileMode(0644)
+	if fi != nil {
+		perm = 0600
+	}
+	f, err := createTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".tmp", perm)
+	if err != nil {
+		if fi != nil && errors.Is(err, fs.ErrPermission) {
+			return writeFileInPlace(filename, b, fi)
+		}
+		return err
+	}
+	tmp := f.Name()
+	fail := func(err error) error {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if fi != nil {
+		if err := copyOwner(f, fi); err != nil {
+			_ = fail(err)
+			return writeFileInPlace(filename, b, fi)
+		}
+		if err := copyXattrs(filename, tmp); err != nil {
+			_ = fail(err)
+			return writeFileInPlace(filename, b, fi)
+		}
+	}
+	if fi != nil {
+		// after chown, which clears the setuid/setgid bits
+		mode := fi.Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+		if err := f.Chmod(mode); err != nil {
+			return fail(err)
+		}
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// Like os.CreateTemp, but with the permissions (before the umask) of the new file.
+func createTemp(dir, prefix string, perm os.FileMode) (*os.File, error) {
+	for try := 0; ; try++ {
+		name := filepath.Join(dir, prefix+strconv.FormatUint(uint64(rand.Uint32()), 10))
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, perm)
+		if os.IsExist(err) && try < 10000 {
+			continue
+		}
+		return f, err
+	}
+}
+
+func writeFileInPlace(filename string, b []byte, fi os.FileInfo) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_TRUNC, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if fi.Mode().IsRegular() {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	return f.Close()
+}
+
+// Best effort, makes the rename durable.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -0,0 +1,309 @@
+package ioutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+var ErrHexOverwrite = errors.New("hex view: only overwriting is supported")
+
+// Hex dump of the bytes of a ReadWriterAt, one line per 16 bytes:
+//
+//	00000010  68 65 6c 6c 6f 0a 00 00  00 00 00 00 00 00 00 00  |hello...........|
+//
+// All lines have the same width (the last one is padded with spaces). Writes must overwrite the same number of bytes; changed hex cells (or ascii cells, if the hex cell didn't change) are written to the bytes. The offset column and separators can't be changed.
+type HexView struct {
+	rw ReadWriterAt
+}
+
+const (
+	hexLineBytes = 16
+	hexMinDigits = 8
+)
+
+func NewHexView(rw ReadWriterAt) *HexView {
+	return &HexView{rw: rw}
+}
+
+// The viewed bytes.
+func (hv *HexView) Data() ReadWriterAt { return hv.rw }
+
+// Number of viewed bytes.
+func (hv *HexView) Size() int { return hv.rw.Max() - hv.rw.Min() }
+
+//----------
+
+func (hv *HexView) digits() int {
+	n := (bits.Len(uint(hv.Size())) + 3) / 4
+	return max(n, hexMinDigits)
+}
+
+// layout of a line
+func (hv *HexView) hexStart() int   { return hv.digits() + 2 }
+func (hv *HexView) asciiStart() int { return hv.hexStart() + hexLineBytes*3 + 3 }
+func (hv *HexView) lineWidth() int  { return hv.asciiStart() + hexLineBytes + 2 }
+
+func hexCellOffset(k int) int {
+	o := k * 3
+	if k >= hexLineBytes/2 {
+		o++
+	}
+	return o
+}
+
+func (hv *HexView) nLines() int {
+	return (hv.Size() + hexLineBytes - 1) / hexLineBytes
+}
+
+// Index of the first hex digit of the byte.
+func (hv *HexView) HexIndex(bi int) int {
+	l, k := bi/hexLineBytes, bi%hexLineBytes
+	return l*hv.lineWidth() + hv.hexStart() + hexCellOffset(k)
+}
+
+// Index of the ascii char of the byte.
+func (hv *HexView) ASCIIIndex(bi int) int {
+	l, k := bi/hexLineBytes, bi%hexLineBytes
+	return l*hv.lineWidth() + hv.asciiStart() + k
+}
+
+// Byte at the index, in the hex (with the nibble) or ascii column. Indexes outside the cells are moved to the next cell, or the last cell at the end.
+func (hv *HexView) Locate(i int) (bi int, ascii bool, nibble int) {
+	size := hv.Size()
+	if size == 0 {
+		return 0, false, 0
+	}
+	lw := hv.lineWidth()
+	l, c := i/lw, i%lw
+	hs, as := hv.hexStart(), hv.asciiStart()
+	switch {
+	case i < 0:
+		return 0, false, 0
+	case c < hs:
+		bi = l * hexLineBytes
+	case c < as-2:
+		c -= hs
+		k := c / 3
+		if c >= hexCellOffset(hexLineBytes/2) {
+			k = (c - 1) / 3
+		}
+		o := c - hexCellOffset(k)
+		if o < 0 { // extra space between the halves
+			o = 0
+		} else if o > 1 { // space after the cell (next line after the last)
+			k, o = k+1, 0
+		}
+		bi, nibble = l*hexLineBytes+k, o
+	case c < as+hexLineBytes:
+		bi, ascii = l*hexLineBytes+max(0, c-as), true
+	default:
+		bi, ascii = (l+1)*hexLineBytes, true
+	}
+	if bi >= size {
+		bi, nibble = size-1, 0
+		if !ascii && i > hv.HexIndex(bi) {
+			nibble = 1
+		}
+	}
+	return bi, ascii, nibble
+}
+
+//----------
+
+// Implement ReaderAt
+func (hv *HexView) Min() int { return 0 }
+
+// Implement ReaderAt
+func (hv *HexView) Max() int { return hv.nLines() * hv.lineWidth() }
+
+// Implement ReaderAt
+func (hv *HexView) ReadFastAt(i, n int) ([]byte, error) {
+	max := hv.Max()
+	if i < 0 {
+		return nil, fmt.Errorf("bad index: %v<0", i)
+	}
+	if i > max {
+		return nil, fmt.Errorf("bad index: %v>%v", i, max)
+	}
+	// before "i==max" to allow reading an empty buffer (ex: readfull("") without err)
+	if n == 0 {
+		return nil, nil
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("bad arg: %v<0", n)
+	}
+	if i == max {
+		return nil, io.EOF
+	}
+	if i+n > max {
+		n = max - i
+	}
+
+	lw := hv.lineWidth()
+	l0, l1 := i/lw, (i+n-1)/lw
+	b, err := hv.render(l0, l1+1)
+	if err != nil {
+		return nil, err
+	}
+	o := i - l0*lw
+	return b[o : o+n], nil
+}
+
+// Renders the lines [l0,l1).
+func (hv *HexView) render(l0, l1 int) ([]byte, error) {
+	a := hv.rw.Min() + l0*hexLineBytes
+	b := min(hv.rw.Min()+l1*hexLineBytes, hv.rw.Max())
+	data, err := ReadFastFull(NewLimitedReaderAt(hv.rw, a, b))
+	if err != nil {
+		return nil, err
+	}
+
+	digits, hs, as, lw := hv.digits(), hv.hexStart(), hv.asciiStart(), hv.lineWidth()
+	buf := make([]byte, (l1-l0)*lw)
+	for i := range buf {
+		buf[i] = ' '
+	}
+	const hexDigits = "0123456789abcdef"
+	for l := l0; l < l1; l++ {
+		line := buf[(l-l0)*lw : (l-l0+1)*lw]
+		off := l * hexLineBytes
+		for j := digits - 1; j >= 0; j-- {
+			line[j] = hexDigits[off&0xf]
+			off >>= 4
+		}
+		line[as-1] = '|'
+		line[as+hexLineBytes] = '|'
+		line[lw-1] = '\n'
+		for k := 0; k < hexLineBytes; k++ {
+			j := (l-l0)*hexLineBytes + k
+			if j >= len(data) {
+				break
+			}
+			c := data[j]
+			ho := hs + hexCellOffset(k)
+			line[ho] = hexDigits[c>>4]
+			line[ho+1] = hexDigits[c&0xf]
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			line[as+k] = c
+		}
+	}
+	return buf, nil
+}
+
+// Implement WriterAt
+func (hv *HexView) OverwriteAt(i, del int, p []byte) error {
+	if i < 0 || del < 0 {
+		return fmt.Errorf("hex view: bad index/del: %v, %v", i, del)
+	}
+	if i+del > hv.Max() {
+		return fmt.Errorf("hex view: del %v>%v", i+del, hv.Max())
+	}
+	if del != len(p) {
+		return ErrHexOverwrite
+	}
+	if del == 0 {
+		return nil
+	}
+
+	lw := hv.lineWidth()
+	l0, l1 := i/lw, (i+del-1)/lw+1
+	old, err := hv.render(l0, l1)
+	if err != nil {
+		return err
+	}
+	buf2 := make([]byte, len(old))
+	copy(buf2, old)
+	copy(buf2[i-l0*lw:], p)
+
+	// positions that are cells
+	hs, as := hv.hexStart(), hv.asciiStart()
+	isCell := make([]bool, lw)
+	for k := 0; k < hexLineBytes; k++ {
+		isCell[hs+hexCellOffset(k)] = true
+		isCell[hs+hexCellOffset(k)+1] = true
+		isCell[as+k] = true
+	}
+
+	size := hv.Size()
+	a0 := l0 * hexLineBytes
+	data := make([]byte, 0, (l1-l0)*hexLineBytes)
+	first, last := -1, -1
+	for l := l0; l < l1; l++ {
+		o, n := old[(l-l0)*lw:(l-l0+1)*lw], buf2[(l-l0)*lw:(l-l0+1)*lw]
+		for j := range o {
+			if o[j] != n[j] && !isCell[j] {
+				return fmt.Errorf("hex view: read-only position: %v", l*lw+j)
+			}
+		}
+		for k := 0; k < hexLineBytes; k++ {
+			bi := l*hexLineBytes + k
+			ho := hs + hexCellOffset(k)
+			hexChanged := o[ho] != n[ho] || o[ho+1] != n[ho+1]
+			asciiChanged := o[as+k] != n[as+k]
+			if bi >= size {
+				if hexChanged || asciiChanged {
+					return fmt.Errorf("hex view: write after the end: %v", l*lw+ho)
+				}
+				continue
+			}
+			c, err := hexCellByte(o[ho : ho+2])
+			if err != nil {
+				return err
+			}
+			switch {
+			case hexChanged:
+				c, err = hexCellByte(n[ho : ho+2])
+				if err != nil {
+					return err
+				}
+			case asciiChanged:
+				c = n[as+k]
+				if c < 0x20 || c > 0x7e {
+					return fmt.Errorf("hex view: not a printable ascii char: %q", c)
+				}
+			default:
+				data = append(data, c)
+				continue
+			}
+			data = append(data, c)
+			if first < 0 {
+				first = bi
+			}
+			last = bi
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	w := data[first-a0 : last-a0+1]
+	m := hv.rw.Min()
+	return hv.rw.OverwriteAt(m+first, len(w), w)
+}
+
+func hexCellByte(b []byte) (byte, error) {
+	v := byte(0)
+	for _, c := range b {
+		d, ok := HexDigit(rune(c))
+		if !ok {
+			return 0, fmt.Errorf("hex view: not a hex digit: %q", c)
+		}
+		v = v<<4 | d
+	}
+	return v, nil
+}
+
+func HexDigit(ru rune) (byte, bool) {
+	switch {
+	case ru >= '0' && ru <= '9':
+		return byte(ru - '0'), true
+	case ru >= 'a' && ru <= 'f':
+		return byte(ru - 'a' + 10), true
+	case ru >= 'A' && ru <= 'F':
+		return byte(ru - 'A' + 10), true
+	}
+	return 0, false
+}
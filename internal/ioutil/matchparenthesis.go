@@ -0,0 +1,99 @@
+package ioutil
+
+import (
+	"strings"
+)
+
+// Finds the parenthesis at index ci (or before) and its matching pair, reading at most pad bytes around ci. Returns the sorted positions, only one if the pair was not found.
+func MatchParenthesis(rd ReaderAt, ci, pad int) ([]int, bool) {
+	r := NewLimitedReaderAtPad(rd, ci, ci, pad)
+
+	sc := NewScanner(r)
+	pos0 := sc.ValidPos(ci)
+
+	// match a parenthesis
+	pairs := []rune("(){}[]")
+	vk := sc.NewValueKeeper()
+	parseOpen := vk.WKeepValue(sc.W.RuneValue(sc.W.RuneOneOf(pairs)))
+	_, err := parseOpen(pos0)
+	if err != nil {
+		//return nil // error: no results returned
+
+		// try reading previous
+		if p3, err2 := sc.M.ReverseMode(pos0, true, parseOpen); err2 != nil {
+			return nil, false // error: no results returned
+		} else {
+			pos0 = p3
+		}
+	}
+
+	// pos0 is at the left side of the rune
+	openPos := pos0
+
+	// resolve open/close runes
+	sym := vk.V.(rune)
+	k := strings.Index(string(pairs), string(sym))
+	isOpen := k%2 == 0
+	if isOpen {
+		k++
+	} else {
+		k--
+	}
+	openRu, closeRu := sym, pairs[k]
+	reverse := !isOpen
+	if reverse {
+		pos0++ // to read the open rune again
+	}
+
+	// match parenthesis
+	stk := 0
+	done := false
+	closePos := 0
+	pushOpen := func(pos int) (int, error) {
+		stk++
+		return pos, nil
+	}
+	popClose := func(pos int) (int, error) {
+		stk--
+		if stk == 0 {
+			done = true
+			closePos = pos
+			if !reverse {
+				closePos--
+			}
+		}
+		return pos, nil
+	}
+	_, _ = sc.M.ReverseMode(pos0,
+		reverse,
+		sc.W.Loop(sc.W.And(
+			sc.W.PtrFalse(&done),
+			sc.W.Or(
+
+				// might not work well (forward vs reverse)
+				// sc.W.QuotedString(),
+
+				sc.W.And(
+					sc.W.Rune(openRu),
+					pushOpen,
+				),
+				sc.W.And(
+					sc.W.Rune(closeRu),
+					popClose,
+				),
+				sc.M.OneRune,
+			),
+		)),
+	)
+
+	// sort points
+	points := []int{openPos}
+	hasClosePos := done
+	if hasClosePos {
+		points = append(points, closePos)
+		if reverse {
+			points[0], points[1] = points[1], points[0]
+		}
+	}
+	return points, true
+}
@@ -0,0 +1,72 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+var ErrReadOnly = errors.New("read-only")
+
+// Read-only view of a memory mapped file. Reads copy the data: the file can be truncated by another program while mapped (reading the missing part faults, and is returned as an error), and the returned slices stay valid after the file is unmapped.
+type MmapFile struct {
+	*BytesReadWriterAt
+	data []byte
+}
+
+func OpenMmapFile(filename string) (*MmapFile, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size <= 0 || int64(int(size)) != size {
+		return nil, fmt.Errorf("mmap: bad size: %v", size)
+	}
+	data, err := mmap(f, int(size))
+	if err != nil {
+		return nil, fmt.Errorf("mmap: %w", err)
+	}
+	return &MmapFile{BytesReadWriterAt: NewBytesReadWriterAt(data), data: data}, nil
+}
+
+// Implement ReaderAt
+func (m *MmapFile) ReadFastAt(i, n int) (b []byte, err error) {
+	defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(interface{ Addr() uintptr }); !ok {
+				panic(r) // not a memory fault
+			}
+			b, err = nil, fmt.Errorf("mmap: read fault at %v, file truncated?", i)
+		}
+	}()
+	b, err = m.BytesReadWriterAt.ReadFastAt(i, n)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.Clone(b), nil
+}
+
+// Implement WriterAt
+func (m *MmapFile) OverwriteAt(i, del int, p []byte) error {
+	return ErrReadOnly
+}
+
+// Unmaps the file. Should not be called while reads might be running in other goroutines.
+func (m *MmapFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	m.BytesReadWriterAt = NewBytesReadWriterAt(nil)
+	data := m.data
+	m.data = nil
+	return munmap(data)
+}
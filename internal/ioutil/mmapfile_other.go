@@ -0,0 +1,16 @@
+//go:build !unix
+
+package ioutil
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, size int) ([]byte, error) {
+	return nil, errors.New("not supported")
+}
+
+func munmap(b []byte) error {
+	return nil
+}
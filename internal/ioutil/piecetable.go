@@ -0,0 +1,206 @@
+package ioutil
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Piece table: the text is a sequence of pieces (byte slices that are never changed), so inserting or deleting doesn't move the rest of the text. Pieces created by inserts are extended while typing at their end. Reads spanning several pieces merge them, so later reads of the same range don't copy.
+type PieceTable struct {
+	mu     sync.Mutex // reads can change the pieces
+	pieces []piece
+	starts []int // text offset of each piece
+	size   int
+}
+
+type piece struct {
+	b   []byte
+	ins bool // created by an insert, can be extended with append
+}
+
+// Pieces limit before merging all into one.
+const maxPieces = 4096
+
+func NewPieceTable(b []byte) *PieceTable {
+	pt := &PieceTable{}
+	pt.reset(b)
+	return pt
+}
+
+func (pt *PieceTable) reset(b []byte) {
+	pt.pieces = pt.pieces[:0]
+	if len(b) > 0 {
+		b2 := make([]byte, len(b))
+		copy(b2, b)
+		pt.pieces = append(pt.pieces, piece{b: b2})
+	}
+	pt.updateStarts(0)
+}
+
+// Implement ReaderAt
+func (pt *PieceTable) ReadFastAt(i, n int) ([]byte, error) {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if i < 0 {
+		return nil, fmt.Errorf("bad index: %v<0", i)
+	}
+	if i > pt.size {
+		return nil, fmt.Errorf("bad index: %v>%v", i, pt.size)
+	}
+
+	// before "i==size" to allow reading an empty buffer (ex: readfull("") without err)
+	if n == 0 {
+		return nil, nil
+	}
+	if n < 0 {
+		return nil, fmt.Errorf("bad arg: %v<0", n)
+	}
+
+	if i == pt.size {
+		return nil, io.EOF
+	}
+
+	// i>=0 && i<size && n>=0 -> n>=1
+	if i+n > pt.size {
+		n = pt.size - i
+	}
+
+	k := pt.pieceIndex(i)
+	off := i - pt.starts[k]
+	if b := pt.pieces[k].b; off+n <= len(b) {
+		return b[off : off+n : off+n], nil
+	}
+	m := pt.pieceIndex(i + n - 1)
+	if size := pt.starts[m] + len(pt.pieces[m].b) - pt.starts[k]; n*2 < size {
+		// small read of big pieces, copy without merging
+		b := make([]byte, 0, n)
+		b = append(b, pt.pieces[k].b[off:]...)
+		for _, p := range pt.pieces[k+1 : m+1] {
+			b = append(b, p.b[:min(len(p.b), n-len(b))]...)
+		}
+		return b, nil
+	}
+	b := pt.merge(k, m)
+	return b[off : off+n : off+n], nil
+}
+
+// Merges the pieces [k,m] into one.
+func (pt *PieceTable) merge(k, m int) []byte {
+	size := pt.starts[m] + len(pt.pieces[m].b) - pt.starts[k]
+	b := make([]byte, 0, size)
+	for _, p := range pt.pieces[k : m+1] {
+		b = append(b, p.b...)
+	}
+	pt.pieces[k] = piece{b: b}
+	pt.pieces = append(pt.pieces[:k+1], pt.pieces[m+1:]...)
+	pt.updateStarts(k)
+	return b
+}
+
+// Implement ReaderAt
+func (pt *PieceTable) Min() int { return 0 }
+
+// Implement ReaderAt
+func (pt *PieceTable) Max() int {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+	return pt.size
+}
+
+// Implement WriterAt
+func (pt *PieceTable) OverwriteAt(i, del int, p []byte) error {
+	pt.mu.Lock()
+	defer pt.mu.Unlock()
+
+	if i < 0 || del < 0 {
+		return fmt.Errorf("iorw.OverwriteAt: bad index/del: %v, %v", i, del)
+	}
+	if i+del > pt.size {
+		return fmt.Errorf("iorw.OverwriteAt: del %v>%v", i+del, pt.size)
+	}
+
+	// replace all (ex: loading a file)
+	if i == 0 && del == pt.size {
+		pt.reset(p)
+		return nil
+	}
+
+	// delete
+	k := pt.split(i)
+	if del > 0 {
+		m := pt.split(i + del)
+		pt.pieces = append(pt.pieces[:k], pt.pieces[m:]...)
+	}
+
+	// insert
+	if len(p) > 0 {
+		if k > 0 && pt.pieces[k-1].ins {
+			pt.pieces[k-1].b = append(pt.pieces[k-1].b, p...)
+			k--
+		} else {
+			b := make([]byte, len(p), max(len(p), 64))
+			copy(b, p)
+			pt.pieces = append(pt.pieces, piece{})
+			copy(pt.pieces[k+1:], pt.pieces[k:])
+			pt.pieces[k] = piece{b: b, ins: true}
+		}
+	}
+
+	pt.updateStarts(max(0, k-1))
+	if len(pt.pieces) > maxPieces {
+		pt.merge(0, len(pt.pieces)-1)
+	}
+	return nil
+}
+
+// Ensures a piece starts at i. Returns the index of that piece (or the number of pieces if i is the end).
+func (pt *PieceTable) split(i int) int {
+	if i >= pt.size {
+		return len(pt.pieces)
+	}
+	k := pt.pieceIndex(i)
+	off := i - pt.starts[k]
+	if off == 0 {
+		return k
+	}
+	p := pt.pieces[k]
+	// limit the capacity of the first part so extending it doesn't overwrite the second
+	pt.pieces[k] = piece{b: p.b[:off:off], ins: p.ins}
+	pt.pieces = append(pt.pieces, piece{})
+	copy(pt.pieces[k+2:], pt.pieces[k+1:])
+	pt.pieces[k+1] = piece{b: p.b[off:], ins: p.ins}
+	pt.updateStarts(k + 1)
+	return k + 1
+}
+
+// Index of the piece containing i (i<size).
+func (pt *PieceTable) pieceIndex(i int) int {
+	return sort.Search(len(pt.starts), func(j int) bool {
+		return pt.starts[j] > i
+	}) - 1
+}
+
+func (pt *PieceTable) updateStarts(k int) {
+	// drop empty pieces
+	w := pt.pieces[:k]
+	for _, p := range pt.pieces[k:] {
+		if len(p.b) > 0 {
+			w = append(w, p)
+		}
+	}
+	pt.pieces = w
+
+	pt.starts = pt.starts[:min(k, len(pt.starts))]
+	s := 0
+	if k > 0 {
+		s = pt.starts[k-1] + len(pt.pieces[k-1].b)
+	}
+	for _, p := range pt.pieces[k:] {
+		pt.starts = append(pt.starts, s)
+		s += len(p.b)
+	}
+	pt.size = s
+}
@@ -0,0 +1,212 @@
+package ioutil
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"testing"
+)
+
+// Random edits and reads compared with a BytesReadWriterAt.
+func TestPieceTableRandom(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	text := func(n int) []byte {
+		b := make([]byte, n)
+		for i := range b {
+			b[i] = byte('a' + rnd.IntN(26))
+		}
+		return b
+	}
+
+	init := text(1000)
+	pt := NewPieceTable(init)
+	rw := NewBytesReadWriterAt(bytes.Clone(init))
+
+	// slices returned by reads, with a copy of their content
+	type read struct{ b, want []byte }
+	reads := []read{}
+
+	for k := 0; k < 20000; k++ {
+		size := rw.Max()
+		i := rnd.IntN(size + 1)
+		switch op := rnd.IntN(10); {
+		case op < 4: // insert, often continuing the previous insert
+			p := text(1 + rnd.IntN(20))
+			mustOverwrite(t, pt, rw, i, 0, p)
+		case op < 6: // delete
+			n := rnd.IntN(min(50, size-i) + 1)
+			mustOverwrite(t, pt, rw, i, n, nil)
+		case op < 7: // replace
+			n := rnd.IntN(min(50, size-i) + 1)
+			mustOverwrite(t, pt, rw, i, n, text(rnd.IntN(20)))
+		default: // read, small (copy) or big (merge)
+			n := rnd.IntN(min(30, size-i) + 1)
+			if rnd.IntN(4) == 0 {
+				n = size - i
+			}
+			b, err := pt.ReadFastAt(i, n)
+			if err != nil && !(i == size && n > 0) {
+				t.Fatalf("read %v,%v: %v", i, n, err)
+			}
+			want, _ := rw.ReadFastAt(i, n)
+			if !bytes.Equal(b, want) {
+				t.Fatalf("read %v,%v: %q, want %q", i, n, b, want)
+			}
+			if len(reads) < 200 {
+				reads = append(reads, read{b, bytes.Clone(b)})
+			}
+		}
+	}
+	checkPieceTable(t, pt, rw)
+
+	// later edits (ex: appending to an insert piece) don't change returned slices
+	for _, r := range reads {
+		if !bytes.Equal(r.b, r.want) {
+			t.Fatalf("read slice changed: %q, want %q", r.b, r.want)
+		}
+	}
+}
+
+func TestPieceTableReadAcrossPieces(t *testing.T) {
+	pt := NewPieceTable(bytes.Repeat([]byte("a"), 100))
+	rw := NewBytesReadWriterAt(bytes.Repeat([]byte("a"), 100))
+	for i := 90; i > 0; i -= 10 {
+		mustOverwrite(t, pt, rw, i, 0, []byte("b"))
+	}
+	n := len(pt.pieces)
+
+	// small read of big pieces: copied, the pieces are kept
+	b, err := pt.ReadFastAt(8, 5)
+	if err != nil || !bytes.Equal(b, []byte("aabaa")) {
+		t.Fatalf("copy read: %q, %v", b, err)
+	}
+	if len(pt.pieces) != n {
+		t.Fatalf("copy read merged the pieces: %v, want %v", len(pt.pieces), n)
+	}
+
+	// big read: merged, the next read doesn't copy
+	b, err = pt.ReadFastAt(5, 80)
+	want, _ := ReadFullCopy(NewLimitedReaderAt(rw, 5, 85))
+	if err != nil || !bytes.Equal(b, want) {
+		t.Fatalf("merge read: %q, %v", b, err)
+	}
+	if len(pt.pieces) >= n {
+		t.Fatalf("merge read didn't merge the pieces: %v", len(pt.pieces))
+	}
+	b2, _ := pt.ReadFastAt(6, 20)
+	if &b2[0] != &b[1] {
+		t.Fatalf("read of merged piece was copied")
+	}
+
+	// appending to the piece before doesn't change the read
+	mustOverwrite(t, pt, rw, 5, 0, []byte("ccc"))
+	if !bytes.Equal(b, want) {
+		t.Fatalf("read changed: %q, want %q", b, want)
+	}
+	checkPieceTable(t, pt, rw)
+}
+
+func TestPieceTableMaxPieces(t *testing.T) {
+	pt := NewPieceTable(bytes.Repeat([]byte("a"), maxPieces*2))
+	rw := NewBytesReadWriterAt(bytes.Repeat([]byte("a"), maxPieces*2))
+	// inserts not continuing the previous one, each splits a piece
+	merged := false
+	for i := 0; i < maxPieces; i++ {
+		n := len(pt.pieces)
+		mustOverwrite(t, pt, rw, rw.Max()-i*3, 0, []byte("b"))
+		if len(pt.pieces) > maxPieces {
+			t.Fatalf("pieces: %v>%v", len(pt.pieces), maxPieces)
+		}
+		if len(pt.pieces) < n {
+			merged = true
+			checkPieceTable(t, pt, rw)
+		}
+	}
+	if !merged {
+		t.Fatalf("pieces not merged: %v", len(pt.pieces))
+	}
+	checkPieceTable(t, pt, rw)
+}
+
+func mustOverwrite(t *testing.T, pt *PieceTable, rw *BytesReadWriterAt, i, n int, p []byte) {
+	t.Helper()
+	if err := rw.OverwriteAt(i, n, p); err != nil {
+		t.Fatal(err)
+	}
+	if err := pt.OverwriteAt(i, n, p); err != nil {
+		t.Fatalf("overwrite %v,%v,%q: %v", i, n, p, err)
+	}
+	if pt.Max() != rw.Max() {
+		t.Fatalf("overwrite %v,%v,%q: size %v, want %v", i, n, p, pt.Max(), rw.Max())
+	}
+}
+
+// Compares the content, without reading all at once (would merge the pieces).
+func checkPieceTable(t *testing.T, pt *PieceTable, rw *BytesReadWriterAt) {
+	t.Helper()
+	b := []byte{}
+	for _, p := range pt.pieces {
+		if len(p.b) == 0 {
+			t.Fatalf("empty piece")
+		}
+		b = append(b, p.b...)
+	}
+	want, _ := ReadFastFull(rw)
+	if !bytes.Equal(b, want) {
+		t.Fatalf("content: %q, want %q", b, want)
+	}
+	for k, s := range pt.starts {
+		if k > 0 && s != pt.starts[k-1]+len(pt.pieces[k-1].b) {
+			t.Fatalf("bad piece start %v: %v", k, s)
+		}
+	}
+}
+
+const benchBufSize = 100 << 20 // 100 MB
+
+func benchBuf() []byte {
+	return bytes.Repeat([]byte("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcde\n"), benchBufSize/64)
+}
+
+func BenchmarkPieceTableInsertStart(b *testing.B) {
+	rw := NewPieceTable(benchBuf())
+	benchInsertStart(b, rw)
+}
+
+func BenchmarkBytesReadWriterAtInsertStart(b *testing.B) {
+	rw := NewBytesReadWriterAt(benchBuf())
+	benchInsertStart(b, rw)
+}
+
+func BenchmarkPieceTableDeleteStart(b *testing.B) {
+	rw := NewPieceTable(benchBuf())
+	benchDeleteStart(b, rw)
+}
+
+func BenchmarkBytesReadWriterAtDeleteStart(b *testing.B) {
+	rw := NewBytesReadWriterAt(benchBuf())
+	benchDeleteStart(b, rw)
+}
+
+func benchInsertStart(b *testing.B, rw ReadWriterAt) {
+	p := []byte("a")
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := rw.OverwriteAt(0, 0, p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchDeleteStart(b *testing.B, rw ReadWriterAt) {
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if rw.Max() == 0 {
+			b.StopTimer()
+			_ = rw.OverwriteAt(0, 0, benchBuf())
+			b.StartTimer()
+		}
+		if err := rw.OverwriteAt(0, 1, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ioutil
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+)
+
+// Copies the extended attributes (includes the access control lists).
+func copyXattrs(src, dst string) error {
+	names, err := listXattrs(src)
+	if err != nil {
+		if errors.Is(err, syscall.ENOTSUP) {
+			return nil
+		}
+		return err
+	}
+	for _, name := range names {
+		v, err := getXattr(src, name)
+		if err != nil {
+			return err
+		}
+		if v2, err := getXattr(dst, name); err == nil && bytes.Equal(v, v2) {
+			continue // ex: inherited selinux context
+		}
+		if err := syscall.Setxattr(dst, name, v, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listXattrs(filename string) ([]string, error) {
+	n, err := syscall.Listxattr(filename, nil)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	n, err = syscall.Listxattr(filename, buf)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, b := range bytes.Split(buf[:n], []byte{0}) {
+		if len(b) > 0 {
+			names = append(names, string(b))
+		}
+	}
+	return names, nil
+}
+
+func getXattr(filename, name string) ([]byte, error) {
+	n, err := syscall.Getxattr(filename, name, nil)
+	if err != nil || n == 0 {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	n, err = syscall.Getxattr(filename, name, buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
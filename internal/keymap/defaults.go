@@ -0,0 +1,71 @@
+package keymap
+
+// editing keys, used by the text areas and the toolbars
+var editDefaults = [][2]string{
+	{"C-S-Right", "cursorJumpRightSelect"},
+	{"C-Right", "cursorJumpRight"},
+	{"S-Right", "cursorRightSelect"},
+	{"Right", "cursorRight"},
+
+	{"C-S-Left", "cursorJumpLeftSelect"},
+	{"C-Left", "cursorJumpLeft"},
+	{"S-Left", "cursorLeftSelect"},
+	{"Left", "cursorLeft"},
+
+	{"C-A-Up", "moveLineUp"},
+	{"S-Up", "cursorUpSelect"},
+	{"Up", "cursorUp"},
+
+	{"C-A-Down", "moveLineDown"},
+	{"S-Down", "cursorDownSelect"},
+	{"Down", "cursorDown"},
+
+	{"C-S-Home", "startOfStringSelect"},
+	{"C-Home", "startOfString"},
+	{"S-Home", "startOfLineSelect"},
+	{"Home", "startOfLine"},
+
+	{"C-S-End", "endOfStringSelect"},
+	{"C-End", "endOfString"},
+	{"S-End", "endOfLineSelect"},
+	{"End", "endOfLine"},
+
+	{"Backspace", "backspace"},
+	{"Delete", "delete"},
+	{"Return", "autoIndent"},
+	{"S-Tab", "tabLeft"},
+	{"Tab", "tabRight"},
+	{"PageUp", "pageUp"},
+	{"PageDown", "pageDown"},
+
+	{"ctrl-D", "comment"},
+	{"ctrl-shift-D", "uncomment"},
+	{"ctrl-C", "copy"},
+	{"ctrl-X", "cut"},
+	{"ctrl-V", "paste"},
+	{"ctrl-shift-V", "pastePrevious"},
+	{"ctrl-K", "removeLines"},
+	{"ctrl-A", "selectAll"},
+	{"ctrl-Z", "undo"},
+	{"ctrl-shift-Z", "redo"},
+	{"ctrl-]", "matchBracket"},
+
+	// row actions
+	{"ctrl-S", "save"},
+	{"ctrl-F", "find"},
+	{"ctrl-H", "replace"},
+	{"ctrl-N", "newFile"},
+	{"ctrl-R", "reload"},
+	{"ctrl-W", "close"},
+}
+
+var defaults = map[Context][][2]string{
+	Global: {
+		{"Escape", "cancel"},
+		{"F1", "toggleInfo"},
+		{"F3", "macroRecord"},
+		{"F4", "macroReplay"},
+	},
+	TextArea: editDefaults,
+	Toolbar:  editDefaults,
+}
@@ -0,0 +1,195 @@
+// Maps key chords (and sequences of chords) to named actions or commands.
+package keymap
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/friedelschoen/glake/internal/ui/driver"
+)
+
+// Context where a map is active.
+type Context string
+
+const (
+	Global   Context = "global"   // checked before any other map
+	TextArea Context = "textarea" // row and floatbox text areas
+	Toolbar  Context = "toolbar"  // row and root toolbars
+)
+
+var Contexts = []Context{Global, TextArea, Toolbar}
+
+//----------
+
+type Binding struct {
+	Keys   []string // sequence of chords (ex: "ctrl-X", "ctrl-S")
+	Action string
+}
+
+func (b *Binding) KeysString() string {
+	return strings.Join(b.Keys, " ")
+}
+
+//----------
+
+type Map struct {
+	mu       sync.Mutex
+	bindings []*Binding
+}
+
+func NewMap() *Map {
+	return &Map{}
+}
+
+// Binds a space separated sequence of chords (ex: "ctrl-X ctrl-S") to an action. An empty action removes the binding.
+func (m *Map) Bind(keys, action string) error {
+	seq := strings.Fields(keys)
+	if len(seq) == 0 {
+		return fmt.Errorf("empty key sequence")
+	}
+	for _, k := range seq {
+		if !driver.ValidKeyName(k) {
+			return fmt.Errorf("invalid key: %q", k)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.bindings = slices.DeleteFunc(m.bindings, func(b *Binding) bool {
+		return slices.Equal(b.Keys, seq)
+	})
+	if action != "" {
+		m.bindings = append(m.bindings, &Binding{Keys: seq, Action: action})
+	}
+	return nil
+}
+
+// Copy of the bindings sorted by keys.
+func (m *Map) Bindings() []*Binding {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := slices.Clone(m.bindings)
+	slices.SortFunc(u, func(a, b *Binding) int {
+		return strings.Compare(a.KeysString(), b.KeysString())
+	})
+	return u
+}
+
+// Keys of an incomplete sequence. Kept per input target (ex: each text area), a sequence started in one is not broken by keys typed in another.
+type Seq struct {
+	pending []driver.Key
+}
+
+// Feeds a key press to the map. Returns the actions to run in order: the action of the prefix of a broken sequence (if bound on its own), then the action of the key. The key is consumed (not to be handled further) if it completed or continued a sequence.
+func (m *Map) Lookup(s *Seq, k driver.Key) ([]string, bool) {
+	// modifiers pressed between chords don't break a sequence
+	if k.IsModifier() {
+		return nil, len(s.pending) > 0
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lookup(s, k)
+}
+
+func (m *Map) lookup(s *Seq, k driver.Key) ([]string, bool) {
+	seq := append(slices.Clone(s.pending), k)
+	s.pending = nil
+	action, prefix := m.match(seq)
+	if prefix {
+		s.pending = seq
+		return nil, true
+	}
+	if action == "" && len(seq) > 1 {
+		// sequence broken: the swallowed prefix on its own, then the key
+		u := []string{}
+		if a, _ := m.match(seq[:len(seq)-1]); a != "" {
+			u = append(u, a)
+		}
+		w, consumed := m.lookup(s, k)
+		return append(u, w...), consumed
+	}
+	if action == "" {
+		return nil, false
+	}
+	return []string{action}, true
+}
+
+// Finds the binding matching seq, and reports if seq is the start of a longer binding that wins over it. A key name matches keys with extra modifiers (ex: "Up" matches "S-Up"), so the name with more modifiers wins.
+func (m *Map) match(seq []driver.Key) (string, bool) {
+	n := len(seq) - 1
+	action, score := "", -1
+	prefixScore := -1
+	for _, b := range m.bindings {
+		if len(b.Keys) < len(seq) || !matchKeys(b.Keys, seq) {
+			continue
+		}
+		s := driver.KeyNameMods(b.Keys[n])
+		if len(b.Keys) > len(seq) {
+			prefixScore = max(prefixScore, s)
+		} else if s > score {
+			action, score = b.Action, s
+		}
+	}
+	// a longer sequence wins over an equally specific binding
+	return action, prefixScore >= 0 && prefixScore >= score
+}
+
+func matchKeys(names []string, seq []driver.Key) bool {
+	for i, k := range seq {
+		if !k.Is(names[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+//----------
+
+var maps = struct {
+	sync.Mutex
+	m map[Context]*Map
+}{m: map[Context]*Map{}}
+
+// Map of the context, initialized with the default bindings.
+func Get(c Context) *Map {
+	maps.Lock()
+	defer maps.Unlock()
+	m, ok := maps.m[c]
+	if !ok {
+		m = NewMap()
+		for _, d := range defaults[c] {
+			if err := m.Bind(d[0], d[1]); err != nil {
+				panic(err)
+			}
+		}
+		maps.m[c] = m
+	}
+	return m
+}
+
+// Adds bindings from the config file, keyed by context and then by key sequence.
+func Load(conf map[string]map[string]string) error {
+	for c, bindings := range conf {
+		if !slices.Contains(Contexts, Context(c)) {
+			return fmt.Errorf("keymap: unknown context: %q", c)
+		}
+		m := Get(Context(c))
+		for keys, action := range bindings {
+			if err := m.Bind(keys, action); err != nil {
+				return fmt.Errorf("keymap: %v: %w", c, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Actions starting with an uppercase letter are commands (ex: "Save", "Find -icase"), the others are actions named by the context handler (ex: "comment").
+func IsCommand(action string) bool {
+	ru, _ := utf8.DecodeRuneInString(action)
+	return unicode.IsUpper(ru)
+}
@@ -0,0 +1,68 @@
+package language
+
+import "github.com/friedelschoen/glake/internal/lsproto"
+
+var cBlock = [2]string{"/*", "*/"}
+var xmlBlock = [2]string{"<!--", "-->"}
+
+// Ids are the lowercase chroma lexer names when there is one.
+var builtin = []*Language{
+	{
+		Id:           "go",
+		Filenames:    []string{"*.go"},
+		LineComment:  "//",
+		BlockComment: cBlock,
+		IndentStyle:  "tab",
+		LSProto:      lsproto.GoplsRegistration(false, false, false),
+		PreSaveHook:  "goimports",
+	},
+	{Id: "gomod", Filenames: []string{"go.mod", "go.sum", "go.work", "go.work.sum"}, LineComment: "//", Lexer: "none"},
+	{Id: "c", Filenames: []string{"*.c", "*.h"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "c++", Filenames: []string{"*.cpp", "*.hpp", "*.cxx", "*.hxx", "*.cc", "*.hh"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "java", Filenames: []string{"*.java"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "javascript", Filenames: []string{"*.js", "*.mjs", "*.cjs"}, Shebangs: []string{"node"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "typescript", Filenames: []string{"*.ts", "*.tsx"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "rust", Filenames: []string{"*.rs"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "zig", Filenames: []string{"*.zig"}, LineComment: "//"},
+	{Id: "verilog", Filenames: []string{"*.v"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "php", Filenames: []string{"*.php"}, Shebangs: []string{"php"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "protocol buffer", Filenames: []string{"*.proto"}, LineComment: "//", BlockComment: cBlock},
+	{Id: "gas", Filenames: []string{"*.s", "*.S", "*.asm"}, LineComment: "//"},
+	{Id: "css", Filenames: []string{"*.css"}, BlockComment: cBlock},
+	{Id: "html", Filenames: []string{"*.html", "*.htm"}, BlockComment: xmlBlock},
+	{Id: "xml", Filenames: []string{"*.xml", "*.svg"}, BlockComment: xmlBlock},
+	{Id: "markdown", Filenames: []string{"*.md", "*.markdown"}, BlockComment: xmlBlock},
+	{Id: "json", Filenames: []string{"*.json"}},
+	{Id: "diff", Filenames: []string{"*.diff", "*.patch"}},
+	{
+		Id:          "bash",
+		Filenames:   []string{"*.sh", "*.bash", "bashrc", "bash_profile", "profile"},
+		Shebangs:    []string{"sh", "bash", "dash", "ksh", "zsh"},
+		LineComment: "#",
+	},
+	{Id: "fish", Filenames: []string{"*.fish"}, Shebangs: []string{"fish"}, LineComment: "#"},
+	{Id: "python", Filenames: []string{"*.py"}, Shebangs: []string{"python", "python2", "python3"}, LineComment: "#", IndentStyle: "space", IndentSize: 4},
+	{Id: "perl", Filenames: []string{"*.pl", "*.pm"}, Shebangs: []string{"perl"}, LineComment: "#"},
+	{Id: "ruby", Filenames: []string{"*.rb", "Gemfile", "Rakefile"}, Shebangs: []string{"ruby"}, LineComment: "#"},
+	{Id: "awk", Filenames: []string{"*.awk"}, Shebangs: []string{"awk", "gawk"}, LineComment: "#"},
+	{Id: "lua", Filenames: []string{"*.lua"}, Shebangs: []string{"lua"}, LineComment: "--"},
+	{Id: "sql", Filenames: []string{"*.sql"}, LineComment: "--", BlockComment: cBlock},
+	{Id: "haskell", Filenames: []string{"*.hs"}, LineComment: "--"},
+	{Id: "makefile", Filenames: []string{"Makefile", "makefile", "GNUmakefile", "*.mk"}, LineComment: "#", IndentStyle: "tab"},
+	{Id: "cmake", Filenames: []string{"CMakeLists.txt", "*.cmake"}, LineComment: "#"},
+	{Id: "docker", Filenames: []string{"Dockerfile", "*.dockerfile"}, LineComment: "#"},
+	{Id: "yaml", Filenames: []string{"*.yaml", "*.yml"}, LineComment: "#", IndentStyle: "space", IndentSize: 2},
+	{Id: "toml", Filenames: []string{"*.toml"}, LineComment: "#"},
+	{Id: "nix", Filenames: []string{"*.nix"}, LineComment: "#"},
+	{Id: "conf", Filenames: []string{"*.conf", "*.list"}, LineComment: "#", Lexer: "none"},
+	{Id: "plaintext", Filenames: []string{"*.txt"}, LineComment: "#"}, // useful (but not correct)
+	{Id: "common lisp", Filenames: []string{"*.lisp", "*.cl"}, LineComment: ";"},
+	{Id: "scheme", Filenames: []string{"*.scm"}, LineComment: ";"},
+	{Id: "clojure", Filenames: []string{"*.clj"}, LineComment: ";"},
+	{Id: "emacslisp", Filenames: []string{"*.el"}, LineComment: ";"},
+	{Id: "prolog", Filenames: []string{"*.pro"}, LineComment: "%", BlockComment: cBlock},
+	{Id: "tex", Filenames: []string{"*.tex"}, LineComment: "%"},
+	{Id: "viml", Filenames: []string{"*.vim", "vimrc"}, LineComment: "\""},
+	{Id: "ledger", Filenames: []string{"*.ledger"}, LineComment: ";", Lexer: "none"}, // ";" is the main comment symbol ("#" is not a comment in some cases)
+	{Id: "xresources", Filenames: []string{"Xresources", "Xdefaults"}, LineComment: "!", Lexer: "none"},
+}
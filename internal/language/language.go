@@ -0,0 +1,217 @@
+// Package language maps files to languages (by filename or shebang) and keeps the per-language settings: comments, syntax highlighting lexer, indentation, language server and pre-save hook.
+package language
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/lexers"
+)
+
+type Language struct {
+	Id           string    `json:"id"`            // lowercase, ex: "go", "c++" (also the snippets and autopairs key)
+	Filenames    []string  `json:"filenames"`     // base name patterns (path.Match), ex: "*.go", "Makefile"
+	Shebangs     []string  `json:"shebangs"`      // interpreters of a "#!" first line, ex: "python3"
+	LineComment  string    `json:"line-comment"`  // ex: "//"
+	BlockComment [2]string `json:"block-comment"` // ex: ["/*", "*/"]
+	Lexer        string    `json:"lexer"`         // chroma lexer name, the id if empty, "none" to disable
+	IndentStyle  string    `json:"indent-style"`  // "tab" or "space", empty to keep the default
+	IndentSize   int       `json:"indent-size"`   // spaces, with the "space" indent style
+	LSProto      string    `json:"lsproto"`       // language server registration, same format as the -lsproto flag
+	PreSaveHook  string    `json:"presavehook"`   // cmd that formats the content before saving (stdin to stdout)
+}
+
+// Comment symbol for editbuf.Comment: the line comment string, or the block comment [2]string, nil if none.
+func (l *Language) CommentSym() any {
+	if l.LineComment != "" {
+		return l.LineComment
+	}
+	if l.BlockComment[0] != "" {
+		return l.BlockComment
+	}
+	return nil
+}
+
+// Syntax highlighting lexer, nil if none.
+func (l *Language) ChromaLexer() chroma.Lexer {
+	name := l.Lexer
+	if name == "" {
+		name = l.Id
+	}
+	if name == "none" {
+		return nil
+	}
+	return lexers.Get(name)
+}
+
+// Inserted by a tab, empty for the default.
+func (l *Language) IndentUnit() string {
+	switch l.IndentStyle {
+	case "tab":
+		return "\t"
+	case "space":
+		return strings.Repeat(" ", max(1, l.IndentSize))
+	}
+	return ""
+}
+
+// Sets the non-empty fields of l2.
+func (l *Language) merge(l2 *Language) {
+	if l2.Filenames != nil {
+		l.Filenames = l2.Filenames
+	}
+	if l2.Shebangs != nil {
+		l.Shebangs = l2.Shebangs
+	}
+	if l2.LineComment != "" {
+		l.LineComment = l2.LineComment
+	}
+	if l2.BlockComment[0] != "" {
+		l.BlockComment = l2.BlockComment
+	}
+	if l2.Lexer != "" {
+		l.Lexer = l2.Lexer
+	}
+	if l2.IndentStyle != "" {
+		l.IndentStyle = l2.IndentStyle
+	}
+	if l2.IndentSize != 0 {
+		l.IndentSize = l2.IndentSize
+	}
+	if l2.LSProto != "" {
+		l.LSProto = l2.LSProto
+	}
+	if l2.PreSaveHook != "" {
+		l.PreSaveHook = l2.PreSaveHook
+	}
+}
+
+func (l *Language) validate() error {
+	if l.Id == "" {
+		return fmt.Errorf("language: empty id")
+	}
+	for _, p := range l.Filenames {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("language %v: %q: %w", l.Id, p, err)
+		}
+	}
+	switch l.IndentStyle {
+	case "", "tab", "space":
+	default:
+		return fmt.Errorf("language %v: bad indent style: %q", l.Id, l.IndentStyle)
+	}
+	return nil
+}
+
+//----------
+
+type Registry struct {
+	langs []*Language // later entries take precedence when matching
+}
+
+// The built-in languages, with the user languages added (or merged with the built-in language of the same id, only the fields that are set).
+func NewRegistry(user []*Language) (*Registry, error) {
+	r := &Registry{}
+	for _, l := range builtin {
+		l2 := *l
+		r.langs = append(r.langs, &l2)
+	}
+	for _, l := range user {
+		if err := l.validate(); err != nil {
+			return nil, err
+		}
+		l2 := *l
+		if i := slices.IndexFunc(r.langs, func(l3 *Language) bool { return l3.Id == l.Id }); i >= 0 {
+			l3 := r.langs[i]
+			l3.merge(l)
+			l2 = *l3
+			r.langs = slices.Delete(r.langs, i, i+1)
+		}
+		r.langs = append(r.langs, &l2)
+	}
+	return r, nil
+}
+
+func (r *Registry) Languages() []*Language {
+	return r.langs
+}
+
+func (r *Registry) Get(id string) (*Language, bool) {
+	for _, l := range r.langs {
+		if l.Id == id {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// Language of the filename. Names without patterns (ex: "go.mod") are preferred over patterns (ex: "*.mod"). A leading "." is optional (ex: "bashrc" matches ".bashrc").
+func (r *Registry) MatchFilename(filename string) (*Language, bool) {
+	base := filepath.Base(filename)
+	names := []string{base}
+	if s, ok := strings.CutPrefix(base, "."); ok && s != "" {
+		names = append(names, s)
+	}
+	var best *Language
+	bestExact := false
+	for i := len(r.langs) - 1; i >= 0; i-- {
+		l := r.langs[i]
+		for _, p := range l.Filenames {
+			exact := !strings.ContainsAny(p, `*?[\`)
+			if best != nil && (bestExact || !exact) {
+				continue
+			}
+			for _, name := range names {
+				if ok, _ := path.Match(p, name); ok {
+					best, bestExact = l, exact
+					break
+				}
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// Language of the "#!" first line of the content (ex: "#!/usr/bin/env python3").
+func (r *Registry) MatchShebang(head []byte) (*Language, bool) {
+	interp := shebangInterpreter(head)
+	if interp == "" {
+		return nil, false
+	}
+	for i := len(r.langs) - 1; i >= 0; i-- {
+		if slices.Contains(r.langs[i].Shebangs, interp) {
+			return r.langs[i], true
+		}
+	}
+	return nil, false
+}
+
+func shebangInterpreter(head []byte) string {
+	line, ok := bytes.CutPrefix(head, []byte("#!"))
+	if !ok {
+		return ""
+	}
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(string(line))
+	if len(fields) == 0 {
+		return ""
+	}
+	interp := path.Base(fields[0])
+	if interp == "env" {
+		interp = ""
+		for _, f := range fields[1:] {
+			if !strings.HasPrefix(f, "-") && !strings.Contains(f, "=") {
+				interp = path.Base(f)
+				break
+			}
+		}
+	}
+	return interp
+}
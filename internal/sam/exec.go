@@ -0,0 +1,389 @@
+package sam
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"unicode/utf8"
+)
+
+type Range struct {
+	Start, End int
+}
+
+type Edit struct {
+	Range
+	Text []byte
+}
+
+type Result struct {
+	Edits  []*Edit // sorted, not overlapping, offsets in the original text
+	Dot    Range   // dot after the last command (original text offsets)
+	Output []byte  // output of "p", "=" and ">"
+}
+
+// Runs shell commands ("|", "<", ">") with the given input.
+type ShellFn func(cmd string, in []byte) ([]byte, error)
+
+type runner struct {
+	b     []byte
+	shell ShellFn
+	res   *Result
+	out   bytes.Buffer
+}
+
+// Runs the command on the text b with the initial dot. The text is not changed, the returned edits should be applied from last to first.
+func Exec(c *Cmd, b []byte, dot Range, shell ShellFn) (*Result, error) {
+	r := &runner{b: b, shell: shell, res: &Result{}}
+	d, err := r.run(c, dot)
+	if err != nil {
+		return nil, err
+	}
+	r.res.Dot = d
+	r.res.Output = r.out.Bytes()
+
+	// changes must be in sequence
+	slices.SortStableFunc(r.res.Edits, func(a, b *Edit) int { return a.Start - b.Start })
+	for i := 1; i < len(r.res.Edits); i++ {
+		if r.res.Edits[i].Start < r.res.Edits[i-1].End {
+			return nil, fmt.Errorf("sam: changes not in sequence")
+		}
+	}
+	return r.res, nil
+}
+
+func (r *runner) edit(start, end int, text []byte) {
+	r.res.Edits = append(r.res.Edits, &Edit{Range{start, end}, text})
+}
+
+func (r *runner) run(c *Cmd, dot Range) (Range, error) {
+	if c.addr != nil {
+		d, err := r.addr(c.addr, dot)
+		if err != nil {
+			return dot, err
+		}
+		dot = d
+	}
+	text := r.b[dot.Start:dot.End]
+
+	switch c.name {
+	case 0: // address only
+	case 'a':
+		r.edit(dot.End, dot.End, []byte(c.text))
+	case 'i':
+		r.edit(dot.Start, dot.Start, []byte(c.text))
+	case 'c':
+		r.edit(dot.Start, dot.End, []byte(c.text))
+	case 'd':
+		r.edit(dot.Start, dot.End, nil)
+	case 'p':
+		r.out.Write(text)
+		if len(text) > 0 && text[len(text)-1] != '\n' {
+			r.out.WriteByte('\n')
+		}
+	case '=':
+		if c.chars {
+			fmt.Fprintf(&r.out, "#%v,#%v\n", r.runeOffset(dot.Start), r.runeOffset(dot.End))
+		} else {
+			l0, l1 := r.lineNumber(dot.Start), r.lineNumber(max(dot.Start, dot.End-1))
+			if l0 == l1 {
+				fmt.Fprintf(&r.out, "%v\n", l0)
+			} else {
+				fmt.Fprintf(&r.out, "%v,%v\n", l0, l1)
+			}
+		}
+	case 's':
+		if err := r.substitute(c, dot); err != nil {
+			return dot, err
+		}
+	case 'x', 'y':
+		for _, d := range r.loopRanges(c, dot) {
+			if _, err := r.run(c.sub, d); err != nil {
+				return dot, err
+			}
+		}
+	case 'g', 'v':
+		if matches(c.re, text) == (c.name == 'g') {
+			return r.run(c.sub, dot)
+		}
+	case '|', '<', '>':
+		if r.shell == nil {
+			return dot, fmt.Errorf("sam: shell commands not available")
+		}
+		in := []byte(nil)
+		if c.name != '<' {
+			in = text
+		}
+		out, err := r.shell(c.text, in)
+		if err != nil {
+			return dot, err
+		}
+		if c.name == '>' {
+			r.out.Write(out)
+		} else {
+			r.edit(dot.Start, dot.End, out)
+		}
+	}
+	return dot, nil
+}
+
+// An empty match after the last line break belongs to the next line (ex: "^$" does not match "a\n").
+func matches(re *regexp.Regexp, text []byte) bool {
+	for _, loc := range re.FindAllIndex(text, -1) {
+		if loc[0] == loc[1] && loc[0] == len(text) && len(text) > 0 && text[len(text)-1] == '\n' {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Ranges of the matches (x) or between the matches (y).
+func (r *runner) loopRanges(c *Cmd, dot Range) []Range {
+	text := r.b[dot.Start:dot.End]
+	locs := c.re.FindAllIndex(text, -1)
+	u := []Range{}
+	if c.name == 'x' {
+		for _, loc := range locs {
+			u = append(u, Range{dot.Start + loc[0], dot.Start + loc[1]})
+		}
+		// last line without a line break
+		if c.lines {
+			k := dot.Start
+			if len(locs) > 0 {
+				k = dot.Start + locs[len(locs)-1][1]
+			}
+			if k < dot.End {
+				u = append(u, Range{k, dot.End})
+			}
+		}
+		return u
+	}
+	k := dot.Start
+	for _, loc := range locs {
+		u = append(u, Range{k, dot.Start + loc[0]})
+		k = dot.Start + loc[1]
+	}
+	return append(u, Range{k, dot.End})
+}
+
+func (r *runner) substitute(c *Cmd, dot Range) error {
+	text := r.b[dot.Start:dot.End]
+	n := 0
+	for _, loc := range c.re.FindAllSubmatchIndex(text, -1) {
+		n++
+		if n < c.nth {
+			continue
+		}
+		r.edit(dot.Start+loc[0], dot.Start+loc[1], expandRepl(c.text, text, loc))
+		if !c.all {
+			break
+		}
+	}
+	if n < c.nth {
+		return fmt.Errorf("sam: s: no match")
+	}
+	return nil
+}
+
+// Expands "&" (the match), "\1".."\9" (submatches) and "\n".
+func expandRepl(repl string, text []byte, loc []int) []byte {
+	w := []byte{}
+	group := func(i int) {
+		if 2*i+1 < len(loc) && loc[2*i] >= 0 {
+			w = append(w, text[loc[2*i]:loc[2*i+1]]...)
+		}
+	}
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '&':
+			group(0)
+		case c == '\\' && i+1 < len(repl):
+			i++
+			e := repl[i]
+			switch {
+			case e >= '0' && e <= '9':
+				group(int(e - '0'))
+			case e == 'n':
+				w = append(w, '\n')
+			case e == 't':
+				w = append(w, '\t')
+			default:
+				w = append(w, e)
+			}
+		default:
+			w = append(w, c)
+		}
+	}
+	return w
+}
+
+//----------
+
+func (r *runner) addr(a *Addr, dot Range) (Range, error) {
+	switch a.typ {
+	case ',', ';':
+		left := Range{0, 0}
+		if a.left != nil {
+			l, err := r.addr(a.left, dot)
+			if err != nil {
+				return dot, err
+			}
+			left = l
+		}
+		if a.typ == ';' {
+			dot = left
+		}
+		right := Range{len(r.b), len(r.b)}
+		if a.right != nil {
+			r2, err := r.addr(a.right, dot)
+			if err != nil {
+				return dot, err
+			}
+			right = r2
+		}
+		if right.End < left.Start {
+			return dot, fmt.Errorf("sam: addresses out of order")
+		}
+		return Range{left.Start, right.End}, nil
+	case '+', '-':
+		left := dot
+		if a.left != nil {
+			l, err := r.addr(a.left, dot)
+			if err != nil {
+				return dot, err
+			}
+			left = l
+		}
+		right := a.right
+		if right == nil {
+			right = &Addr{typ: 'l', n: 1}
+		}
+		return r.relAddr(right, left, a.typ == '-')
+	}
+	return r.simpleAddr(a, dot)
+}
+
+func (r *runner) simpleAddr(a *Addr, dot Range) (Range, error) {
+	switch a.typ {
+	case '.':
+		return dot, nil
+	case '$':
+		return Range{len(r.b), len(r.b)}, nil
+	case '#':
+		i, err := r.byteOffset(a.n)
+		return Range{i, i}, err
+	case 'l':
+		return r.line(a.n)
+	case '/':
+		return r.search(a.re, dot.End, false)
+	case '?':
+		return r.search(a.re, dot.Start, true)
+	}
+	return dot, fmt.Errorf("sam: bad address")
+}
+
+// Address relative to the dot: "+" from its end, "-" from its start.
+func (r *runner) relAddr(a *Addr, dot Range, back bool) (Range, error) {
+	switch a.typ {
+	case '#':
+		if back {
+			i, err := r.byteOffset(r.runeOffset(dot.Start) - a.n)
+			return Range{i, i}, err
+		}
+		i, err := r.byteOffset(r.runeOffset(dot.End) + a.n)
+		return Range{i, i}, err
+	case 'l':
+		if back {
+			return r.line(r.lineNumber(dot.Start) - a.n)
+		}
+		// a dot ending at a line start is considered to be in the previous line
+		e := dot.End
+		if e > dot.Start && r.b[e-1] == '\n' {
+			e--
+		}
+		return r.line(r.lineNumber(e) + a.n)
+	case '/', '?':
+		if back {
+			return r.search(a.re, dot.Start, true)
+		}
+		return r.search(a.re, dot.End, false)
+	}
+	return r.addr(a, dot)
+}
+
+// Searches from i wrapping around.
+func (r *runner) search(re *regexp.Regexp, i int, back bool) (Range, error) {
+	if !back {
+		if loc := re.FindIndex(r.b[i:]); loc != nil {
+			return Range{i + loc[0], i + loc[1]}, nil
+		}
+		if loc := re.FindIndex(r.b); loc != nil {
+			return Range{loc[0], loc[1]}, nil
+		}
+	} else {
+		locs := re.FindAllIndex(r.b, -1)
+		for k := len(locs) - 1; k >= 0; k-- {
+			if locs[k][1] <= i {
+				return Range{locs[k][0], locs[k][1]}, nil
+			}
+		}
+		if len(locs) > 0 {
+			loc := locs[len(locs)-1]
+			return Range{loc[0], loc[1]}, nil
+		}
+	}
+	return Range{}, fmt.Errorf("sam: no match for %v", strconv.Quote(re.String()[len("(?m)"):]))
+}
+
+// Range of line n (one-based, including the line break). Line zero is the empty range at the start.
+func (r *runner) line(n int) (Range, error) {
+	if n < 0 {
+		return Range{}, fmt.Errorf("sam: address out of range")
+	}
+	if n == 0 {
+		return Range{0, 0}, nil
+	}
+	s := 0
+	for l := 1; l < n; l++ {
+		k := bytes.IndexByte(r.b[s:], '\n')
+		if k < 0 {
+			return Range{}, fmt.Errorf("sam: address out of range")
+		}
+		s += k + 1
+	}
+	if s == len(r.b) && n > 1 {
+		return Range{s, s}, nil
+	}
+	e := len(r.b)
+	if k := bytes.IndexByte(r.b[s:], '\n'); k >= 0 {
+		e = s + k + 1
+	}
+	return Range{s, e}, nil
+}
+
+func (r *runner) lineNumber(i int) int {
+	return bytes.Count(r.b[:i], []byte("\n")) + 1
+}
+
+func (r *runner) runeOffset(i int) int {
+	return utf8.RuneCount(r.b[:i])
+}
+
+func (r *runner) byteOffset(n int) (int, error) {
+	if n < 0 {
+		return 0, fmt.Errorf("sam: address out of range")
+	}
+	i := 0
+	for ; n > 0; n-- {
+		if i >= len(r.b) {
+			return 0, fmt.Errorf("sam: address out of range")
+		}
+		_, size := utf8.DecodeRune(r.b[i:])
+		i += size
+	}
+	return i, nil
+}
@@ -0,0 +1,298 @@
+// Sam/acme structural regular expressions command language, as used by acme's Edit command.
+package sam
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Addr struct {
+	typ         byte // '#', 'l' (line), '/', '?', '$', '.', ',', ';', '+', '-'
+	n           int
+	re          *regexp.Regexp
+	left, right *Addr // compound addresses (can be nil for the defaults)
+}
+
+type Cmd struct {
+	addr  *Addr // nil for dot
+	name  byte
+	re    *regexp.Regexp
+	text  string // a, i, c, s (replacement), |, <, > (shell cmd)
+	sub   *Cmd   // x, y, g, v
+	nth   int    // s: replace the nth match
+	all   bool   // s: "g" flag
+	chars bool   // "=#"
+	lines bool   // x without a regular expression
+}
+
+type parser struct {
+	s string
+	i int
+}
+
+func Parse(s string) (*Cmd, error) {
+	p := &parser{s: s}
+	c, err := p.cmd()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	if p.i < len(p.s) {
+		return nil, p.errorf("unexpected text: %q", p.s[p.i:])
+	}
+	return c, nil
+}
+
+func (p *parser) errorf(f string, a ...any) error {
+	return fmt.Errorf("sam: %v: %v", p.i, fmt.Sprintf(f, a...))
+}
+
+func (p *parser) peek() byte {
+	if p.i >= len(p.s) {
+		return 0
+	}
+	return p.s[p.i]
+}
+
+func (p *parser) skipSpace() {
+	for p.i < len(p.s) && (p.s[p.i] == ' ' || p.s[p.i] == '\t') {
+		p.i++
+	}
+}
+
+//----------
+
+func (p *parser) cmd() (*Cmd, error) {
+	p.skipSpace()
+	a, err := p.addr()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpace()
+	c := &Cmd{addr: a, name: p.peek()}
+	if c.name == 0 {
+		if a == nil {
+			return nil, p.errorf("missing command")
+		}
+		// address only: set dot
+		return c, nil
+	}
+	p.i++
+
+	switch c.name {
+	case 'x', 'y':
+		p.skipSpace()
+		if d := p.peek(); d != 0 && !isAlnum(d) && d != ' ' && d != '{' {
+			if c.re, err = p.regexp(); err != nil {
+				return nil, err
+			}
+		} else if c.name == 'x' {
+			c.re = regexp.MustCompile(`(?m).*\n`)
+			c.lines = true
+		} else {
+			return nil, p.errorf("y: missing regular expression")
+		}
+		if c.sub, err = p.subCmd(); err != nil {
+			return nil, err
+		}
+	case 'g', 'v':
+		p.skipSpace()
+		if c.re, err = p.regexp(); err != nil {
+			return nil, err
+		}
+		if c.sub, err = p.subCmd(); err != nil {
+			return nil, err
+		}
+	case 's':
+		c.nth = 1
+		if n, ok := p.number(); ok {
+			c.nth = n
+		}
+		d := p.peek()
+		if c.re, err = p.regexp(); err != nil {
+			return nil, err
+		}
+		c.text, err = p.delimited(d, false)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() == 'g' {
+			p.i++
+			c.all = true
+		}
+	case 'a', 'i', 'c':
+		p.skipSpace()
+		d := p.peek()
+		if d == 0 || isAlnum(d) {
+			return nil, p.errorf("%c: missing text", c.name)
+		}
+		p.i++
+		if c.text, err = p.delimited(d, true); err != nil {
+			return nil, err
+		}
+	case 'd', 'p':
+	case '=':
+		if p.peek() == '#' {
+			p.i++
+			c.chars = true
+		}
+	case '|', '<', '>':
+		c.text = strings.TrimSpace(p.s[p.i:])
+		p.i = len(p.s)
+		if c.text == "" {
+			return nil, p.errorf("%c: missing shell command", c.name)
+		}
+	default:
+		return nil, p.errorf("unknown command: %q", c.name)
+	}
+	return c, nil
+}
+
+// Command of x, y, g, v. Defaults to "p".
+func (p *parser) subCmd() (*Cmd, error) {
+	p.skipSpace()
+	if p.peek() == 0 {
+		return &Cmd{name: 'p'}, nil
+	}
+	return p.cmd()
+}
+
+//----------
+
+func (p *parser) addr() (*Addr, error) {
+	left, err := p.simpleAddrs()
+	if err != nil {
+		return nil, err
+	}
+	if d := p.peek(); d == ',' || d == ';' {
+		p.i++
+		right, err := p.addr()
+		if err != nil {
+			return nil, err
+		}
+		return &Addr{typ: d, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+// Simple addresses joined with "+" or "-" (implicit "+" between two simple addresses).
+func (p *parser) simpleAddrs() (*Addr, error) {
+	left, err := p.simpleAddr()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		d := p.peek()
+		if d != '+' && d != '-' {
+			if left != nil && (d == '/' || d == '?' || d == '#' || isDigit(d)) {
+				d = '+'
+			} else {
+				return left, nil
+			}
+		} else {
+			p.i++
+		}
+		right, err := p.simpleAddr()
+		if err != nil {
+			return nil, err
+		}
+		left = &Addr{typ: d, left: left, right: right}
+	}
+}
+
+func (p *parser) simpleAddr() (*Addr, error) {
+	switch d := p.peek(); {
+	case d == '#':
+		p.i++
+		n, _ := p.number()
+		return &Addr{typ: '#', n: n}, nil
+	case isDigit(d):
+		n, _ := p.number()
+		return &Addr{typ: 'l', n: n}, nil
+	case d == '/' || d == '?':
+		re, err := p.regexp()
+		if err != nil {
+			return nil, err
+		}
+		return &Addr{typ: d, re: re}, nil
+	case d == '$' || d == '.':
+		p.i++
+		return &Addr{typ: d}, nil
+	}
+	return nil, nil
+}
+
+func (p *parser) number() (int, bool) {
+	j := p.i
+	for p.i < len(p.s) && isDigit(p.s[p.i]) {
+		p.i++
+	}
+	if j == p.i {
+		return 0, false
+	}
+	n, _ := strconv.Atoi(p.s[j:p.i])
+	return n, true
+}
+
+// Parses "/re/" (any delimiter). The last delimiter is optional at the end of the text.
+func (p *parser) regexp() (*regexp.Regexp, error) {
+	d := p.peek()
+	if d == 0 || isAlnum(d) || d == ' ' || d == '\\' {
+		return nil, p.errorf("missing regular expression")
+	}
+	p.i++
+	s, err := p.delimited(d, false)
+	if err != nil {
+		return nil, err
+	}
+	if s == "" {
+		return nil, p.errorf("empty regular expression")
+	}
+	re, err := regexp.Compile("(?m)" + s)
+	if err != nil {
+		return nil, fmt.Errorf("sam: %w", err)
+	}
+	return re, nil
+}
+
+// Reads up to the delimiter d. An escaped delimiter is unescaped, other escapes are kept (regexp, replacement), unless unescape is set (text to insert).
+func (p *parser) delimited(d byte, unescape bool) (string, error) {
+	sb := &strings.Builder{}
+	for p.i < len(p.s) {
+		c := p.s[p.i]
+		p.i++
+		switch {
+		case c == d:
+			return sb.String(), nil
+		case c == '\\' && p.i < len(p.s):
+			e := p.s[p.i]
+			p.i++
+			switch {
+			case e == d:
+				sb.WriteByte(e)
+			case unescape && e == 'n':
+				sb.WriteByte('\n')
+			case unescape && e == 't':
+				sb.WriteByte('\t')
+			case unescape && e == '\\':
+				sb.WriteByte('\\')
+			default:
+				sb.WriteByte('\\')
+				sb.WriteByte(e)
+			}
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String(), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
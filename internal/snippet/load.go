@@ -0,0 +1,73 @@
+package snippet
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+type Def struct {
+	Name        string
+	Prefixes    []string // trigger words
+	Body        string
+	Description string
+}
+
+// Reads a snippets file in the vscode format:
+//
+//	{"name": {"prefix": "iferr", "body": ["if err != nil {", "\treturn $1", "}"], "description": "..."}}
+//
+// The prefix and the body can be a string or a list of strings (body lines).
+func LoadFile(filename string) ([]*Def, error) {
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]*struct {
+		Prefix      stringList `json:"prefix"`
+		Body        stringList `json:"body"`
+		Description string     `json:"description"`
+	}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("snippet: %v: %w", filename, err)
+	}
+	u := []*Def{}
+	for name, v := range m {
+		if v == nil {
+			continue
+		}
+		prefixes := []string(v.Prefix)
+		if len(prefixes) == 0 {
+			prefixes = []string{name}
+		}
+		d := &Def{
+			Name:        name,
+			Prefixes:    prefixes,
+			Body:        strings.Join(v.Body, "\n"),
+			Description: v.Description,
+		}
+		u = append(u, d)
+	}
+	sort.Slice(u, func(i, j int) bool { return u[i].Name < u[j].Name })
+	return u, nil
+}
+
+//----------
+
+type stringList []string
+
+func (sl *stringList) UnmarshalJSON(b []byte) error {
+	s := ""
+	if err := json.Unmarshal(b, &s); err == nil {
+		*sl = []string{s}
+		return nil
+	}
+	u := []string{}
+	if err := json.Unmarshal(b, &u); err != nil {
+		return err
+	}
+	*sl = u
+	return nil
+}
@@ -0,0 +1,327 @@
+// Snippets in the TextMate/LSP snippet syntax.
+//
+// Supported: tabstops ("$1", "${1}"), placeholders ("${1:default}", can be nested), choices ("${1|a,b|}", the first is used), the final cursor position ("$0"), and variables ("$NAME", "${NAME}", "${NAME:default}"). Transforms ("${NAME/re/format/}") are parsed but not applied.
+package snippet
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type Snippet struct {
+	Text  string
+	Stops []*Stop // in visiting order, repeated tabstops are consecutive, $0 is last
+}
+
+type Stop struct {
+	N          int
+	Start, End int // byte offsets in Text
+}
+
+// Value of a variable, false if unknown.
+type VarFn func(name string) (string, bool)
+
+func Expand(body string, vars VarFn) (*Snippet, error) {
+	p := &parser{src: []rune(body)}
+	nodes, err := p.parse(false)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &renderer{vars: vars, defaults: map[int][]*node{}, active: map[int]bool{}}
+	r.collectDefaults(nodes)
+	r.render(nodes)
+
+	sn := &Snippet{Text: r.buf.String(), Stops: r.stops}
+	hasEnd := false
+	for _, st := range sn.Stops {
+		if st.N == 0 {
+			hasEnd = true
+		}
+	}
+	if !hasEnd {
+		n := len(sn.Text)
+		sn.Stops = append(sn.Stops, &Stop{N: 0, Start: n, End: n})
+	}
+	sort.SliceStable(sn.Stops, func(i, j int) bool {
+		a, b := sn.Stops[i].N, sn.Stops[j].N
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+	return sn, nil
+}
+
+//----------
+
+type nodeKind int
+
+const (
+	textNode nodeKind = iota
+	stopNode
+	varNode
+)
+
+type node struct {
+	kind     nodeKind
+	text     string // text node
+	n        int    // stop node
+	name     string // var node
+	children []*node
+	choices  []string
+}
+
+type parser struct {
+	src []rune
+	i   int
+}
+
+func (p *parser) parse(inner bool) ([]*node, error) {
+	nodes := []*node{}
+	buf := []rune{}
+	flush := func() {
+		if len(buf) > 0 {
+			nodes = append(nodes, &node{kind: textNode, text: string(buf)})
+			buf = nil
+		}
+	}
+	for p.i < len(p.src) {
+		c := p.src[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.src) && strings.ContainsRune(`$}\`, p.src[p.i+1]):
+			buf = append(buf, p.src[p.i+1])
+			p.i += 2
+		case c == '}' && inner:
+			flush()
+			return nodes, nil
+		case c == '$':
+			n, err := p.dollar()
+			if err != nil {
+				return nil, err
+			}
+			if n == nil {
+				buf = append(buf, c)
+				p.i++
+				continue
+			}
+			flush()
+			nodes = append(nodes, n)
+		default:
+			buf = append(buf, c)
+			p.i++
+		}
+	}
+	if inner {
+		return nil, errors.New("snippet: missing '}'")
+	}
+	flush()
+	return nodes, nil
+}
+
+// Parses a construct starting with '$'. Returns nil (and doesn't advance) if it is a literal '$'.
+func (p *parser) dollar() (*node, error) {
+	start := p.i
+	p.i++ // '$'
+	if p.i >= len(p.src) {
+		p.i = start
+		return nil, nil
+	}
+	c := p.src[p.i]
+	switch {
+	case isDigit(c):
+		return &node{kind: stopNode, n: p.readInt()}, nil
+	case isVarStart(c):
+		return &node{kind: varNode, name: p.readName()}, nil
+	case c != '{':
+		p.i = start
+		return nil, nil
+	}
+	p.i++ // '{'
+	if p.i >= len(p.src) {
+		p.i = start
+		return nil, nil
+	}
+
+	n := &node{}
+	switch c := p.src[p.i]; {
+	case isDigit(c):
+		n.kind = stopNode
+		n.n = p.readInt()
+	case isVarStart(c):
+		n.kind = varNode
+		n.name = p.readName()
+	default:
+		p.i = start
+		return nil, nil
+	}
+	if p.i >= len(p.src) {
+		return nil, errors.New("snippet: missing '}'")
+	}
+
+	switch p.src[p.i] {
+	case '}':
+		p.i++
+		return n, nil
+	case ':':
+		p.i++
+		children, err := p.parse(true)
+		if err != nil {
+			return nil, err
+		}
+		p.i++ // '}'
+		n.children = children
+		return n, nil
+	case '|':
+		if n.kind != stopNode {
+			break
+		}
+		p.i++
+		choices, err := p.readChoices()
+		if err != nil {
+			return nil, err
+		}
+		n.choices = choices
+		return n, nil
+	case '/':
+		if err := p.skipTransform(); err != nil {
+			return nil, err
+		}
+		return n, nil
+	}
+	return nil, errors.New("snippet: unexpected " + strconv.QuoteRune(p.src[p.i]))
+}
+
+func (p *parser) readInt() int {
+	k := p.i
+	for p.i < len(p.src) && isDigit(p.src[p.i]) {
+		p.i++
+	}
+	v, _ := strconv.Atoi(string(p.src[k:p.i]))
+	return v
+}
+
+func (p *parser) readName() string {
+	k := p.i
+	for p.i < len(p.src) && (isVarStart(p.src[p.i]) || isDigit(p.src[p.i])) {
+		p.i++
+	}
+	return string(p.src[k:p.i])
+}
+
+// Reads "a,b|}" (after the first '|').
+func (p *parser) readChoices() ([]string, error) {
+	u := []string{}
+	buf := []rune{}
+	for p.i < len(p.src) {
+		c := p.src[p.i]
+		switch {
+		case c == '\\' && p.i+1 < len(p.src) && strings.ContainsRune(`$}\,|`, p.src[p.i+1]):
+			buf = append(buf, p.src[p.i+1])
+			p.i += 2
+		case c == ',':
+			u = append(u, string(buf))
+			buf = nil
+			p.i++
+		case c == '|' && p.i+1 < len(p.src) && p.src[p.i+1] == '}':
+			u = append(u, string(buf))
+			p.i += 2
+			return u, nil
+		default:
+			buf = append(buf, c)
+			p.i++
+		}
+	}
+	return nil, errors.New("snippet: missing '|}'")
+}
+
+// Skips "/re/format/options}" (at the first '/').
+func (p *parser) skipTransform() error {
+	depth := 0
+	for p.i < len(p.src) {
+		c := p.src[p.i]
+		switch {
+		case c == '\\':
+			p.i++
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth == 0 {
+				p.i++
+				return nil
+			}
+			depth--
+		}
+		p.i++
+	}
+	return errors.New("snippet: missing '}'")
+}
+
+func isDigit(ru rune) bool    { return ru >= '0' && ru <= '9' }
+func isVarStart(ru rune) bool { return ru == '_' || (ru < unicode.MaxASCII && unicode.IsLetter(ru)) }
+
+//----------
+
+type renderer struct {
+	buf      strings.Builder
+	stops    []*Stop
+	vars     VarFn
+	defaults map[int][]*node // first placeholder content of each tabstop, used by the repeated ones
+	active   map[int]bool    // tabstops being rendered, avoids recursion
+}
+
+func (r *renderer) collectDefaults(nodes []*node) {
+	for _, n := range nodes {
+		if n.kind == stopNode {
+			if _, ok := r.defaults[n.n]; !ok && (n.children != nil || n.choices != nil) {
+				if n.choices != nil {
+					r.defaults[n.n] = []*node{{kind: textNode, text: n.choices[0]}}
+				} else {
+					r.defaults[n.n] = n.children
+				}
+			}
+		}
+		r.collectDefaults(n.children)
+	}
+}
+
+func (r *renderer) render(nodes []*node) {
+	for _, n := range nodes {
+		switch n.kind {
+		case textNode:
+			r.buf.WriteString(n.text)
+		case stopNode:
+			if r.active[n.n] {
+				continue
+			}
+			r.active[n.n] = true
+			start := r.buf.Len()
+			switch {
+			case len(n.choices) > 0:
+				r.buf.WriteString(n.choices[0])
+			case n.children != nil:
+				r.render(n.children)
+			default:
+				r.render(r.defaults[n.n])
+			}
+			r.stops = append(r.stops, &Stop{N: n.n, Start: start, End: r.buf.Len()})
+			r.active[n.n] = false
+		case varNode:
+			v, ok := "", false
+			if r.vars != nil {
+				v, ok = r.vars(n.name)
+			}
+			switch {
+			case ok && (v != "" || n.children == nil):
+				r.buf.WriteString(v)
+			case n.children != nil:
+				r.render(n.children)
+			default:
+				r.buf.WriteString(n.name) // unknown variable
+			}
+		}
+	}
+}
@@ -0,0 +1,267 @@
+// Detection and conversion of the text files encoding and line endings. The text is edited as UTF-8 with "\n" line endings, and converted back when saved.
+package textencoding
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+)
+
+type Format struct {
+	Encoding string // "utf-8", "utf-16le", "utf-16be" (with a "-bom" suffix if the file starts with a byte order mark), or a legacy encoding name (ex: "windows-1252")
+	EOL      string // "lf", "crlf", "cr", or "mixed" (not normalized)
+}
+
+var Default = Format{Encoding: "utf-8", EOL: "lf"}
+
+func (f Format) String() string {
+	return f.Encoding + "," + f.EOL
+}
+
+//----------
+
+// Decodes the file content. Text that is not valid UTF-8 (and has no BOM and doesn't look like UTF-16) is decoded with the first legacy encoding of the fallbacks that converts back to the same bytes. Otherwise the content is kept unchanged (as "utf-8", ex: binary files).
+func Decode(b []byte, fallbacks ...string) ([]byte, Format) {
+	f := Default
+	b2, enc, ok := decodeText(b, fallbacks)
+	if !ok {
+		return b, f
+	}
+	f.Encoding = enc
+	f.EOL = detectEOL(b2)
+	return normalizeEOL(b2, f.EOL), f
+}
+
+func decodeText(b []byte, fallbacks []string) ([]byte, string, bool) {
+	candidates := []string{}
+	switch {
+	case bytes.HasPrefix(b, bomUTF8):
+		candidates = append(candidates, "utf-8-bom")
+	case bytes.HasPrefix(b, bomUTF16LE):
+		candidates = append(candidates, "utf-16le-bom")
+	case bytes.HasPrefix(b, bomUTF16BE):
+		candidates = append(candidates, "utf-16be-bom")
+	default:
+		if enc, ok := looksUTF16(b); ok {
+			candidates = append(candidates, enc)
+		} else if utf8.Valid(b) {
+			return b, "utf-8", true
+		}
+	}
+	if bytes.IndexByte(b, 0) < 0 { // nul bytes: binary
+		for _, enc := range fallbacks {
+			if enc != "" && !strings.HasPrefix(enc, "utf-") {
+				candidates = append(candidates, enc)
+			}
+		}
+	}
+
+	for _, enc := range candidates {
+		b2, err := decode(b, enc)
+		if err != nil || !utf8.Valid(b2) {
+			continue
+		}
+		// must be able to save the same content
+		if b3, err := encode(b2, enc); err != nil || !bytes.Equal(b, b3) {
+			continue
+		}
+		return b2, enc, true
+	}
+	return nil, "", false
+}
+
+// Reports whether the decoded content is not text (nul bytes or invalid utf-8), ex: executables or images.
+func IsBinary(b []byte) bool {
+	return bytes.IndexByte(b, 0) >= 0 || !utf8.Valid(b)
+}
+
+// Decodes the file content with the encoding, only the line endings are detected.
+func DecodeAs(b []byte, enc string) ([]byte, Format, error) {
+	b2, err := decode(b, enc)
+	if err != nil {
+		return nil, Format{}, err
+	}
+	eol := detectEOL(b2)
+	return normalizeEOL(b2, eol), Format{Encoding: enc, EOL: eol}, nil
+}
+
+// Checks the nul bytes positions of the (mostly ascii) text.
+func looksUTF16(b []byte) (string, bool) {
+	n := min(len(b), 4096) &^ 1
+	if n < 2 {
+		return "", false
+	}
+	even, odd := 0, 0
+	for i := 0; i < n; i += 2 {
+		if b[i] == 0 {
+			even++
+		}
+		if b[i+1] == 0 {
+			odd++
+		}
+	}
+	half := n / 2
+	switch {
+	case odd*10 >= half*3 && even*20 < half:
+		return "utf-16le", true
+	case even*10 >= half*3 && odd*20 < half:
+		return "utf-16be", true
+	}
+	return "", false
+}
+
+//----------
+
+// Converts the normalized (utf-8, "\n") text to the format.
+func Encode(b []byte, f Format) ([]byte, error) {
+	switch f.EOL {
+	case "", "lf", "mixed": // mixed: not normalized, saved as is
+	case "crlf":
+		b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r\n"))
+	case "cr":
+		b = bytes.ReplaceAll(b, []byte("\n"), []byte("\r"))
+	default:
+		return nil, fmt.Errorf("unknown line ending: %q", f.EOL)
+	}
+	return encode(b, f.Encoding)
+}
+
+func encode(b []byte, name string) ([]byte, error) {
+	enc, bom, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if enc != nil {
+		b2, err := enc.NewEncoder().Bytes(b)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", name, err)
+		}
+		b = b2
+	}
+	if len(bom) > 0 {
+		b = append(bom[:len(bom):len(bom)], b...)
+	}
+	return b, nil
+}
+
+func decode(b []byte, name string) ([]byte, error) {
+	enc, bom, err := lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(b, bom) {
+		return nil, fmt.Errorf("%v: missing byte order mark", name)
+	}
+	b = b[len(bom):]
+	if enc == nil {
+		return b, nil
+	}
+	return enc.NewDecoder().Bytes(b)
+}
+
+var (
+	bomUTF8    = []byte("\xef\xbb\xbf")
+	bomUTF16LE = []byte("\xff\xfe")
+	bomUTF16BE = []byte("\xfe\xff")
+)
+
+// Encoding (nil for utf-8) and byte order mark.
+func lookup(name string) (encoding.Encoding, []byte, error) {
+	switch name {
+	case "", "utf-8":
+		return nil, nil, nil
+	case "utf-8-bom":
+		return nil, bomUTF8, nil
+	case "utf-16le":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil, nil
+	case "utf-16le-bom":
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), bomUTF16LE, nil
+	case "utf-16be":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil, nil
+	case "utf-16be-bom":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), bomUTF16BE, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unknown encoding: %q", name)
+	}
+	return enc, nil, nil
+}
+
+// Canonical encoding name (ex: "latin1" -> "windows-1252").
+func EncodingName(name string) (string, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "utf8":
+		name = "utf-8"
+	case "utf-16":
+		name = "utf-16le-bom"
+	}
+	enc, _, err := lookup(name)
+	if err != nil {
+		return "", err
+	}
+	if enc == nil || strings.HasPrefix(name, "utf-16") {
+		return name, nil
+	}
+	name2, err := htmlindex.Name(enc)
+	if err != nil {
+		return "", err
+	}
+	if name2 == "utf-8" {
+		return "", fmt.Errorf("encoding %q is utf-8", name)
+	}
+	return name2, nil
+}
+
+func EOLName(name string) (string, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "lf", "crlf", "cr":
+		return name, nil
+	case "unix":
+		return "lf", nil
+	case "dos", "windows":
+		return "crlf", nil
+	case "mac":
+		return "cr", nil
+	}
+	return "", fmt.Errorf("unknown line ending: %q", name)
+}
+
+//----------
+
+// The most used line ending, or "mixed" if converting to it would change other lines (the text is kept as is, saving an unedited file doesn't change it).
+func detectEOL(b []byte) string {
+	crlf := bytes.Count(b, []byte("\r\n"))
+	lf := bytes.Count(b, []byte("\n")) - crlf
+	cr := bytes.Count(b, []byte("\r")) - crlf
+	switch {
+	case crlf > 0 && lf > 0:
+		return "mixed"
+	case crlf > 0 && crlf >= cr: // lone "\r" are kept
+		return "crlf"
+	case cr > 0 && cr > lf:
+		if crlf > 0 || lf > 0 {
+			return "mixed"
+		}
+		return "cr"
+	}
+	return "lf"
+}
+
+func normalizeEOL(b []byte, eol string) []byte {
+	switch eol {
+	case "crlf":
+		return bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+	case "cr":
+		b = bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))
+		return bytes.ReplaceAll(b, []byte("\r"), []byte("\n"))
+	}
+	return b
+}
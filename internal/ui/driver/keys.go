@@ -1,8 +1,10 @@
 package driver
 
 import (
+	"fmt"
 	"slices"
 	"strings"
+	"unicode"
 	"unicode/utf8"
 
 	"github.com/veandco/go-sdl2/sdl"
@@ -365,52 +367,59 @@ var mousebuttons = []string{
 	"MouseX2",     /* SDL_BUTTON_X2 */
 }
 
-func (k Key) Is(name string) bool {
-	modifiers := []struct {
-		name string
-		code sdl.Keymod
-	}{
-		{"ctrl-", sdl.KMOD_CTRL},
-		{"C-", sdl.KMOD_CTRL},
-		{"shift-", sdl.KMOD_SHIFT},
-		{"S-", sdl.KMOD_SHIFT},
-		{"alt-", sdl.KMOD_ALT},
-		{"A-", sdl.KMOD_ALT},
-		{"meta-", sdl.KMOD_GUI},
-		{"M-", sdl.KMOD_GUI},
-	}
+var keymodifiers = []struct {
+	name string
+	code sdl.Keymod
+}{
+	{"ctrl-", sdl.KMOD_CTRL},
+	{"C-", sdl.KMOD_CTRL},
+	{"shift-", sdl.KMOD_SHIFT},
+	{"S-", sdl.KMOD_SHIFT},
+	{"alt-", sdl.KMOD_ALT},
+	{"A-", sdl.KMOD_ALT},
+	{"meta-", sdl.KMOD_GUI},
+	{"M-", sdl.KMOD_GUI},
+}
 
-	mousemod := []struct {
-		name string
-		code MouseButton
-	}{
-		{"LMB-", ButtonLeft},
-		{"MMB-", ButtonMiddle},
-		{"RMB-", ButtonRight},
-	}
+var mousemodifiers = []struct {
+	name string
+	code MouseButton
+}{
+	{"LMB-", ButtonLeft},
+	{"MMB-", ButtonMiddle},
+	{"RMB-", ButtonRight},
+}
 
+// Splits the modifiers prefixes from a key name. Returns the number of modifiers found.
+func parseKeyName(name string) (sdl.Keymod, MouseButton, int, string) {
 	qkeymod := sdl.Keymod(0)
 	qmousemod := MouseButton(0)
+	n := 0
 nameloop:
 	for len(name) > 0 {
-		for _, mod := range modifiers {
+		for _, mod := range keymodifiers {
 			if strings.HasPrefix(name, mod.name) {
-				modlen := len(mod.name)
-				name = name[modlen:]
+				name = name[len(mod.name):]
 				qkeymod |= mod.code
+				n++
 				continue nameloop
 			}
 		}
-		for _, mod := range mousemod {
+		for _, mod := range mousemodifiers {
 			if strings.HasPrefix(name, mod.name) {
-				modlen := len(mod.name)
-				name = name[modlen:]
+				name = name[len(mod.name):]
 				qmousemod |= mod.code
+				n++
 				continue nameloop
 			}
 		}
 		break
 	}
+	return qkeymod, qmousemod, n, name
+}
+
+func (k Key) Is(name string) bool {
+	qkeymod, qmousemod, _, name := parseKeyName(name)
 	if k.KeyMod&qkeymod != qkeymod {
 		return false
 	}
@@ -421,6 +430,10 @@ nameloop:
 		return k.Rune == 0 && k.Mouse == 0 && k.Sym == 0
 	} else if utf8.RuneCountInString(name) == 1 {
 		query, _ := utf8.DecodeRuneInString(name)
+		if k.Rune == 0 && k.Sym != 0 {
+			// no text input while ctrl is pressed, match the key (ex: "ctrl-D")
+			return k.SymName() == string(unicode.ToUpper(query))
+		}
 		return query == k.Rune
 	} else if mbn := slices.Index(mousebuttons, name); mbn != -1 {
 		return k.Mouse == 1<<(mbn-1)
@@ -431,6 +444,83 @@ nameloop:
 	}
 }
 
+// Reports if name is a key that can be matched with Key.Is.
+func ValidKeyName(name string) bool {
+	_, _, _, name = parseKeyName(name)
+	switch {
+	case utf8.RuneCountInString(name) == 1:
+		return true
+	case name == "":
+		return false
+	}
+	return slices.Contains(mousebuttons, name) || slices.Contains(scancodes, name)
+}
+
+// Number of modifiers in a key name (ex: "C-S-Up" has 2). Used to prefer the more specific of several names matching the same key.
+func KeyNameMods(name string) int {
+	_, _, n, _ := parseKeyName(name)
+	return n
+}
+
+// Name of the key (ex: "A", "Escape"), empty for mouse or unknown keys.
+func (k Key) SymName() string {
+	if k.Sym == 0 || int(k.Sym) >= len(scancodes) {
+		return ""
+	}
+	return scancodes[k.Sym]
+}
+
+// Name of the key with the modifiers pressed, in the format matched by Key.Is (ex: "ctrl-shift-V", "a", "Return"). Empty for keys without a name (ex: mouse buttons).
+func (k Key) Name() string {
+	name := k.SymName()
+	if k.Rune != 0 {
+		name = string(k.Rune)
+	}
+	if name == "" {
+		return ""
+	}
+	sb := strings.Builder{}
+	for _, mod := range keymodifiers {
+		// long names only (ex: "ctrl-", not "C-")
+		if len(mod.name) > 2 && k.KeyMod&mod.code != 0 {
+			sb.WriteString(mod.name)
+		}
+	}
+	for _, mod := range mousemodifiers {
+		if k.MouseMod&mod.code != 0 {
+			sb.WriteString(mod.name)
+		}
+	}
+	sb.WriteString(name)
+	return sb.String()
+}
+
+// Key from its name (see Key.Name). A single rune is text input, unless ctrl is pressed (no text input with ctrl, ex: "ctrl-V" is the V key).
+func ParseKey(name string) (Key, error) {
+	keymod, mousemod, _, s := parseKeyName(name)
+	k := Key{KeyMod: keymod, MouseMod: mousemod}
+	if utf8.RuneCountInString(s) == 1 {
+		if keymod&sdl.KMOD_CTRL == 0 {
+			k.Type = KeyRune
+			k.Rune, _ = utf8.DecodeRuneInString(s)
+			return k, nil
+		}
+		s = strings.ToUpper(s)
+	}
+	sym := slices.Index(scancodes, s)
+	if s == "" || sym < 0 {
+		return Key{}, fmt.Errorf("unknown key: %q", name)
+	}
+	k.Type = KeyControl
+	k.Sym = sdl.Scancode(sym)
+	return k, nil
+}
+
+// Reports if the key is a modifier key on its own (ex: ctrl pressed before the next chord).
+func (k Key) IsModifier() bool {
+	return k.Sym >= sdl.SCANCODE_LCTRL && k.Sym <= sdl.SCANCODE_RGUI
+}
+
 func (k Key) HasMod(mod sdl.Keymod) bool {
 	return k.KeyMod&mod != 0
 }
@@ -23,6 +23,7 @@ type TextArea struct {
 func NewTextArea(ui *UI) *TextArea {
 	ta := &TextArea{ui: ui}
 	ta.TextEditX = widget.NewTextEditX(ui)
+	ta.KeyActionFn = ta.keyAction
 	return ta
 }
 
@@ -79,13 +80,33 @@ func (ta *TextArea) handleInputEvent2(ev0 any, p image.Point) bool {
 			ta.ENode.Cursor = sdl.SYSTEM_CURSOR_ARROW
 		}
 	case *driver.KeyDown:
+		if ev.Key.Is("S-Tab") && ta.snippetJump(true) {
+			return true
+		}
 		if ev.Key.Is("Tab") {
+			if ta.snippetJump(false) {
+				return true
+			}
 			return ta.inlineCompleteEv()
 		}
 	}
 	return false
 }
 
+// Moves between the placeholders of an inserted snippet.
+func (ta *TextArea) snippetJump(back bool) bool {
+	ta.BeginUndoGroup()
+	defer ta.EndUndoGroup()
+	handled, err := editbuf.SnippetJump(ta.EditCtx(), back)
+	if err != nil {
+		ta.Error(err)
+	}
+	if handled {
+		ta.MakeCursorVisible()
+	}
+	return handled
+}
+
 func (ta *TextArea) inlineCompleteEv() bool {
 	c := ta.Cursor()
 	if c.HaveSelection() {
@@ -106,6 +127,12 @@ func (ta *TextArea) inlineCompleteEv() bool {
 	return ev2.ReplyHandled
 }
 
+func (ta *TextArea) keyAction(action string) bool {
+	ev2 := &TextAreaKeyActionEvent{ta, action, false}
+	ta.EvReg.RunCallbacks(TextAreaKeyActionEventId, ev2)
+	return ev2.ReplyHandled
+}
+
 func (ta *TextArea) PointIndexInsideSelection(p image.Point) bool {
 	c := ta.Cursor()
 	if s, e, ok := c.SelectionIndexes(); ok {
@@ -143,6 +170,7 @@ const (
 	TextAreaInlineCompleteEventId
 	TextAreaInputEventId
 	TextAreaLayoutEventId
+	TextAreaKeyActionEventId
 )
 
 type TextAreaCmdEvent struct {
@@ -182,6 +210,12 @@ type TextAreaInputEvent struct {
 	ReplyHandled bool
 }
 
+type TextAreaKeyActionEvent struct {
+	TextArea     *TextArea
+	Action       string // keymap action not handled by the text editing (ex: "save", "Find")
+	ReplyHandled bool
+}
+
 type TextAreaLayoutEvent struct {
 	TextArea *TextArea
 }
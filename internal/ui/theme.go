@@ -2,19 +2,30 @@ package ui
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"image"
 	"image/color"
+	"io"
+	"io/fs"
+	"maps"
 	"os"
 	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/friedelschoen/glake/config"
+	"github.com/friedelschoen/glake/internal/drawer"
 	"github.com/friedelschoen/glake/internal/findfont"
 	"github.com/friedelschoen/glake/internal/ui/widget"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/gomonobolditalic"
+	"golang.org/x/image/font/gofont/gomonoitalic"
 	"golang.org/x/image/font/opentype"
 	"golang.org/x/image/math/fixed"
 )
@@ -93,16 +104,26 @@ func parseColor(text string) (color.Color, error) {
 	return nil, fmt.Errorf("invalid color format: incorrect length")
 }
 
-// ParsePalette reads a color palette from an INI-like file.
-func parsePalette(filename string) (map[string]color.Color, error) {
-	file, err := os.Open(filename)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open palette file: %w", err)
-	}
-	defer file.Close()
+// Palette keys with values that are not colors, and their check.
+var paletteStrings = map[string]func(string) error{
+	"syntax_style": func(s string) error { // chroma style name
+		_, err := widget.SyntaxStyle(s)
+		return err
+	},
+}
+
+// Theme file entries.
+type themePalette struct {
+	colors  widget.Palette
+	strings map[string]string // values that are not colors (widget.Theme.Strings)
+}
 
+// ParsePalette reads a color palette from an INI-like file. Returns the name of the inherited theme if there is an "inherit = <theme>" entry (before any section).
+func parsePalette(r io.Reader) (*themePalette, string, error) {
 	palette := make(map[string]color.Color)
-	scanner := bufio.NewScanner(file)
+	strs := map[string]string{}
+	inherit := ""
+	scanner := bufio.NewScanner(r)
 	var section string
 	lineNum := 0
 
@@ -126,26 +147,78 @@ func parsePalette(filename string) (map[string]color.Color, error) {
 		// Parse key-value pairs
 		key, value, found := strings.Cut(text, "=")
 		if !found {
-			return nil, fmt.Errorf("malformed line %d: missing '='", lineNum)
+			return nil, "", fmt.Errorf("malformed line %d: missing '='", lineNum)
 		}
 
 		key = section + strings.TrimSpace(key)
 		value = strings.TrimSpace(value)
 
+		if key == "inherit" {
+			inherit = value
+			continue
+		}
+
+		if check, ok := paletteStrings[key]; ok {
+			if err := check(value); err != nil {
+				return nil, "", fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			strs[key] = value
+			continue
+		}
+		if section == "syntax_" {
+			key, value, err := parseSyntaxEntry(key, value)
+			if err != nil {
+				return nil, "", fmt.Errorf("malformed line %d: %w", lineNum, err)
+			}
+			strs[key] = value
+			continue
+		}
+
 		// Convert to color
 		col, err := parseColor(value)
 		if err != nil {
-			return nil, fmt.Errorf("malformed line %d (key: %s): %w", lineNum, key, err)
+			return nil, "", fmt.Errorf("malformed line %d (key: %s): %w", lineNum, key, err)
 		}
 
 		palette[key] = col
 	}
 
 	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error reading file: %w", err)
+		return nil, "", fmt.Errorf("error reading file: %w", err)
 	}
 
-	return palette, nil
+	return &themePalette{colors: palette, strings: strs}, inherit, nil
+}
+
+// Syntax section entry (ex: "keyword = #0000a0 bold", "name.function = bg:yellow underline"), converted to a chroma style entry.
+func parseSyntaxEntry(key, value string) (string, string, error) {
+	name := strings.TrimPrefix(key, "syntax_")
+	if s, ok := widget.SyntaxTokenAliases[name]; ok {
+		name = s
+	}
+	if _, ok := widget.SyntaxTokenTypes[name]; !ok {
+		return "", "", fmt.Errorf("unknown syntax token type: %q", name)
+	}
+
+	u := []string{}
+	for _, f := range strings.Fields(value) {
+		switch f {
+		case "bold", "nobold", "italic", "noitalic", "underline", "nounderline", "noinherit":
+			u = append(u, f)
+			continue
+		}
+		prefix := ""
+		if s, ok := strings.CutPrefix(f, "bg:"); ok {
+			prefix, f = "bg:", s
+		}
+		c, err := parseColor(f)
+		if err != nil {
+			return "", "", fmt.Errorf("syntax %v: %w", name, err)
+		}
+		r, g, b, _ := c.RGBA()
+		u = append(u, fmt.Sprintf("%v#%02x%02x%02x", prefix, r>>8, g>>8, b>>8))
+	}
+	return "syntax_" + name, strings.Join(u, " "), nil
 }
 
 func exist(filename string) bool {
@@ -153,6 +226,14 @@ func exist(filename string) bool {
 	return err == nil /* || !errors.Is(err, os.ErrNotExist) */
 }
 
+func themesDir() string {
+	cdir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return path.Join(cdir, "glake", "themes")
+}
+
 func filepathTheme(name string) string {
 	if exist(name) {
 		return name
@@ -160,13 +241,12 @@ func filepathTheme(name string) string {
 	if exist(name + themeExtension) {
 		return name + themeExtension
 	}
-	cdir, err := os.UserConfigDir()
-	if err == nil {
-		cname := path.Join(cdir, "glake", "themes", name)
+	if dir := themesDir(); dir != "" {
+		cname := path.Join(dir, name)
 		if exist(cname) {
 			return cname
 		}
-		cname = path.Join(cdir, "glake", "themes", name+themeExtension)
+		cname = path.Join(dir, name+themeExtension)
 		if exist(cname) {
 			return cname
 		}
@@ -174,14 +254,100 @@ func filepathTheme(name string) string {
 	return ""
 }
 
-func SetColorscheme(name string, node widget.Node) {
-	pal, err := parsePalette(filepathTheme(name))
+// Opens the theme file, or the built-in theme if there is no file or the file was already seen (allows a theme file to inherit the built-in theme with the same name). The filename is empty for built-in themes.
+func openTheme(name string, seen map[string]bool) (io.ReadCloser, string, error) {
+	if filename := filepathTheme(name); filename != "" {
+		if u, err := filepath.Abs(filename); err == nil {
+			filename = u
+		}
+		if !seen[filename] {
+			seen[filename] = true
+			f, err := os.Open(filename)
+			if err != nil {
+				return nil, "", err
+			}
+			return f, filename, nil
+		}
+	}
+	key := "builtin:" + name
+	if seen[key] {
+		return nil, "", fmt.Errorf("inherit loop: %v", name)
+	}
+	seen[key] = true
+	f, err := config.Themes.Open(path.Join("themes", name+themeExtension))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "unable to set theme: %s\n", err)
-		os.Exit(1)
-		return
+		if filepathTheme(name) != "" { // the file was already seen
+			return nil, "", fmt.Errorf("inherit loop: %v", name)
+		}
+		return nil, "", fmt.Errorf("theme not found: %v", name)
 	}
-	node.Embed().SetThemePalette(pal)
+	return f, "", nil
+}
+
+// Palette of the theme with the inherited entries, and the theme files used (to be watched, also on errors: the files read until the error).
+func loadColorscheme(name string, seen map[string]bool) (*themePalette, []string, error) {
+	r, filename, err := openTheme(name, seen)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer r.Close()
+	files := []string{}
+	if filename != "" {
+		files = append(files, filename)
+	}
+	pal, inherit, err := parsePalette(r)
+	if err != nil {
+		if filename != "" {
+			return nil, files, fmt.Errorf("%v: %w", filename, err)
+		}
+		return nil, files, fmt.Errorf("%v: %w", name, err)
+	}
+	if inherit == "" {
+		return pal, files, nil
+	}
+	pal2, files2, err := loadColorscheme(inherit, seen)
+	files = append(files, files2...)
+	if err != nil {
+		return nil, files, fmt.Errorf("%v: inherit: %w", name, err)
+	}
+	maps.Copy(pal2.colors, pal.colors)
+	maps.Copy(pal2.strings, pal.strings)
+	return pal2, files, nil
+}
+
+// Sets the theme palette. Returns the theme files (with the inherited ones) to allow reloading on change, also on errors (the palette is not changed).
+func SetColorscheme(name string, node widget.Node) ([]string, error) {
+	pal, files, err := loadColorscheme(name, map[string]bool{})
+	if err != nil {
+		return files, fmt.Errorf("colortheme: %w", err)
+	}
+	t := *node.Embed().Theme()
+	t.Palette, t.Strings = pal.colors, pal.strings
+	node.Embed().SetTheme(t)
+	ColorTheme = name
+	return files, nil
+}
+
+// Names of the built-in themes and the themes in the user config directory.
+func ColorThemes() []string {
+	u := []string{}
+	add := func(entries []fs.DirEntry) {
+		for _, e := range entries {
+			if name, ok := strings.CutSuffix(e.Name(), themeExtension); ok && !e.IsDir() {
+				u = append(u, name)
+			}
+		}
+	}
+	if entries, err := fs.ReadDir(config.Themes, "themes"); err == nil {
+		add(entries)
+	}
+	if dir := themesDir(); dir != "" {
+		if entries, err := os.ReadDir(dir); err == nil {
+			add(entries)
+		}
+	}
+	slices.Sort(u)
+	return slices.Compact(u)
 }
 
 func loadThemeFont(name string, node widget.Node) error {
@@ -194,23 +360,58 @@ func loadThemeFont(name string, node widget.Node) error {
 }
 
 var ColorTheme = ""
+
+const DefaultColorTheme = "light"
+
 var CurrentFont = ""
 var TTFontOptions opentype.FaceOptions
 
+// Font face with the bold/italic variants of the font family (a *drawer.StyledFontFace).
 func ThemeFontFace(name string, size float64) (font.Face, error) {
-	b, err := findfont.GetFontData(name)
-	if err != nil {
+	opt := TTFontOptions
+	if size != 0 {
+		opt.Size = size
+	}
+
+	b, family, err := findfont.GetFontDataFamily(name)
+	defFont := err != nil
+	if defFont {
 		b = defaultFont
 	}
-	font, err := opentype.Parse(b)
+	ff, err := newFontFace(b, &opt)
 	if err != nil {
 		return nil, err
 	}
-	opt := TTFontOptions
-	if size != 0 {
-		opt.Size = size
+	sf := &drawer.StyledFontFace{Face: ff}
+
+	// variants: a face is not set if the family has no such variant (same font data as the regular, or a font of another family with other advances)
+	variant := func(style string, def []byte) font.Face {
+		b2 := def
+		if !defFont {
+			b3, family3, err := findfont.GetFontDataFamily(name + ":" + style)
+			if err != nil || bytes.Equal(b3, b) || family3 != family {
+				return nil
+			}
+			b2 = b3
+		}
+		ff2, err := newFontFace(b2, &opt)
+		if err != nil {
+			return nil
+		}
+		return ff2
+	}
+	sf.Bold = variant("bold", gomonobold.TTF)
+	sf.Italic = variant("italic", gomonoitalic.TTF)
+	sf.BoldItalic = variant("bold:italic", gomonobolditalic.TTF)
+	return sf, nil
+}
+
+func newFontFace(b []byte, opt *opentype.FaceOptions) (font.Face, error) {
+	f, err := opentype.Parse(b)
+	if err != nil {
+		return nil, err
 	}
-	return opentype.NewFace(font, &opt)
+	return opentype.NewFace(f, opt)
 }
 
 var defaultFont = gomono.TTF
@@ -3,6 +3,7 @@ package ui
 import (
 	"image"
 
+	"github.com/friedelschoen/glake/internal/keymap"
 	"github.com/friedelschoen/glake/internal/ui/driver"
 )
 
@@ -15,6 +16,7 @@ func NewToolbar(ui *UI) *Toolbar {
 	tb := &Toolbar{}
 	tb.TextArea = NewTextArea(ui)
 	tb.SetThemePaletteNamePrefix("toolbar_")
+	tb.EditCtx().Keys = keymap.Toolbar
 	tb.TextArea.Drawer.Opt.EarlyExitMeasure = true // performance
 	return tb
 }
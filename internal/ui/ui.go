@@ -53,8 +53,10 @@ func NewUI(winName string) (*UI, error) {
 	// Embed nodes have their wrapper nodes set when they are appended to another node. The root node is not appended to any other node, therefore it needs to be set here.
 	ui.Root.Embed().SetWrapperForRoot(ui.Root)
 
-	// set theme before root init
-	SetColorscheme(ColorTheme, ui.Root)
+	// set theme before root init (errors are reported by the editor when setting it again)
+	if _, err := SetColorscheme(ColorTheme, ui.Root); err != nil {
+		_, _ = SetColorscheme(DefaultColorTheme, ui.Root)
+	}
 	loadThemeFont(CurrentFont, ui.Root)
 
 	// build ui - needs ui.UI to be set
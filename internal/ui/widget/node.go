@@ -490,6 +490,23 @@ func (en *EmbedNode) treeThemePaletteColor2(name string) (color.Color, bool) {
 	return nil, false
 }
 
+// Theme value that is not a color (see Theme.Strings) in the tree themes, false if not set.
+func (en *EmbedNode) TreeThemePaletteString(name string) (string, bool) {
+	if !strings.HasPrefix(name, en.theme.PaletteNamePrefix) {
+		s := en.theme.PaletteNamePrefix + name
+		if v, ok := en.TreeThemePaletteString(s); ok {
+			return v, true
+		}
+	}
+	if v, ok := en.theme.Strings[name]; ok {
+		return v, true
+	}
+	if en.Parent != nil {
+		return en.Parent.TreeThemePaletteString(name)
+	}
+	return "", false
+}
+
 func (en *EmbedNode) SetThemeFontFace(ff font.Face) {
 	defer en.themeChangeCallback()
 	defer en.MarkNeedsLayout()
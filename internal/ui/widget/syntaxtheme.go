@@ -0,0 +1,102 @@
+package widget
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+	"unicode"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// Syntax token types by theme name: the lowercase chroma token type with "." between categories (ex: "name.function" for chroma.NameFunction). Set in the theme "[syntax]" section, stored in the palette as "syntax_<name>" (chroma style entry strings, ex: "#0000a0 bold").
+var SyntaxTokenTypes = func() map[string]chroma.TokenType {
+	m := map[string]chroma.TokenType{}
+	for tt := range chroma.StandardTypes {
+		m[syntaxTokenName(tt)] = tt
+	}
+	return m
+}()
+
+// Short names of the common syntax token types.
+var SyntaxTokenAliases = map[string]string{
+	"string": "literal.string",
+	"number": "literal.number",
+}
+
+func syntaxTokenName(tt chroma.TokenType) string {
+	sb := strings.Builder{}
+	for i, ru := range tt.String() {
+		if unicode.IsUpper(ru) && i > 0 {
+			sb.WriteByte('.')
+		}
+		sb.WriteRune(unicode.ToLower(ru))
+	}
+	return sb.String()
+}
+
+// Chroma style by name (the "syntax_style" theme value).
+func SyntaxStyle(name string) (*chroma.Style, error) {
+	s, ok := styles.Registry[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown syntax style: %q", name)
+	}
+	return s, nil
+}
+
+// Syntax highlighting style from the theme:
+//   - the "[syntax]" section entries (token types without an entry inherit from their category, ex: "keyword.type" from "keyword"), over the "syntax_style" style if set.
+//   - otherwise the "syntax_style" theme value (chroma style name).
+//   - otherwise a light or dark style (by the text background) with the theme strings and comments colors.
+func (te *TextEditX) themeSyntaxStyle() *chroma.Style {
+	entries := map[chroma.TokenType]string{}
+	for name, tt := range SyntaxTokenTypes {
+		if v, ok := te.TreeThemePaletteString("syntax_" + name); ok {
+			entries[tt] = v
+		}
+	}
+	var base *chroma.Style
+	hasBase := false
+	if name, ok := te.TreeThemePaletteString("syntax_style"); ok {
+		// unknown styles are reported when the theme is loaded (see ui.SetColorscheme)
+		s, err := SyntaxStyle(name)
+		base, hasBase = s, err == nil
+	}
+
+	var sb *chroma.StyleBuilder
+	switch {
+	case len(entries) > 0:
+		if hasBase {
+			sb = base.Builder()
+		} else {
+			sb = chroma.NewStyleBuilder("theme")
+		}
+		for tt, e := range entries {
+			sb.Add(tt, e)
+		}
+	case hasBase:
+		return base
+	default:
+		pcol := te.TreeThemePaletteColor
+		name := "xcode"
+		if bg := pcol("text_bg"); bg != nil && color.GrayModel.Convert(bg).(color.Gray).Y < 0x80 {
+			name = "xcode-dark"
+		}
+		sb = styles.Get(name).Builder()
+		add := func(tt chroma.TokenType, c color.Color) {
+			if c == nil {
+				return
+			}
+			r, g, b, _ := c.RGBA()
+			sb.Add(tt, fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8))
+		}
+		add(chroma.LiteralString, pcol("text_colorize_string_fg"))
+		add(chroma.Comment, pcol("text_colorize_comments_fg"))
+	}
+	style, err := sb.Build()
+	if err != nil {
+		return styles.Fallback
+	}
+	return style
+}
@@ -75,7 +75,12 @@ func (t *Text) SetStr(str string) error {
 }
 
 func (t *Text) contentChanged() {
-	t.Drawer.ContentChanged()
+	t.contentChangedAt(0)
+}
+
+// Content before the offset didn't change.
+func (t *Text) contentChangedAt(offset int) {
+	t.Drawer.ContentChangedAt(offset)
 
 	// content changing can influence the layout in the case of dynamic sized textareas (needs layout). Also in the case of scrollareas that need to recalc scrollbars.
 	t.MarkNeedsLayoutAndPaint()
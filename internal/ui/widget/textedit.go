@@ -15,6 +15,10 @@ type TextEdit struct {
 	rwu     *historybuf.RWUndo
 	ctx     *editbuf.EditorBuffer   // ctx for rw editing utils (contains cursor)
 	RWEvReg *eventregister.Register // the rwundo wraps the rwev, so on a write event callback, the undo data is not commited yet. It is incorrect to try to undo inside a write callback. If a rwev wraps rwundo, undoing will not trigger the outer rwev events, otherwise undoing would register as another undo event (cycle).
+
+	KeyActionFn func(action string) bool // handles keymap actions unknown to editbuf
+
+	commentSym any // string (line comment) or [2]string (block comment), nil for none
 }
 
 func NewTextEdit(uiCtx UIContext) *TextEdit {
@@ -36,10 +40,22 @@ func NewTextEdit(uiCtx UIContext) *TextEdit {
 	return te
 }
 
-func (te *TextEdit) CommentLineSym() any { return nil }
+func (te *TextEdit) CommentLineSym() any { return te.commentSym }
 func (te *TextEdit) PageUp(up bool)      {}
 func (te *TextEdit) ScrollUp(up bool)    {}
 
+// Symbol used by the comment/uncomment actions: a string (line comment), a [2]string (block comment), or nil to disable.
+func (te *TextEdit) SetCommentLineSym(sym any) {
+	te.commentSym = sym
+}
+
+func (te *TextEdit) RunKeyAction(action string) bool {
+	if te.KeyActionFn == nil {
+		return false
+	}
+	return te.KeyActionFn(action)
+}
+
 func (te *TextEdit) RW() ioutil.ReadWriterAt {
 	// TODO: returning rw with undo/events, differs from SetRW(), workaround is to use te.Text.RW() to get underlying rw
 
@@ -51,6 +67,14 @@ func (te *TextEdit) SetRW(rw ioutil.ReadWriterAt) {
 
 	te.Text.SetRW(rw)
 	te.rwev.ReadWriterAt = rw
+
+	// hex view: overwrite editing
+	te.ctx.Hex, _ = rw.(*ioutil.HexView)
+}
+
+// Returns nil if the content is not shown as a hex dump.
+func (te *TextEdit) HexView() *ioutil.HexView {
+	return te.ctx.Hex
 }
 
 func (te *TextEdit) SetRWFromMaster(m *TextEdit) {
@@ -63,8 +87,9 @@ func (te *TextEdit) SetRWFromMaster(m *TextEdit) {
 // Called when the changes are done on this textedit
 func (te *TextEdit) onWrite2(ev any) {
 	e := ev.(*ioutil.RWEvWrite2)
+	te.ctx.Snippet.Update(&e.RWEvWrite)
 	if e.Changed {
-		te.contentChanged()
+		te.contentChangedAt(e.Index)
 	}
 }
 
@@ -72,8 +97,9 @@ func (te *TextEdit) onWrite2(ev any) {
 func (te *TextEdit) HandleRWWrite2(ev *ioutil.RWEvWrite2) {
 	te.stableRuneOffset(&ev.RWEvWrite)
 	te.stableCursor(&ev.RWEvWrite)
+	te.ctx.Snippet.Update(&ev.RWEvWrite)
 	if ev.Changed {
-		te.contentChanged()
+		te.contentChangedAt(ev.Index)
 	}
 }
 
@@ -112,6 +138,23 @@ func (te *TextEdit) undoRedo(redo bool) error {
 	return nil
 }
 
+// Moves to the state of a history node (undo tree branches).
+func (te *TextEdit) UndoTo(n *historybuf.HNode) error {
+	c, ok, err := te.rwu.GoTo(n)
+	if err != nil {
+		return err
+	}
+	if ok {
+		te.ctx.C.Set(c) // restore cursor
+		te.MakeCursorVisible()
+	}
+	return nil
+}
+
+func (te *TextEdit) History() *historybuf.History {
+	return te.rwu.History
+}
+
 func (te *TextEdit) ClearUndones() {
 	te.rwu.History.ClearUndones()
 }
@@ -130,13 +173,44 @@ func (te *TextEdit) OnInputEvent(ev driver.Event) bool {
 	te.BeginUndoGroup()
 	defer te.EndUndoGroup()
 
-	handled, err := editbuf.HandleInput(te.ctx, ev)
+	handle := editbuf.HandleInput
+	switch {
+	case te.ctx.Hex != nil:
+		handle = editbuf.HandleHexInput
+	case te.ctx.Vi != nil:
+		handle = editbuf.HandleViInput
+	}
+	handled, err := handle(te.ctx, ev)
 	if err != nil {
 		te.Error(err)
 	}
 	return handled
 }
 
+// Enables vi-style modal editing. The cursor is drawn as a block outside of insert mode.
+func (te *TextEdit) SetViMode(on bool) {
+	if !on {
+		te.ctx.Vi = nil
+		te.Drawer.Opt.Cursor.Block = false
+		te.MarkNeedsPaint()
+		return
+	}
+	if te.ctx.Vi != nil {
+		return
+	}
+	te.ctx.Vi = editbuf.NewVi()
+	te.ctx.Vi.OnModeChange = func(m editbuf.ViMode) {
+		te.Drawer.Opt.Cursor.Block = m != editbuf.ViInsert
+		te.MarkNeedsPaint()
+	}
+	te.Drawer.Opt.Cursor.Block = true
+	te.MarkNeedsPaint()
+}
+
+func (te *TextEdit) ViMode() bool {
+	return te.ctx.Vi != nil
+}
+
 func (te *TextEdit) SetBytes(b []byte) error {
 	te.BeginUndoGroup()
 	defer te.EndUndoGroup()
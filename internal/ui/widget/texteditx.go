@@ -35,6 +35,11 @@ func NewTextEditX(uiCtx UIContext) *TextEditX {
 
 	te.Text.Drawer.Opt.Cursor.On = true
 
+	// syntax highlight tokens are ready (tokenised in the background)
+	te.Text.Drawer.Opt.SyntaxHighlight.OnUpdate = func() {
+		te.RunOnUIGoRoutine(te.MarkNeedsPaint)
+	}
+
 	// setup colorize order
 	te.Text.Drawer.Opt.Colorize.Groups = []*drawer.ColorizeGroup{
 		&te.Text.Drawer.Opt.SyntaxHighlight.Group,
@@ -219,4 +224,6 @@ func (te *TextEditX) OnThemeChange() {
 	// parenthesis highlight
 	te.Drawer.Opt.ParenthesisHighlight.Fg = pcol("text_parenthesis_fg")
 	te.Drawer.Opt.ParenthesisHighlight.Bg = pcol("text_parenthesis_bg")
+
+	te.Drawer.SetSyntaxStyle(te.themeSyntaxStyle())
 }
@@ -61,12 +61,14 @@ var DefaultPalette = Palette{
 type Theme struct {
 	FontFace          font.Face
 	Palette           Palette
+	Strings           map[string]string // palette values that are not colors (ex: "syntax_style")
 	PaletteNamePrefix string
 }
 
 func (t *Theme) empty() bool {
 	return (t.FontFace == nil &&
 		(t.Palette == nil || t.Palette.Empty()) &&
+		len(t.Strings) == 0 &&
 		t.PaletteNamePrefix == "")
 }
 